@@ -0,0 +1,168 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveEntry locates a file inside an opened archive
+type archiveEntry struct {
+	archive string    // Source path of the archive
+	file    *zip.File // Entry within the archive
+}
+
+// archiveSource wraps a Source so that ZIP archives found while walking are listed as
+// directories of their entries. An entry's path is the archive's path joined with its
+// name inside the archive (e.g. backups/2010.zip/Summer/IMG_1234.jpg), so dates and
+// directory context are parsed from both. Entries are streamed out one at a time and
+// the archive is never extracted as a whole.
+type archiveSource struct {
+	Source
+	archives map[string]*zip.ReadCloser // Opened archives by source path
+	cleanups []func()                   // Remove local copies of remote archives
+	entries  map[string]archiveEntry    // Archive entries by their listed path
+}
+
+// newArchiveSource wraps src so its ZIP archives are processed as well
+func newArchiveSource(src Source) *archiveSource {
+	return &archiveSource{
+		Source:   src,
+		archives: make(map[string]*zip.ReadCloser),
+		entries:  make(map[string]archiveEntry),
+	}
+}
+
+// Walk lists the underlying files, replacing each readable .zip file with its entries
+func (s *archiveSource) Walk(dir string) ([]SourceFile, error) {
+	files, err := s.Source.Walk(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var listed []SourceFile
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file.Path), ".zip") {
+			listed = append(listed, file)
+			continue
+		}
+
+		entries, err := s.openArchive(file.Path)
+		if err != nil {
+			log.Printf("Warning: skipping unreadable archive %s: %v", file.Path, err)
+			continue
+		}
+		log.Printf("Found %d files in archive %s", len(entries), file.Path)
+		listed = append(listed, entries...)
+	}
+	return listed, nil
+}
+
+// openArchive opens an archive (downloading it first if the source is remote) and
+// registers its entries, returning their listed paths
+func (s *archiveSource) openArchive(archivePath string) ([]SourceFile, error) {
+	// ZIP needs random access to its central directory, so remote archives are copied locally
+	localPath, cleanup, err := s.Source.Fetch(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := zip.OpenReader(localPath)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	s.archives[archivePath] = reader
+	s.cleanups = append(s.cleanups, cleanup)
+
+	var files []SourceFile
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := filepath.FromSlash(f.Name)
+		// Ignore entries that would escape the archive and macOS resource-fork folders
+		if !filepath.IsLocal(name) || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		path := filepath.Join(archivePath, name)
+		s.entries[path] = archiveEntry{archive: archivePath, file: f}
+		files = append(files, SourceFile{Path: path, Size: int64(f.UncompressedSize64)})
+	}
+	return files, nil
+}
+
+// Open streams a file, decompressing it if it's an archive entry
+func (s *archiveSource) Open(path string) (io.ReadCloser, error) {
+	if entry, ok := s.entries[path]; ok {
+		return entry.file.Open()
+	}
+	return s.Source.Open(path)
+}
+
+// ReadHead returns the start of a file, decompressing only that much of an archive entry
+func (s *archiveSource) ReadHead(path string, n int) ([]byte, error) {
+	entry, ok := s.entries[path]
+	if !ok {
+		return s.Source.ReadHead(path, n)
+	}
+	r, err := entry.file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readHead(r, n)
+}
+
+// Attrs returns an archive entry's recorded mode and time (its owner isn't known), or a regular file's attributes
+func (s *archiveSource) Attrs(path string) (fileAttrs, error) {
+	entry, ok := s.entries[path]
+	if !ok {
+		return s.Source.Attrs(path)
+	}
+	return fileAttrs{mode: entry.file.Mode().Perm(), uid: -1, gid: -1, modTime: entry.file.Modified}, nil
+}
+
+// Fetch extracts a single archive entry to a temp file, or fetches a regular file
+func (s *archiveSource) Fetch(path string) (string, func(), error) {
+	entry, ok := s.entries[path]
+	if !ok {
+		return s.Source.Fetch(path)
+	}
+
+	r, err := entry.file.Open()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open %s in %s: %w", entry.file.Name, entry.archive, err)
+	}
+	defer r.Close()
+
+	tempFile, err := os.CreateTemp("", "photo-archive-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file for archive entry: %w", err)
+	}
+	tempPath := tempFile.Name()
+	cleanup := func() { os.Remove(tempPath) }
+
+	_, err = io.Copy(tempFile, r)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to extract %s from %s: %w", entry.file.Name, entry.archive, err)
+	}
+	return tempPath, cleanup, nil
+}
+
+// Close closes every opened archive and removes local copies of remote ones
+func (s *archiveSource) Close() {
+	for _, reader := range s.archives {
+		reader.Close()
+	}
+	for _, cleanup := range s.cleanups {
+		cleanup()
+	}
+}
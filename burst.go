@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// burstPatterns recognize burst frames by name; key identifies the burst within its directory
+var burstPatterns = []struct {
+	regex *regexp.Regexp
+	key   func(m []string) string
+}{
+	{
+		// IMG_1234_BURST001.jpg, IMG_1234_BURST002_COVER.jpg
+		regex: regexp.MustCompile(`(?i)^(.+?)[_-]burst(\d{1,4})(?:[_-]cover)?$`),
+		key:   func(m []string) string { return m[1] },
+	},
+	{
+		// Pixel bursts: 00000IMG_00000_BURST20181021143005123_COVER.jpg, 00001IMG_00001_BURST20181021143005123.jpg
+		regex: regexp.MustCompile(`(?i)^(\d{5})img_\d{5}_(burst\d{14,17})(?:_cover)?$`),
+		key:   func(m []string) string { return m[2] },
+	},
+}
+
+// burstSequenceRegex finds the frame number at the end of a BURSTnnn name for zero-padding
+var burstSequenceRegex = regexp.MustCompile(`(?i)([_-]burst)(\d{1,4})([_-]cover)?$`)
+
+// burstGroup is a set of frames from one burst
+type burstGroup struct {
+	leader  string   // First frame in natural sort order, whose date the other frames take
+	members []string // All frames, in natural sort order
+
+	once sync.Once
+	date *DateInfo // Leader's date, resolved on first use (nil if it has none)
+}
+
+// burstMatch returns the key identifying a file's burst, or "" if it isn't a burst frame
+func burstMatch(path string) string {
+	base := filepath.Base(path)
+	stem := strings.TrimSuffix(base, fileExt(base))
+	for _, pattern := range burstPatterns {
+		if m := pattern.regex.FindStringSubmatch(stem); m != nil {
+			return strings.ToLower(filepath.Join(filepath.Dir(path), pattern.key(m)))
+		}
+	}
+	return ""
+}
+
+// findBursts groups burst frames by burst, returning each frame's group
+func findBursts(mediaFiles []string) map[string]*burstGroup {
+	groups := make(map[string]*burstGroup)
+	for _, path := range mediaFiles {
+		key := burstMatch(path)
+		if key == "" {
+			continue
+		}
+		group := groups[key]
+		if group == nil {
+			group = &burstGroup{}
+			groups[key] = group
+		}
+		group.members = append(group.members, path)
+	}
+
+	bursts := make(map[string]*burstGroup)
+	for _, group := range groups {
+		// A lone frame is just a photo
+		if len(group.members) < 2 {
+			continue
+		}
+		naturalSort(group.members)
+		group.leader = group.members[0]
+		for _, path := range group.members {
+			bursts[path] = group
+		}
+	}
+	return bursts
+}
+
+// burstDate returns the date of a burst's first frame, resolving it once for the whole burst
+func (p *PhotoProcessor) burstDate(group *burstGroup) *DateInfo {
+	group.once.Do(func() {
+		src := &fetchedFile{source: p.source, path: group.leader}
+		defer src.Close()
+		group.date, _, _, _ = p.resolveDate(group.leader, src)
+	})
+	return group.date
+}
+
+// padBurstSequence zero-pads a BURSTn frame number to three digits so frames sort in order
+func padBurstSequence(desc string) string {
+	return burstSequenceRegex.ReplaceAllStringFunc(desc, func(match string) string {
+		m := burstSequenceRegex.FindStringSubmatch(match)
+		seq, _ := strconv.Atoi(m[2])
+		return fmt.Sprintf("%s%03d%s", m[1], seq, m[3])
+	})
+}
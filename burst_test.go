@@ -0,0 +1,124 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestFindBurstsGroupsFrames(t *testing.T) {
+	dir := "/src/trip"
+	frame := func(name string) string { return filepath.Join(dir, name) }
+	// Listed out of order, as a walk might return them
+	files := []string{
+		frame("IMG_1234_BURST10.jpg"),
+		frame("IMG_5678.jpg"),
+		frame("IMG_1234_BURST2.jpg"),
+		frame("IMG_1234_BURST1_COVER.jpg"),
+		frame("IMG_9999_BURST1.jpg"),
+		frame("IMG_1234_BURST3.jpg"),
+		frame("00001IMG_00001_BURST20181021143005123.jpg"),
+		frame("00000IMG_00000_BURST20181021143005123_COVER.jpg"),
+	}
+	bursts := findBursts(files)
+
+	canon := bursts[frame("IMG_1234_BURST2.jpg")]
+	if canon == nil {
+		t.Fatal("IMG_1234 frames weren't grouped")
+	}
+	wantMembers := []string{frame("IMG_1234_BURST1_COVER.jpg"), frame("IMG_1234_BURST2.jpg"), frame("IMG_1234_BURST3.jpg"), frame("IMG_1234_BURST10.jpg")}
+	if !slices.Equal(canon.members, wantMembers) {
+		t.Errorf("members = %v, want %v", canon.members, wantMembers)
+	}
+	if canon.leader != wantMembers[0] {
+		t.Errorf("leader = %s, want %s", canon.leader, wantMembers[0])
+	}
+	for _, member := range wantMembers {
+		if bursts[member] != canon {
+			t.Errorf("%s isn't in the IMG_1234 burst", member)
+		}
+	}
+
+	pixel := bursts[frame("00001IMG_00001_BURST20181021143005123.jpg")]
+	if pixel == nil || pixel.leader != frame("00000IMG_00000_BURST20181021143005123_COVER.jpg") || len(pixel.members) != 2 {
+		t.Errorf("Pixel burst = %+v, want both frames led by the cover", pixel)
+	}
+
+	// A lone frame and an ordinary photo aren't bursts
+	for _, name := range []string{"IMG_9999_BURST1.jpg", "IMG_5678.jpg"} {
+		if bursts[frame(name)] != nil {
+			t.Errorf("%s was grouped as a burst", name)
+		}
+	}
+	if len(bursts) != 6 {
+		t.Errorf("found %d burst frames, want 6", len(bursts))
+	}
+}
+
+func TestPadBurstSequence(t *testing.T) {
+	tests := []struct{ desc, want string }{
+		{"IMG_1234_BURST1", "IMG_1234_BURST001"},
+		{"IMG_1234_BURST10_COVER", "IMG_1234_BURST010_COVER"},
+		{"IMG_1234-burst7", "IMG_1234-burst007"},
+		{"IMG_1234_BURST1234", "IMG_1234_BURST1234"},
+		{"IMG_1234", "IMG_1234"},
+	}
+	for _, tt := range tests {
+		if got := padBurstSequence(tt.desc); got != tt.want {
+			t.Errorf("padBurstSequence(%q) = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestBurstFramesShareLeaderDateAndSortInOrder(t *testing.T) {
+	srcDir := "/src"
+	frames := []string{"IMG_1234_BURST1_COVER.jpg", "IMG_1234_BURST2.jpg", "IMG_1234_BURST3.jpg", "IMG_1234_BURST9.jpg", "IMG_1234_BURST10.jpg", "IMG_1234_BURST11.jpg"}
+	paths := make([]string, len(frames))
+	for i, name := range frames {
+		paths[i] = filepath.Join(srcDir, name)
+	}
+
+	p := NewPhotoProcessor(&Config{SourceDir: srcDir, GroupBursts: true})
+	p.source = localSource{}
+	// Only the first frame has a date of its own
+	leaderDate := DateInfo{Year: 2018, Month: 10, Day: 21, Time: "14:30:05", HasTime: true}
+	p.dateOverrides = &dateOverrides{byPath: map[string]DateInfo{}, byName: map[string]DateInfo{frames[0]: leaderDate}}
+	p.bursts = findBursts(paths)
+
+	var names []string
+	for _, path := range paths {
+		group := p.bursts[path]
+		if group == nil {
+			t.Fatalf("%s isn't in a burst", path)
+		}
+		date := p.burstDate(group)
+		if date == nil || date.String() != "2018-10-21 14:30:05" {
+			t.Fatalf("burst date for %s = %v, want the leader's 2018-10-21 14:30:05", path, date)
+		}
+		dirPath, name, err := p.ComputeDestination(path, date.withOriginal(filepath.Base(path)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dirPath != filepath.Join("2018", "2018-10") {
+			t.Errorf("%s goes to %s, want 2018/2018-10 with its leader", path, dirPath)
+		}
+		names = append(names, name)
+	}
+
+	want := []string{
+		"2018-10-21_143005_IMG_1234_BURST001_COVER.jpg",
+		"2018-10-21_143005_IMG_1234_BURST002.jpg",
+		"2018-10-21_143005_IMG_1234_BURST003.jpg",
+		"2018-10-21_143005_IMG_1234_BURST009.jpg",
+		"2018-10-21_143005_IMG_1234_BURST010.jpg",
+		"2018-10-21_143005_IMG_1234_BURST011.jpg",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	// The padded names sort in frame order, even as plain strings
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("names don't sort in frame order: %v", names)
+	}
+}
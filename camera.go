@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// cameraAllowed applies the IncludeCameras/ExcludeCameras filters to a photo's EXIF make and model
+func (p *PhotoProcessor) cameraAllowed(metadata *ExifMetadata) bool {
+	if metadata.Make == "" && metadata.Model == "" {
+		return p.config.IncludeUnknownCamera
+	}
+	if len(p.config.IncludeCameras) > 0 && !matchesCamera(p.config.IncludeCameras, metadata.Make, metadata.Model) {
+		return false
+	}
+	return !matchesCamera(p.config.ExcludeCameras, metadata.Make, metadata.Model)
+}
+
+// cameraFilterEnabled reports whether any camera filter is configured
+func (p *PhotoProcessor) cameraFilterEnabled() bool {
+	return len(p.config.IncludeCameras) > 0 || len(p.config.ExcludeCameras) > 0
+}
+
+// matchesCamera reports whether any pattern matches the make, the model or "make model"
+// Patterns are case-insensitive globs if they contain *, ? or [, otherwise substrings
+func matchesCamera(patterns []string, cameraMake, cameraModel string) bool {
+	candidates := []string{
+		strings.ToLower(cameraMake),
+		strings.ToLower(cameraModel),
+		strings.ToLower(strings.TrimSpace(cameraMake + " " + cameraModel)),
+	}
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		isGlob := strings.ContainsAny(pattern, "*?[")
+		for _, candidate := range candidates {
+			if candidate == "" {
+				continue
+			}
+			if isGlob {
+				if ok, _ := path.Match(pattern, candidate); ok {
+					return true
+				}
+			} else if strings.Contains(candidate, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateCameraPatterns checks that glob patterns are well formed
+func validateCameraPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// printCapabilities reports the recognized file types and the external tools and
+// SSH credentials that were detected, without processing any files
+func printCapabilities(w io.Writer) {
+	fmt.Fprintln(w, "File types:")
+	fmt.Fprintf(w, "  Images: %s\n", strings.Join(imageExtensions, " "))
+	fmt.Fprintf(w, "  Videos: %s\n", strings.Join(videoExtensions, " "))
+	fmt.Fprintf(w, "  RAW:    %s (with -include-raw)\n", strings.Join(rawExtensions, " "))
+
+	fmt.Fprintln(w, "Metadata tools:")
+	if path, err := exec.LookPath(exiftoolBinary); err == nil {
+		fmt.Fprintf(w, "  exiftool: available (%s)\n", path)
+	} else {
+		fmt.Fprintln(w, "  exiftool: not found")
+	}
+	switch {
+	case !commandAvailable("docker"):
+		fmt.Fprintln(w, "  Docker:   not found")
+	case exec.Command("docker", "image", "inspect", exiftoolDockerImage).Run() == nil:
+		fmt.Fprintf(w, "  Docker:   available (%s image present)\n", exiftoolDockerImage)
+	default:
+		fmt.Fprintf(w, "  Docker:   available (%s image will be pulled on first use)\n", exiftoolDockerImage)
+	}
+
+	if decoder := findHEICDecoder(); decoder != nil {
+		fmt.Fprintf(w, "  HEIC:     %s\n", decoder.name)
+	} else {
+		fmt.Fprintln(w, "  HEIC:     no decoder found (heif-convert, ImageMagick or sips)")
+	}
+
+	fmt.Fprintln(w, "SSH authentication:")
+	found := false
+	for _, keyPath := range sshKeyPaths() {
+		key, err := os.ReadFile(keyPath)
+		if err != nil {
+			continue
+		}
+		if _, err := ssh.ParsePrivateKey(key); err != nil {
+			fmt.Fprintf(w, "  %s: unusable (%v)\n", keyPath, err)
+			continue
+		}
+		fmt.Fprintf(w, "  %s: public key\n", keyPath)
+		found = true
+	}
+	if !found {
+		fmt.Fprintln(w, "  No usable SSH keys found - remote sources and destinations will fail")
+	}
+}
+
+// commandAvailable reports whether a command is on the PATH
+func commandAvailable(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// compareExifColumns is the header of the EXIF vs filename audit report
+var compareExifColumns = []string{"path", "filename_date", "exif_date", "difference", "make", "model"}
+
+// exifNameDifference returns how far an EXIF capture time is from the date in a file's
+// name, positive when EXIF is later. A name stands for as much as it says: without a
+// time its whole day, with only a month or a year (as in "1999_party" or a "2018" folder)
+// that whole month or year. Any EXIF time within that span is no difference, and others
+// are measured from its edge. Both are compared as wall clocks, since EXIF dates carry
+// no reliable time zone.
+func exifNameDifference(nameDate *DateInfo, exifTime time.Time) time.Duration {
+	exifWall := time.Date(exifTime.Year(), exifTime.Month(), exifTime.Day(), exifTime.Hour(), exifTime.Minute(), exifTime.Second(), 0, time.UTC)
+	start := nameDate.ToTimeWithDefault(0)
+	var end time.Time
+	switch nameDate.Precision {
+	case PrecisionYear:
+		start = time.Date(nameDate.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(1, 0, 0)
+	case PrecisionMonth:
+		start = time.Date(nameDate.Year, time.Month(nameDate.Month), 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+	default:
+		if nameDate.HasTime {
+			return exifWall.Sub(start)
+		}
+		end = start.AddDate(0, 0, 1)
+	}
+	end = end.Add(-time.Second)
+
+	switch {
+	case exifWall.Before(start):
+		return exifWall.Sub(start)
+	case exifWall.After(end):
+		return exifWall.Sub(end)
+	default:
+		return 0
+	}
+}
+
+// nameDateString formats a filename date in the report at the precision the name has
+func nameDateString(d *DateInfo) string {
+	switch d.Precision {
+	case PrecisionYear:
+		return fmt.Sprintf("%04d", d.Year)
+	case PrecisionMonth:
+		return fmt.Sprintf("%04d-%02d", d.Year, d.Month)
+	default:
+		return d.String()
+	}
+}
+
+// formatSignedDuration formats a duration like formatDuration, with a sign
+func formatSignedDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	}
+	return "+" + formatDuration(d)
+}
+
+// CompareExifVsFilename walks the source and writes every media file whose EXIF capture
+// time and filename date differ by more than Config.CompareExifTolerance to
+// Config.CompareExifPath as CSV, without changing anything. Disagreements are also
+// tallied by camera in the log, since a camera with a wrong clock shows up as one
+// camera being off on every file.
+func (p *PhotoProcessor) CompareExifVsFilename() error {
+	p.configureExiftool()
+
+	closeSource, err := p.openSource()
+	if err != nil {
+		return err
+	}
+	defer closeSource()
+
+	files, err := p.source.Walk(p.config.SourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to walk source: %w", err)
+	}
+	mediaFiles := p.selectMediaFiles(p.config.SourceDir, files)
+	naturalSort(mediaFiles)
+	log.Printf("Comparing EXIF and filename dates of %d media files", len(mediaFiles))
+
+	f, err := os.Create(p.config.CompareExifPath)
+	if err != nil {
+		return fmt.Errorf("failed to create comparison report: %w", err)
+	}
+	defer f.Close()
+	out := csv.NewWriter(f)
+	out.Write(compareExifColumns)
+
+	byCamera := make(map[string]int)
+	var compared, disagree, noName, noExif int
+	for _, path := range mediaFiles {
+		nameDate, err := ParseDateFromFilenameWithOptions(path, p.parseOptions())
+		if err != nil {
+			noName++
+			continue
+		}
+
+		metadata, err := p.readSourceExif(path)
+		if err != nil {
+			log.Printf("Warning: failed to read EXIF of %s: %v", path, err)
+			noExif++
+			continue
+		}
+		exifTime, ok := metadata.CaptureTime()
+		if !ok {
+			noExif++
+			continue
+		}
+
+		compared++
+		diff := exifNameDifference(nameDate, exifTime)
+		if diff.Abs() <= p.config.CompareExifTolerance {
+			continue
+		}
+		disagree++
+		camera := strings.TrimSpace(metadata.Make + " " + metadata.Model)
+		if camera == "" {
+			camera = "unknown camera"
+		}
+		byCamera[camera]++
+		out.Write([]string{path, nameDateString(nameDate), exifTime.Format("2006-01-02 15:04:05"), formatSignedDuration(diff), metadata.Make, metadata.Model})
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return fmt.Errorf("failed to write comparison report: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write comparison report: %w", err)
+	}
+
+	log.Printf("Compared %d files with both dates: %d disagree by more than %s; %d without a filename date, %d without an EXIF date",
+		compared, disagree, p.config.CompareExifTolerance, noName, noExif)
+	cameras := make([]string, 0, len(byCamera))
+	for camera := range byCamera {
+		cameras = append(cameras, camera)
+	}
+	sort.Slice(cameras, func(i, j int) bool {
+		if byCamera[cameras[i]] != byCamera[cameras[j]] {
+			return byCamera[cameras[i]] > byCamera[cameras[j]]
+		}
+		return cameras[i] < cameras[j]
+	})
+	for _, camera := range cameras {
+		log.Printf("  %s: %d disagreeing", camera, byCamera[camera])
+	}
+	log.Printf("Wrote %d disagreements to %s", disagree, p.config.CompareExifPath)
+	return nil
+}
+
+// readSourceExif reads a source file's EXIF for the inventory and the audit
+func (p *PhotoProcessor) readSourceExif(path string) (*ExifMetadata, error) {
+	src := &fetchedFile{source: p.source, path: path}
+	if p.sshClient != nil {
+		// Only the metadata is needed, so don't download whole files
+		src.headBytes = directStreamHeadBytes
+	}
+	defer src.Close()
+
+	localPath, err := src.MetadataPath()
+	if err != nil {
+		return nil, err
+	}
+	return ReadExifData(localPath)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExifNameDifferenceAtNamePrecision(t *testing.T) {
+	midYear := time.Date(1999, 7, 14, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		exif time.Time
+		want time.Duration
+	}{
+		// A year-only name or folder covers the whole year
+		{"/photos/1999_party.jpg", midYear, 0},
+		{"/photos/1999/IMG_1234.jpg", midYear, 0},
+		{"/photos/1999_party.jpg", time.Date(2000, 1, 1, 1, 0, 0, 0, time.UTC), time.Hour + time.Second},
+		{"/photos/1998_party.jpg", midYear, 194*24*time.Hour + 15*time.Hour + 30*time.Minute + time.Second},
+		// A month covers the whole month
+		{"/photos/1999_07_beach.jpg", midYear, 0},
+		{"/photos/1999_06_beach.jpg", midYear, 13*24*time.Hour + 15*time.Hour + 30*time.Minute + time.Second},
+		// A full date covers only its day
+		{"/photos/1999-07-13_beach.jpg", midYear, 15*time.Hour + 30*time.Minute + time.Second},
+		{"/photos/1999-07-14_beach.jpg", midYear, 0},
+	}
+	for _, tt := range tests {
+		nameDate, err := ParseDateFromFilename(tt.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := exifNameDifference(nameDate, tt.exif); got != tt.want {
+			t.Errorf("%s vs %s: difference = %s, want %s", tt.name, tt.exif.Format(time.DateTime), got, tt.want)
+		}
+	}
+}
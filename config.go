@@ -1,5 +1,15 @@
 package main
 
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
 // Config holds the application configuration
 type Config struct {
 	SourceDir    string
@@ -10,7 +20,342 @@ type Config struct {
 	Verbose      bool
 	RemoteDest   bool   // Whether destination is on remote server
 	SkipExisting bool   // Skip files that already exist at destination
-	Workers      int    // Number of concurrent workers
+	Workers      int    // Number of concurrent workers (WorkersAuto picks one for the source and destination)
 	TestDir      string // Optional: specific subdirectory under SourceDir to process
 	FixMetadata  bool   // Fix metadata mode: restore original EXIF timestamps instead of copying files
+
+	ProcessOrder string // Order files are handed to workers: sorted (default, natural sort of paths) or asfound (walk order)
+
+	SSHKeepalive time.Duration // Interval between SSH keepalive requests (0 disables)
+
+	SSHPort     int // SSH port for the source, overriding any port in SSHHost (0 uses the host's, or 22)
+	DestSSHPort int // SSH port for the destination, overriding any port in DestSSHHost (0 uses the host's, or 22)
+
+	ParallelWalks int // Walk remote sources with this many concurrent find commands, one per top-level subdirectory (0 or 1 runs a single find)
+
+	DirectRemoteStream bool // Remote to remote: pipe each file from the source host straight into the destination host and update its dates with the destination's exiftool, instead of copying it through a local temp file
+
+	MinFileSize int64 // Skip media files smaller than this many bytes, such as tiny thumbnails (0 disables)
+
+	Limit int // Process only the first this many media files, after filtering and sorting (0 processes all)
+
+	DryRunSamples   int    // In dry-run mode, write this many renamed, EXIF-updated sample copies for inspection
+	DryRunSampleDir string // Directory for dry-run samples (defaults to a new temporary directory)
+
+	DestLayout string // Destination folder layout: year, year-month (default), year-month-day or flat
+
+	WordSeparator string // Separator between date, time and description words in filenames (defaults to "_")
+
+	StripDatePrefix bool // Remove a leading copy of the parsed date from descriptions (the -strip-date-prefix flag defaults to on)
+
+	NormalizeWhitespace bool // Collapse runs of spaces, tabs, underscores and separators in descriptions into one separator
+
+	DirMode  os.FileMode // Mode for created destination directories (defaults to 0755)
+	FileMode os.FileMode // Mode for written destination files (0 keeps the default from file creation)
+
+	PreservePermissions bool // Give written files their source file's mode, and its owner on local destinations where permitted (needs root)
+
+	DefaultTimeOfDay time.Duration // Time of day (offset from midnight) given to date-only files without EXIF; 0 keeps midnight so real EXIF times sort after
+
+	PreferEarliestYear bool   // When a name contains several years, use the earliest (e.g. capture year over scan year)
+	DateOrder          string // How DD-MM-YYYY/MM-DD-YYYY names are read when ambiguous: dmy, mdy or ymd (year-first only); empty accepts only unambiguous ones
+
+	DateFromFolderOnly bool // Date every file from its parent folder's path alone, ignoring its name, Takeout metadata and PreferExif (date overrides still apply)
+
+	InteractiveUnknown bool // Prompt on the terminal for the date of each file nothing else dates, instead of sending it straight to unknown/
+
+	RangeFolderDate string // Date files that nothing else dates from a year-range folder above them (e.g. 2010-2019): start or midpoint of the range (empty sends them to unknown/)
+
+	PreferExif bool // Date files by their EXIF capture time whenever it is present and plausible, ignoring the filename date
+
+	DateOverrideCSV string // CSV of "<file name or path>,<date>" rows whose dates replace the filename and EXIF dates (empty disables)
+
+	TagProcessed bool // Tag written files with XMP-pmeta:ProcessedBy and skip destination files already bearing it
+
+	ExiftoolTimeout     time.Duration // Kill an exiftool run that takes longer than this and count the file as an error (0 waits forever)
+	ExiftoolDockerImage string        // Docker image used when exiftool isn't installed (defaults to exiftool/exiftool; pin a tag for reproducibility)
+	ExiftoolPath        string        // exiftool binary to run, for installs outside PATH (empty looks up "exiftool" in PATH)
+
+	VerifyExifWrite bool // Re-read the date after each metadata write and count mismatches as failures
+
+	// Performance investigation
+	CPUProfile string // Write a pprof CPU profile of Process here (empty disables)
+	MemProfile string // Write a pprof heap profile here when Process finishes (empty disables)
+	TracePath  string // Write a runtime execution trace of Process here, for go tool trace (empty disables)
+
+	VerifyUpload        bool // Hash each file uploaded to a remote destination on the remote host (HashAlgo) and compare it with the local copy (or, when streaming host to host, the source file)
+	VerifyUploadRetries int  // Re-uploads after a verification mismatch before the file counts as an error
+
+	SetFileModifyDate bool // Set each written or fixed file's modification time to the photo date (not supported for S3)
+
+	// S3-compatible object storage destination (used instead of DestDir's filesystem when S3Bucket is set)
+	S3Endpoint  string // Service URL, e.g. https://s3.us-west-002.backblazeb2.com (defaults to AWS for S3Region)
+	S3Bucket    string // Destination bucket; DestDir becomes the key prefix
+	S3Region    string // Region used for request signing
+	S3AccessKey string
+	S3SecretKey string
+
+	WriteFolderIndex bool   // Write a JSON index of the files placed in each destination folder
+	FolderIndexName  string // File name of the per-folder index (defaults to index.json)
+
+	ReportPath   string // Write a per-file result report here (empty disables)
+	ReportFormat string // Report format: json (default, a single array), ndjson (one object per line) or csv
+
+	TreePlanPath string // Write the planned (or, outside dry-run, actual) destination hierarchy here as nested JSON (empty disables)
+
+	SummaryJSONPath string // Write the final statistics here as JSON at the end of the run (empty disables)
+
+	NeedsMetadataPath string // Write the destination files left without their date in their metadata here, one per line (empty disables)
+
+	HashAlgo string // Content hash for verification and dedup: sha256 (default), md5, xxhash or blake3
+
+	IncludeRaw bool // Also process camera RAW files (NEF, CR2, ARW, DNG, ...), dated from their embedded EXIF
+
+	DetectByContent bool // Identify media by the file's leading bytes instead of trusting its extension
+
+	ProcessArchives bool // Process the files inside .zip archives found in the source, streaming each entry out
+
+	SkipHidden bool     // Skip dotfiles, macOS "._" AppleDouble files and anything in dot-directories (the -skip-hidden flag defaults to on)
+	JunkDirs   []string // Directory names pruned while walking; nil uses DefaultJunkDirs, an empty slice prunes nothing
+
+	ExifDateTags []string // EXIF date tags consulted for a photo's capture time, first plausible one wins; nil uses DefaultExifDateTags
+
+	GroupBursts bool // Give every frame of a burst (IMG_1234_BURST001.jpg, ...) its first frame's date and folder, with zero-padded frame numbers
+
+	SkipDerivedPreviews bool // Leave out thumbnails (.thumbnails dirs, *_thumb/*_preview names) and JPEGs exported next to a same-named HEIC/HEIF original
+
+	// Camera filters, matched case-insensitively against EXIF make, model or "make model"
+	// (substrings, or globs when the pattern contains *, ? or [)
+	IncludeCameras       []string // Only process photos from matching cameras (empty allows all)
+	ExcludeCameras       []string // Never process photos from matching cameras
+	IncludeUnknownCamera bool     // Process photos without EXIF make/model when camera filters are set
+
+	ConvertHEICtoJPG bool // Transcode HEIC/HEIF to JPEG at the destination (needs heif-convert, ImageMagick or sips)
+
+	MoveSidecars bool // Copy XMP and iPhone AAE sidecars alongside their media under the matching new name
+
+	WalkCachePath string        // File caching the source enumeration so restarts skip the walk (empty disables)
+	WalkCacheTTL  time.Duration // How long a cached enumeration is reused (0 means until the cache file is deleted)
+
+	DedupeReport       bool   // Instead of processing, report duplicate files already in DestDir (nothing is deleted)
+	DedupeReportFormat string // Dedupe report output: "text" (default) or "tsv" for scripts
+
+	DestExistsAction string // When the destination name is taken: overwrite (default), skip, or merge (skip identical content, otherwise add a _N suffix)
+
+	ContactSheets bool // Write a contact sheet of embedded EXIF thumbnails to each destination folder that received files
+
+	ExiftoolBatchSize int // Date non-JPEG files with one exiftool run for up to this many files that workers are writing at once (0 runs exiftool per file)
+
+	SourceManifest string // Text file listing the source files to process, one path per line, instead of walking the source (empty walks)
+
+	DefaultClockDates []string // Days (YYYY-MM-DD) whose EXIF dates come from a reset camera clock and are ignored (nil uses DefaultClockDates, empty ignores none)
+
+	MaxPathLength int // In dry-run mode, warn about destination paths longer than this many characters (0 disables)
+
+	PauseFile string // While this file exists, no new files are started; files in progress finish (empty disables)
+
+	ExifWriteMinConfidence string // Only write a date to a file's metadata when its confidence is at least this: low, medium or high (empty always writes)
+
+	SeparateScreenshots bool   // File screenshots (by name, or PNGs in a Screenshots folder) under ScreenshotsDir/YYYY instead of the normal layout
+	ScreenshotsDir      string // Destination subtree for screenshots, relative to the destination (empty uses DefaultScreenshotsDir)
+
+	RelinkExisting bool // Instead of processing, write the date in each standardized DestDir file's name into its metadata in place
+
+	DedupKeep string // Process only one of each set of identical source files, chosen by first-seen, largest, newest-mtime or shortest-name (empty processes all)
+
+	Histogram       string // Instead of processing, print how many source files fall in each year, month or day ("" disables)
+	HistogramFormat string // Histogram output: text (default) or csv
+
+	DateConflictPrefer string // When a file's name and folder give different years: filename (default) or folder; either way the conflict is logged and reported
+
+	ExifOffset string // UTC offset (+HH:MM) written to the OffsetTime tags with each date, without shifting the time (empty writes none)
+
+	RetryErroredAtEnd bool // After the main pass, reconnect SSH and process the files that errored once more, taking any that succeed out of the error count
+
+	IncludePDF   bool // Also organize PDFs (e.g. scanned album pages) by the date in their name; their metadata is left alone
+	PDFWriteDate bool // Write each PDF's date into its CreationDate and ModDate with exiftool
+
+	CompareExifPath      string        // Instead of processing, write each source file whose EXIF and filename dates disagree here as CSV
+	CompareExifTolerance time.Duration // Differences up to this aren't reported; a name without a time matches its whole day
+
+	WriteMetadataSidecar bool // Write <file>.json next to each organized file with its source, derived date, confidence, date source, camera and GPS
+
+	ForceReprocess bool // Process files that already have their standardized name and folder instead of skipping them
+
+	InventoryPath    string // Instead of processing, write each source media file's dates, camera and GPS here
+	InventoryFormat  string // Inventory format: json (default), ndjson or csv
+	InventoryWorkers int    // Files whose metadata is read at once for the inventory (0 uses Workers)
+
+	GeocodeDB string // Offline city database for appending GPS-derived place names to month folders (empty disables)
+
+	TransferProgressInterval time.Duration // How often to report progress of a long upload/download (0 disables)
+
+	ProgressEveryN   int           // Log progress every this many files (0 uses DefaultProgressEveryN, negative only logs on ProgressInterval)
+	ProgressInterval time.Duration // Log progress at least this often (0 uses DefaultProgressInterval, negative only logs on ProgressEveryN)
+
+	// ProgressCallback, when set, receives progress snapshots instead of the console progress log
+	ProgressCallback func(ProcessStats)
+}
+
+// Validate checks the configuration for invalid values
+func (c *Config) Validate() error {
+	if c.DirMode&^os.ModePerm != 0 || c.FileMode&^os.ModePerm != 0 {
+		return fmt.Errorf("directory and file modes must be permission bits only (e.g. 0775, 0664)")
+	}
+	if c.PreservePermissions && c.S3Bucket != "" {
+		return fmt.Errorf("preserving permissions is not supported for S3 destinations")
+	}
+	if c.PreservePermissions && c.FileMode != 0 {
+		return fmt.Errorf("preserving permissions and a fixed file mode are mutually exclusive")
+	}
+	if c.SetFileModifyDate && c.S3Bucket != "" {
+		return fmt.Errorf("setting file modification dates is not supported for S3 destinations")
+	}
+	switch c.DedupeReportFormat {
+	case "", DedupeFormatText, DedupeFormatTSV:
+	default:
+		return fmt.Errorf("unsupported dedupe report format %q (use %s or %s)", c.DedupeReportFormat, DedupeFormatText, DedupeFormatTSV)
+	}
+	for _, patterns := range [][]string{c.IncludeCameras, c.ExcludeCameras} {
+		if err := validateCameraPatterns(patterns); err != nil {
+			return fmt.Errorf("invalid camera pattern: %w", err)
+		}
+	}
+	if _, err := newHasher(c.HashAlgo); err != nil {
+		return err
+	}
+	if c.FolderIndexName != "" && (strings.ContainsAny(c.FolderIndexName, `/\`) || c.FolderIndexName == "." || c.FolderIndexName == "..") {
+		return fmt.Errorf("folder index name must be a plain file name, got %q", c.FolderIndexName)
+	}
+	if err := validateReportFormat(c.ReportFormat); err != nil {
+		return err
+	}
+	if err := validateReportFormat(c.InventoryFormat); err != nil {
+		return fmt.Errorf("inventory: %w", err)
+	}
+	if c.InventoryWorkers < 0 {
+		return fmt.Errorf("inventory workers must not be negative")
+	}
+	if err := validateProcessOrder(c.ProcessOrder); err != nil {
+		return err
+	}
+	if err := validateLayout(c.DestLayout); err != nil {
+		return err
+	}
+	if c.VerifyUpload && !c.RemoteDest {
+		return fmt.Errorf("upload verification requires a remote destination")
+	}
+	if c.VerifyUploadRetries < 0 {
+		return fmt.Errorf("upload verification retries must not be negative")
+	}
+	if c.ExiftoolTimeout < 0 {
+		return fmt.Errorf("exiftool timeout must not be negative")
+	}
+	if c.ExifDateTags != nil {
+		if _, err := canonicalExifDateTags(c.ExifDateTags); err != nil {
+			return err
+		}
+		if len(c.ExifDateTags) == 0 {
+			return fmt.Errorf("at least one EXIF date tag is required")
+		}
+	}
+	if c.MinFileSize < 0 {
+		return fmt.Errorf("minimum file size must not be negative")
+	}
+	if c.DateFromFolderOnly && c.PreferExif {
+		return fmt.Errorf("dating from the folder only and preferring EXIF dates are mutually exclusive")
+	}
+	if err := validateRangeFolderDate(c.RangeFolderDate); err != nil {
+		return err
+	}
+	if c.Limit < 0 {
+		return fmt.Errorf("file limit must not be negative")
+	}
+	for _, port := range []int{c.SSHPort, c.DestSSHPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("SSH port %d is out of range", port)
+		}
+	}
+	if err := validateDateOrder(c.DateOrder); err != nil {
+		return err
+	}
+	if err := validateDestExistsAction(c.DestExistsAction); err != nil {
+		return err
+	}
+	if c.DestExistsAction == DestExistsMerge && (c.SkipExisting || c.DirectRemoteStream) {
+		return fmt.Errorf("merging into the destination can't be combined with skipping existing files or direct remote streaming")
+	}
+	if c.ExiftoolBatchSize < 0 {
+		return fmt.Errorf("exiftool batch size must not be negative")
+	}
+	if c.SourceManifest != "" && (c.ProcessArchives || c.WalkCachePath != "") {
+		return fmt.Errorf("a source manifest replaces the walk, so it can't be combined with archive processing or a walk cache")
+	}
+	if c.MaxPathLength < 0 {
+		return fmt.Errorf("maximum path length must not be negative")
+	}
+	if err := validateConfidence(c.ExifWriteMinConfidence); err != nil {
+		return err
+	}
+	if err := validateScreenshotsDir(c.ScreenshotsDir); err != nil {
+		return err
+	}
+	if err := validateDateConflictPrefer(c.DateConflictPrefer); err != nil {
+		return err
+	}
+	if err := validateHistogram(c.Histogram, c.HistogramFormat); err != nil {
+		return err
+	}
+	if err := validateExifOffset(c.ExifOffset); err != nil {
+		return err
+	}
+	if err := validateDedupKeep(c.DedupKeep); err != nil {
+		return err
+	}
+	if c.CompareExifTolerance < 0 {
+		return fmt.Errorf("EXIF comparison tolerance must not be negative")
+	}
+	if c.PDFWriteDate && !c.IncludePDF {
+		return fmt.Errorf("writing PDF dates requires -include-pdf")
+	}
+	if c.ExiftoolPath != "" {
+		if _, err := exec.LookPath(c.ExiftoolPath); err != nil {
+			return fmt.Errorf("invalid exiftool path: %w", err)
+		}
+	}
+	if err := validateClockDates(c.DefaultClockDates); err != nil {
+		return err
+	}
+	if c.ParallelWalks < 0 {
+		return fmt.Errorf("parallel walks must not be negative")
+	}
+	if c.DirectRemoteStream {
+		if c.SSHHost == "" || !c.RemoteDest {
+			return fmt.Errorf("direct remote streaming requires a remote source and a remote destination")
+		}
+		if c.ConvertHEICtoJPG || c.TagProcessed || c.VerifyExifWrite || c.DryRunSamples > 0 {
+			return fmt.Errorf("direct remote streaming can't be combined with HEIC conversion, processed tags, EXIF write verification or dry-run samples")
+		}
+	}
+	if c.DefaultTimeOfDay < 0 || c.DefaultTimeOfDay >= 24*time.Hour {
+		return fmt.Errorf("default time of day must be between 00:00:00 and 23:59:59")
+	}
+	if c.WordSeparator != "" {
+		if err := validateSeparator(c.WordSeparator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSeparator ensures a word separator is a single filesystem-safe character
+func validateSeparator(sep string) error {
+	if utf8.RuneCountInString(sep) != 1 {
+		return fmt.Errorf("word separator must be a single character, got %q", sep)
+	}
+	r, _ := utf8.DecodeRuneInString(sep)
+	if !unicode.IsPrint(r) || unicode.IsSpace(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+		return fmt.Errorf("word separator %q is not safe for filenames", sep)
+	}
+	return nil
 }
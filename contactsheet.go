@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// ContactSheetName is the file name of the contact sheet written to each destination folder
+const ContactSheetName = "contact-sheet.jpg"
+
+// Contact sheet geometry, in pixels
+const (
+	contactSheetColumns = 8
+	contactCellWidth    = 160
+	contactCellHeight   = 120
+	contactCellPadding  = 4
+)
+
+// contactSheetBackground fills the sheet around the thumbnails
+var contactSheetBackground = color.RGBA{R: 32, G: 32, B: 32, A: 255}
+
+// contactEntry is one thumbnail for a folder's contact sheet
+type contactEntry struct {
+	name      string // Destination file name, for ordering
+	thumbnail []byte // Embedded JPEG thumbnail
+}
+
+// readThumbnail returns the JPEG thumbnail embedded in a file's EXIF, asking exiftool
+// for formats goexif can't read (HEIC, RAW, video)
+func readThumbnail(path string) ([]byte, error) {
+	if f, err := os.Open(path); err == nil {
+		x, decodeErr := exif.Decode(f)
+		f.Close()
+		if decodeErr == nil {
+			if thumb, err := x.JpegThumbnail(); err == nil && len(thumb) > 0 {
+				return thumb, nil
+			}
+		}
+	}
+
+	if !checkExiftoolAvailable() {
+		return nil, fmt.Errorf("no embedded thumbnail")
+	}
+	thumb, err := runExiftool(exiftoolBinary, "-b", "-ThumbnailImage", path)
+	if err != nil {
+		return nil, err
+	}
+	if len(thumb) == 0 {
+		return nil, fmt.Errorf("no embedded thumbnail")
+	}
+	return thumb, nil
+}
+
+// addToContactSheet records the thumbnail of a file written to destPath for its folder's
+// contact sheet; files without one are left off the sheet
+func (p *PhotoProcessor) addToContactSheet(localPath, destPath string) {
+	thumb, err := readThumbnail(localPath)
+	if err != nil {
+		if p.config.Verbose {
+			log.Printf("No thumbnail for contact sheet: %s: %v", destPath, err)
+		}
+		return
+	}
+
+	p.contactSheetMutex.Lock()
+	defer p.contactSheetMutex.Unlock()
+	dir := filepath.Dir(destPath)
+	p.contactSheets[dir] = append(p.contactSheets[dir], contactEntry{name: filepath.Base(destPath), thumbnail: thumb})
+}
+
+// writeContactSheets writes the contact sheet of every folder that received files with thumbnails
+func (p *PhotoProcessor) writeContactSheets() {
+	dirs := make([]string, 0, len(p.contactSheets))
+	for dir := range p.contactSheets {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		sheetPath := filepath.Join(dir, ContactSheetName)
+		if err := p.writeContactSheet(sheetPath, p.contactSheets[dir]); err != nil {
+			log.Printf("Warning: failed to write contact sheet %s: %v", sheetPath, err)
+		} else if p.config.Verbose {
+			log.Printf("Wrote contact sheet: %s (%d thumbnails)", sheetPath, len(p.contactSheets[dir]))
+		}
+	}
+}
+
+// writeContactSheet lays the thumbnails out in a grid, in file name order, and stores it at sheetPath
+func (p *PhotoProcessor) writeContactSheet(sheetPath string, entries []contactEntry) error {
+	sort.Slice(entries, func(i, j int) bool { return naturalLess(entries[i].name, entries[j].name) })
+
+	var thumbs []image.Image
+	for _, entry := range entries {
+		img, err := jpeg.Decode(bytes.NewReader(entry.thumbnail))
+		if err != nil {
+			log.Printf("Warning: unreadable thumbnail of %s: %v", entry.name, err)
+			continue
+		}
+		thumbs = append(thumbs, img)
+	}
+	if len(thumbs) == 0 {
+		return nil
+	}
+
+	columns := min(len(thumbs), contactSheetColumns)
+	rows := (len(thumbs) + columns - 1) / columns
+	cellW, cellH := contactCellWidth+contactCellPadding, contactCellHeight+contactCellPadding
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*cellW+contactCellPadding, rows*cellH+contactCellPadding))
+	draw.Draw(sheet, sheet.Bounds(), &image.Uniform{C: contactSheetBackground}, image.Point{}, draw.Src)
+
+	for i, thumb := range thumbs {
+		cell := image.Rect(0, 0, contactCellWidth, contactCellHeight).
+			Add(image.Pt(contactCellPadding+(i%columns)*cellW, contactCellPadding+(i/columns)*cellH))
+		drawScaled(sheet, cell, thumb)
+	}
+
+	tempFile, err := os.CreateTemp("", "photo-contact-*.jpg")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for contact sheet: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	err = jpeg.Encode(tempFile, sheet, &jpeg.Options{Quality: 85})
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temp contact sheet: %w", err)
+	}
+
+	return p.dest.Write(tempFile.Name(), sheetPath)
+}
+
+// drawScaled draws src centred in cell, scaled to fit it with its aspect ratio kept.
+// Nearest-neighbour sampling is plenty for thumbnails this small.
+func drawScaled(dst draw.Image, cell image.Rectangle, src image.Image) {
+	b := src.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return
+	}
+	w, h := cell.Dx(), b.Dy()*cell.Dx()/b.Dx()
+	if h > cell.Dy() {
+		w, h = b.Dx()*cell.Dy()/b.Dy(), cell.Dy()
+	}
+	origin := cell.Min.Add(image.Pt((cell.Dx()-w)/2, (cell.Dy()-h)/2))
+
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			dst.Set(origin.X+x, origin.Y+y, src.At(b.Min.X+x*b.Dx()/w, sy))
+		}
+	}
+}
+
+// isContactSheet reports whether path is a contact sheet written by a previous run
+func isContactSheet(path string) bool {
+	return strings.EqualFold(filepath.Base(path), ContactSheetName)
+}
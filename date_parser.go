@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // DateInfo represents extracted date information from a filename
@@ -15,12 +16,55 @@ type DateInfo struct {
 	Month    int
 	Day      int
 	Time     string // HH:MM:SS format, if available
+	HasTime  bool   // Whether Time was found in the name (rather than defaulted)
+	Offset   string // UTC offset from the filename (e.g. "+0200", "-05:00", "Z"), if available
 	Original string // Original filename
+
+	Source DateSource // Where the date came from ("" when not recorded, e.g. parsed outside a run)
+
+	Precision DatePrecision // How much of the date was found; Month and Day beyond it are defaulted to 1
+}
+
+// DatePrecision says how much of a date was found, from a whole day down to just a year
+type DatePrecision int
+
+const (
+	PrecisionDay   DatePrecision = iota // Year, month and day were all found
+	PrecisionMonth                      // Only the year and month were found
+	PrecisionYear                       // Only the year was found
+)
+
+// DateSource says where a file's date, and so its folder and name, came from
+type DateSource string
+
+// Date sources recorded in DateInfo.Source and the per-file report
+const (
+	DateSourceOverride DateSource = "override" // The date override CSV
+	DateSourceExif     DateSource = "exif"     // The EXIF capture time, with PreferExif
+	DateSourceTakeout  DateSource = "takeout"  // A Google Takeout metadata file
+	DateSourceFilename DateSource = "filename" // The file's own name
+	DateSourceFolder   DateSource = "folder"   // A folder above the file
+	DateSourceRange    DateSource = "range"    // A year-range folder (RangeFolderDate), low confidence
+	DateSourceBurst    DateSource = "burst"    // The first frame of the file's burst
+	DateSourceManual   DateSource = "manual"   // Entered at the interactive prompt
+)
+
+// NameOptions controls how standardized filenames are assembled
+type NameOptions struct {
+	Separator       string // Joins the date, time and description, and replaces spaces in descriptions
+	StripDatePrefix bool   // Remove a leading copy of the parsed date from descriptions
+
+	NormalizeWhitespace bool // Collapse runs of whitespace, underscores and separators in descriptions into one separator
+}
+
+// DefaultNameOptions returns the naming options used when none are configured
+func DefaultNameOptions() NameOptions {
+	return NameOptions{Separator: "_", StripDatePrefix: true}
 }
 
 // ExtractDirectoryContext extracts meaningful directory names from a path
-// and returns them concatenated with underscores, cleaned of dates and special chars
-func ExtractDirectoryContext(fullPath, sourceRoot string) string {
+// and returns them concatenated with the separator, cleaned of dates and special chars
+func ExtractDirectoryContext(fullPath, sourceRoot, sep string) string {
 	// Normalize paths - remove trailing slashes
 	sourceRoot = strings.TrimRight(sourceRoot, "/")
 	fullPath = strings.TrimRight(fullPath, "/")
@@ -47,17 +91,25 @@ func ExtractDirectoryContext(fullPath, sourceRoot string) string {
 		}
 
 		// Clean the directory name
-		cleaned := cleanDirectoryName(part)
+		cleaned := cleanDirectoryName(part, sep)
 		if cleaned != "" {
 			contextParts = append(contextParts, cleaned)
 		}
 	}
 
-	return strings.Join(contextParts, "_")
+	return strings.Join(contextParts, sep)
 }
 
-// cleanDirectoryName removes date patterns and cleans up a directory name
-func cleanDirectoryName(dir string) string {
+// cleanDirectoryName removes date patterns and cleans up a directory name,
+// joining the remaining words with sep
+func cleanDirectoryName(dir, sep string) string {
+	// Archives listed as directories (-process-archives) contribute only their name
+	dir = regexp.MustCompile(`(?i)\.zip$`).ReplaceAllString(dir, "")
+
+	// Remove decade ranges like "2010-2019", "1980-1989" (anywhere in the string)
+	// before the start-of-name patterns below, which would leave a bare "2019" behind
+	dir = regexp.MustCompile(`\d{4}-\d{4}`).ReplaceAllString(dir, "")
+
 	// Remove common date patterns at the start
 	dir = regexp.MustCompile(`^\d{4}[-_]\d{2}[-_]\d{2}`).ReplaceAllString(dir, "") // YYYY-MM-DD or YYYY_MM_DD
 	dir = regexp.MustCompile(`^\d{8}`).ReplaceAllString(dir, "")                   // YYYYMMDD
@@ -65,9 +117,6 @@ func cleanDirectoryName(dir string) string {
 	dir = regexp.MustCompile(`^\d{4}[-_]`).ReplaceAllString(dir, "")               // YYYY_ or YYYY-
 	dir = regexp.MustCompile(`^\d{4}\s+`).ReplaceAllString(dir, "")                // YYYY followed by space
 
-	// Remove decade ranges like "2010-2019", "1980-1989" (anywhere in the string)
-	dir = regexp.MustCompile(`\d{4}-\d{4}`).ReplaceAllString(dir, "")
-
 	// Remove "and before" or similar suffix patterns
 	dir = regexp.MustCompile(`\s+and\s+(before|after)$`).ReplaceAllString(dir, "")
 
@@ -97,6 +146,10 @@ func cleanDirectoryName(dir string) string {
 		}
 	}
 
+	if sep != "_" {
+		dir = strings.ReplaceAll(dir, "_", sep)
+	}
+
 	return dir
 }
 
@@ -106,13 +159,79 @@ func cleanDirectoryName(dir string) string {
 // - YYYY_MM_DD_description.jpg
 // - YYYY-MM-DD description.jpg (with hyphens)
 // - YYYY-MM-DD HH.MM.SS.jpg (with time)
+// - Screenshot_YYYY-MM-DD-HH-MM-SS[-mmm]_app.png (dash-delimited time, optional milliseconds)
+// - Photo Mon DD, YYYY, H MM SS AM.jpg (12-hour time)
 // - YYYY_description.jpg
 // - YYMMDD_description.jpg (for years 19XX or 20XX)
-// - YYMM_description.jpg (for years 19XX or 20XX, defaults to 1st of month)
+// - DD-MM-YYYY / MM-DD-YYYY description.jpg (when unambiguous, or per ParseOptions.DateOrder)
+// - YYMM_description.jpg (for years 19XX or 20XX, defaults to 1st of month; needs a separator)
+// - "... from YYYY ..." / "taken YYYY" / "shot YYYY" (capture year in scans, wins over other dates)
 // Also checks parent directory names for date patterns
 func ParseDateFromFilename(filename string) (*DateInfo, error) {
+	return ParseDateFromFilenameWithOptions(filename, ParseOptions{})
+}
+
+// ParseOptions tunes how dates are extracted from filenames
+type ParseOptions struct {
+	// PreferEarliestYear uses the earliest plausible year in a name when it contains several
+	// (e.g. a scan date followed by the capture year)
+	PreferEarliestYear bool
+
+	// DateOrder says how day-first or month-first numeric dates (DD-MM-YYYY, MM-DD-YYYY)
+	// are read when both numbers could be the month. Empty only accepts unambiguous ones.
+	DateOrder string
+}
+
+// Date orders for numeric dates that don't start with the year
+const (
+	DateOrderDMY = "dmy" // Day first (21-10-2018), as in most of Europe
+	DateOrderMDY = "mdy" // Month first (10-21-2018), as in the US
+	DateOrderYMD = "ymd" // Year-first dates only; DD-MM-YYYY and MM-DD-YYYY aren't recognized
+)
+
+// validateDateOrder checks a date order name ("" auto-detects unambiguous dates)
+func validateDateOrder(order string) error {
+	switch order {
+	case "", DateOrderDMY, DateOrderMDY, DateOrderYMD:
+		return nil
+	default:
+		return fmt.Errorf("unsupported date order %q (use %s, %s or %s)", order, DateOrderDMY, DateOrderMDY, DateOrderYMD)
+	}
+}
+
+// dayMonth splits the first two numbers of a DD-MM-YYYY or MM-DD-YYYY date into day and
+// month. A number above 12 can only be the day, which settles the order, as does the same
+// number twice; otherwise the configured order decides, and without one the date is
+// rejected as ambiguous.
+func dayMonth(first, second int, order string) (day, month int, err error) {
+	switch {
+	case order == DateOrderYMD:
+		return 0, 0, fmt.Errorf("day- or month-first dates are disabled")
+	case first == second:
+		// 05-05 reads the same either way
+		return first, second, nil
+	case first > 12 && second <= 12:
+		return first, second, nil
+	case second > 12 && first <= 12:
+		return second, first, nil
+	case first > 12 || second > 12:
+		return 0, 0, fmt.Errorf("no month in %02d-%02d", first, second)
+	case order == DateOrderDMY:
+		return first, second, nil
+	case order == DateOrderMDY:
+		return second, first, nil
+	default:
+		return 0, 0, fmt.Errorf("%02d-%02d is ambiguous without a date order", first, second)
+	}
+}
+
+// yearTokenRegex matches standalone plausible years for PreferEarliestYear
+var yearTokenRegex = regexp.MustCompile(`(?:^|\D)((?:18|19|20)\d{2})(?:\D|$)`)
+
+// ParseDateFromFilenameWithOptions is ParseDateFromFilename with configurable heuristics
+func ParseDateFromFilenameWithOptions(filename string, opts ParseOptions) (*DateInfo, error) {
 	base := filepath.Base(filename)
-	name := strings.TrimSuffix(base, filepath.Ext(base))
+	name := strings.TrimSuffix(base, fileExt(base))
 
 	// Also check the full path (parent directories may contain dates)
 	fullPath := filename
@@ -123,6 +242,25 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 		regex   *regexp.Regexp
 		extract func([]string) (*DateInfo, error)
 	}{
+		{
+			// Capture-year phrases in scanned photos (e.g. "scanned-2021 from 1985 trip", "taken_1970")
+			// The phrase names the real capture date, so it beats any scan date elsewhere in the name.
+			// A full date after the keyword ("Screen Shot 2018-10-21 at ...", "photos from
+			// 2018-10-21") is left to the full-date patterns below, which keep its day and time.
+			regexp.MustCompile(`(?i)(?:^|[^a-z])(?:from|taken|shot)[\s_-]+(?:in[\s_-]+)?(\d{4})(?:[-_.](\d{2})(?:[-_.](\d{2}))?)?(?:\D|$)`),
+			func(matches []string) (*DateInfo, error) {
+				if matches[3] != "" {
+					return nil, fmt.Errorf("%s is a full date", matches[0])
+				}
+				year, _ := strconv.Atoi(matches[1])
+				month, precision := 1, PrecisionYear
+				if matches[2] != "" {
+					month, _ = strconv.Atoi(matches[2])
+					precision = PrecisionMonth
+				}
+				return &DateInfo{Year: year, Month: month, Day: 1, Original: base, Precision: precision}, nil
+			},
+		},
 		{
 			// YYYY-MM-DD HH.MM.SS format (with time, spaces, hyphens, and periods)
 			regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})\s+(\d{2})\.(\d{2})\.(\d{2})`),
@@ -134,7 +272,64 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 				minute, _ := strconv.Atoi(matches[5])
 				second, _ := strconv.Atoi(matches[6])
 				timeStr := fmt.Sprintf("%02d:%02d:%02d", hour, minute, second)
-				return &DateInfo{Year: year, Month: month, Day: day, Time: timeStr, Original: base}, nil
+				return &DateInfo{Year: year, Month: month, Day: day, Time: timeStr, HasTime: true, Original: base}, nil
+			},
+		},
+		{
+			// ISO 8601 YYYY-MM-DDTHH.MM.SS, YYYY-MM-DDTHHMMSS or YYYY-MM-DDTHH:MM:SS, with optional offset
+			regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})T(\d{2})[.:]?(\d{2})[.:]?(\d{2})(Z|[+-]\d{2}:?\d{2})?`),
+			func(matches []string) (*DateInfo, error) {
+				year, _ := strconv.Atoi(matches[1])
+				month, _ := strconv.Atoi(matches[2])
+				day, _ := strconv.Atoi(matches[3])
+				hour, _ := strconv.Atoi(matches[4])
+				minute, _ := strconv.Atoi(matches[5])
+				second, _ := strconv.Atoi(matches[6])
+				if hour > 23 || minute > 59 || second > 59 {
+					return nil, fmt.Errorf("invalid time in %s", matches[0])
+				}
+				timeStr := fmt.Sprintf("%02d:%02d:%02d", hour, minute, second)
+				return &DateInfo{Year: year, Month: month, Day: day, Time: timeStr, HasTime: true, Offset: matches[7], Original: base}, nil
+			},
+		},
+		{
+			// Dash-delimited YYYY-MM-DD-HH-MM-SS with optional milliseconds (Android screenshots,
+			// e.g. "Screenshot_2018-10-21-14-30-05-123_com.app")
+			regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})-(\d{2})-(\d{2})-(\d{2})(?:-\d{1,3})?(?:\D|$)`),
+			func(matches []string) (*DateInfo, error) {
+				year, _ := strconv.Atoi(matches[1])
+				month, _ := strconv.Atoi(matches[2])
+				day, _ := strconv.Atoi(matches[3])
+				hour, _ := strconv.Atoi(matches[4])
+				minute, _ := strconv.Atoi(matches[5])
+				second, _ := strconv.Atoi(matches[6])
+				if hour > 23 || minute > 59 || second > 59 {
+					return nil, fmt.Errorf("invalid time in %s", matches[0])
+				}
+				timeStr := fmt.Sprintf("%02d:%02d:%02d", hour, minute, second)
+				return &DateInfo{Year: year, Month: month, Day: day, Time: timeStr, HasTime: true, Original: base}, nil
+			},
+		},
+		{
+			// "Mon DD, YYYY, H MM SS AM/PM" (iOS exports, e.g. "Photo Oct 21, 2018, 2 30 05 PM")
+			regexp.MustCompile(`(?i)(?:^|[^a-z])(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+(\d{1,2}),?\s+(\d{4}),?\s+(\d{1,2})[\s.:](\d{2})[\s.:](\d{2})\s*([ap])\.?m\b`),
+			func(matches []string) (*DateInfo, error) {
+				month := monthFromAbbrev(matches[1])
+				day, _ := strconv.Atoi(matches[2])
+				year, _ := strconv.Atoi(matches[3])
+				hour, _ := strconv.Atoi(matches[4])
+				minute, _ := strconv.Atoi(matches[5])
+				second, _ := strconv.Atoi(matches[6])
+				if hour < 1 || hour > 12 || minute > 59 || second > 59 {
+					return nil, fmt.Errorf("invalid time in %s", matches[0])
+				}
+				// 12 AM is midnight and 12 PM is noon
+				hour %= 12
+				if strings.EqualFold(matches[7], "p") {
+					hour += 12
+				}
+				timeStr := fmt.Sprintf("%02d:%02d:%02d", hour, minute, second)
+				return &DateInfo{Year: year, Month: month, Day: day, Time: timeStr, HasTime: true, Original: base}, nil
 			},
 		},
 		{
@@ -147,6 +342,20 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 				return &DateInfo{Year: year, Month: month, Day: day, Original: base}, nil
 			},
 		},
+		{
+			// DD-MM-YYYY or MM-DD-YYYY (any of -_. between parts), read per opts.DateOrder
+			regexp.MustCompile(`(?:^|\D)(\d{1,2})[-_.](\d{1,2})[-_.](\d{4})(?:\D|$)`),
+			func(matches []string) (*DateInfo, error) {
+				first, _ := strconv.Atoi(matches[1])
+				second, _ := strconv.Atoi(matches[2])
+				year, _ := strconv.Atoi(matches[3])
+				day, month, err := dayMonth(first, second, opts.DateOrder)
+				if err != nil {
+					return nil, err
+				}
+				return &DateInfo{Year: year, Month: month, Day: day, Original: base}, nil
+			},
+		},
 		{
 			// YYYY_MM_DD format (with underscores)
 			regexp.MustCompile(`(\d{4})_(\d{2})_(\d{2})`),
@@ -173,7 +382,7 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 			func(matches []string) (*DateInfo, error) {
 				year, _ := strconv.Atoi(matches[1])
 				month, _ := strconv.Atoi(matches[2])
-				return &DateInfo{Year: year, Month: month, Day: 1, Original: base}, nil
+				return &DateInfo{Year: year, Month: month, Day: 1, Original: base, Precision: PrecisionMonth}, nil
 			},
 		},
 		{
@@ -196,12 +405,20 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 			},
 		},
 		{
-			// YYMM format (4 consecutive digits followed by non-digit or end, assume 19XX or 20XX based on value)
-			regexp.MustCompile(`^(\d{2})(\d{2})(?:\D|$)`),
+			// YYMM format (4 digits with a real month, then a separator; assume 19XX or 20XX based on value)
+			// Four bare digits are usually something else, so names like "1080p_clip", "2048x1536",
+			// "0420.jpg" (a counter) or "1234abc" (a serial) don't count, and neither does
+			// "1012_foo" unless the full year also appears in the path (e.g. ".../2010/1012_foo")
+			regexp.MustCompile(`^(\d{2})(0[1-9]|1[0-2])[-_ ]`),
 			func(matches []string) (*DateInfo, error) {
 				yy, _ := strconv.Atoi(matches[1])
 				month, _ := strconv.Atoi(matches[2])
 
+				// Zero-padded counters (0001_, 0012_) far outnumber photos from 2000 named this way
+				if yy == 0 {
+					return nil, fmt.Errorf("%s looks like a counter", matches[0])
+				}
+
 				// Heuristic: if YY > 50, assume 19XX, else 20XX
 				var year int
 				if yy > 50 {
@@ -209,18 +426,25 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 				} else {
 					year = 2000 + yy
 				}
+				if !pathHasYear(fullPath, year) {
+					return nil, fmt.Errorf("%s has no year in its path to confirm it", matches[0])
+				}
 
 				// Default to 1st of the month
-				return &DateInfo{Year: year, Month: month, Day: 1, Original: base}, nil
+				return &DateInfo{Year: year, Month: month, Day: 1, Original: base, Precision: PrecisionMonth}, nil
 			},
 		},
 		{
-			// YYYY only format (year only, no specific month/day) - matches YYYY_ or YYYY/ in path
-			regexp.MustCompile(`[/\\](\d{4})(?:_|/|\\)`),
+			// YYYY only format (year only, no specific month/day) - matches YYYY_ or YYYY/ in path,
+			// but not a resolution or counter pair like "1920_1080"
+			regexp.MustCompile(`[/\\](\d{4})(?:_(?:\D|$)|/|\\)`),
 			func(matches []string) (*DateInfo, error) {
 				year, _ := strconv.Atoi(matches[1])
+				if err := checkBareYear(year); err != nil {
+					return nil, err
+				}
 				// Default to January 1st when only year is available
-				return &DateInfo{Year: year, Month: 1, Day: 1, Original: base}, nil
+				return &DateInfo{Year: year, Month: 1, Day: 1, Original: base, Precision: PrecisionYear}, nil
 			},
 		},
 		{
@@ -229,16 +453,30 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 			func(matches []string) (*DateInfo, error) {
 				year, _ := strconv.Atoi(matches[1])
 				// Use the specified year as the default
-				return &DateInfo{Year: year, Month: 1, Day: 1, Original: base}, nil
+				return &DateInfo{Year: year, Month: 1, Day: 1, Original: base, Precision: PrecisionYear}, nil
 			},
 		},
 		{
-			// YYYY at start of filename or directory (e.g., "1933Lilian", "1903_Ivan")
-			regexp.MustCompile(`(?:^|[/\\])(\d{4})[A-Za-z_]`),
+			// YYYY at start of filename or directory (e.g., "1933Lilian", "1903_Ivan"),
+			// but not a resolution like "1920x1080" or "1920_1080"
+			regexp.MustCompile(`(?:^|[/\\])(\d{4})(?:_(?:\D|$)|[A-Za-z](?:\D|$))`),
 			func(matches []string) (*DateInfo, error) {
 				year, _ := strconv.Atoi(matches[1])
+				if err := checkBareYear(year); err != nil {
+					return nil, err
+				}
 				// Default to January 1st when only year is available
-				return &DateInfo{Year: year, Month: 1, Day: 1, Original: base}, nil
+				return &DateInfo{Year: year, Month: 1, Day: 1, Original: base, Precision: PrecisionYear}, nil
+			},
+		},
+		{
+			// A full date after a capture phrase in a shape no pattern above reads (e.g. "taken 1985.06.12")
+			regexp.MustCompile(`(?i)(?:^|[^a-z])(?:from|taken|shot)[\s_-]+(?:in[\s_-]+)?(\d{4})[-_.](\d{2})[-_.](\d{2})(?:\D|$)`),
+			func(matches []string) (*DateInfo, error) {
+				year, _ := strconv.Atoi(matches[1])
+				month, _ := strconv.Atoi(matches[2])
+				day, _ := strconv.Atoi(matches[3])
+				return &DateInfo{Year: year, Month: month, Day: day, Original: base}, nil
 			},
 		},
 	}
@@ -256,7 +494,7 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 				}
 
 				// Validate year range (reasonable for photos)
-				if info.Year < 1800 || info.Year > 2100 {
+				if !validYear(info.Year) {
 					continue
 				}
 
@@ -264,10 +502,14 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 				if info.Month < 1 || info.Month > 12 {
 					continue
 				}
-				if info.Day < 1 || info.Day > 31 {
+				if info.Day < 1 || info.Day > daysInMonth(info.Year, info.Month) {
 					continue
 				}
 
+				if opts.PreferEarliestYear {
+					info = preferEarliestYear(info, searchStr)
+				}
+
 				return info, nil
 			}
 		}
@@ -276,9 +518,106 @@ func ParseDateFromFilename(filename string) (*DateInfo, error) {
 	return nil, fmt.Errorf("could not parse date from filename: %s", filename)
 }
 
-// ToTime converts DateInfo to time.Time
+// monthFromAbbrev returns the month number for an English month name or its three-letter abbreviation
+func monthFromAbbrev(name string) int {
+	months := []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	prefix := strings.ToLower(name)
+	if len(prefix) > 3 {
+		prefix = prefix[:3]
+	}
+	for i, month := range months {
+		if prefix == month {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// preferEarliestYear replaces info with the earliest plausible year found in s, if earlier
+func preferEarliestYear(info *DateInfo, s string) *DateInfo {
+	earliest := info.Year
+	for _, m := range yearTokenRegex.FindAllStringSubmatch(s, -1) {
+		if year, _ := strconv.Atoi(m[1]); year < earliest {
+			earliest = year
+		}
+	}
+	if earliest == info.Year {
+		return info
+	}
+	// Only the year is known for the earlier date
+	return &DateInfo{Year: earliest, Month: 1, Day: 1, Original: info.Original, Precision: PrecisionYear}
+}
+
+// Years outside this range are rejected as implausible for photos
+const (
+	minPhotoYear = 1800
+	maxPhotoYear = 2100
+)
+
+// validYear reports whether year is plausible for a photo
+func validYear(year int) bool {
+	return year >= minPhotoYear && year <= maxPhotoYear
+}
+
+// firstPhotoYear is when the oldest surviving photograph was taken
+const firstPhotoYear = 1826
+
+// daysInMonth returns the number of days in a month, so "31-02-2018" is rejected
+func daysInMonth(year, month int) int {
+	return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// checkBareYear rejects a year that stands alone in a name but can't be a capture year,
+// since a "year" in the future or before photography, like the 2048 in "2048_wide" or
+// the 1806 in "1806_0042", is a size, counter or serial number
+func checkBareYear(year int) error {
+	if year > time.Now().Year() || year < firstPhotoYear {
+		return fmt.Errorf("%d can't be a capture year", year)
+	}
+	return nil
+}
+
+// pathHasYear reports whether year appears as a standalone number somewhere in path
+func pathHasYear(path string, year int) bool {
+	for _, m := range yearTokenRegex.FindAllStringSubmatch(path, -1) {
+		if m[1] == strconv.Itoa(year) {
+			return true
+		}
+	}
+	return false
+}
+
+// DateInfoFromTime builds a DateInfo for the calendar date of t, e.g. from EXIF
+func DateInfoFromTime(t time.Time, original string) *DateInfo {
+	return &DateInfo{Year: t.Year(), Month: int(t.Month()), Day: t.Day(), Original: original}
+}
+
+// withSource returns a copy of the date recording where it came from
+func (d *DateInfo) withSource(source DateSource) *DateInfo {
+	copied := *d
+	copied.Source = source
+	return &copied
+}
+
+// withOriginal returns a copy of the date for another file
+func (d *DateInfo) withOriginal(original string) *DateInfo {
+	copied := *d
+	copied.Original = original
+	return &copied
+}
+
+// DefaultTimeOfDay is the time used by ToTime for dates without a known time
+const DefaultTimeOfDay = 12 * time.Hour
+
+// ToTime converts DateInfo to time.Time, using noon when no time is known
 func (d *DateInfo) ToTime() time.Time {
-	if d.Time != "" {
+	return d.ToTimeWithDefault(DefaultTimeOfDay)
+}
+
+// ToTimeWithDefault converts DateInfo to time.Time, using defaultTime (an offset
+// from midnight) when no time is known
+func (d *DateInfo) ToTimeWithDefault(defaultTime time.Duration) time.Time {
+	if d.HasTime {
 		// Parse HH:MM:SS if available
 		parts := strings.Split(d.Time, ":")
 		if len(parts) == 3 {
@@ -289,36 +628,192 @@ func (d *DateInfo) ToTime() time.Time {
 		}
 	}
 
-	// Default to noon if no time specified
-	return time.Date(d.Year, time.Month(d.Month), d.Day, 12, 0, 0, 0, time.UTC)
+	return time.Date(d.Year, time.Month(d.Month), d.Day, 0, 0, 0, 0, time.UTC).Add(defaultTime)
+}
+
+// String returns the date as "YYYY-MM-DD", or "YYYY-MM-DD HH:MM:SS" when the name had a time
+func (d *DateInfo) String() string {
+	if d.HasTime {
+		return fmt.Sprintf("%04d-%02d-%02d %s", d.Year, d.Month, d.Day, d.Time)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Before reports whether d is earlier than other, comparing their ToTime values
+func (d *DateInfo) Before(other *DateInfo) bool {
+	return d.ToTime().Before(other.ToTime())
+}
+
+// Equal reports whether d and other have the same ToTime value and both have a time
+// or both are date-only, so a date-only name never equals an explicit 12:00:00
+func (d *DateInfo) Equal(other *DateInfo) bool {
+	return d.HasTime == other.HasTime && d.ToTime().Equal(other.ToTime())
 }
 
 // StandardizedFilename generates a standardized filename based on date info
-// Format: YYYY-MM-DD_description.ext (time only included if not default)
+// Format: YYYY-MM-DD_description.ext (time only included if known)
 // Format with time: YYYY-MM-DD_HHMMSS_description.ext
-func (d *DateInfo) StandardizedFilename(description string, ext string) string {
-	// Clean description: remove existing date patterns, trim spaces, replace spaces with underscores
+// The "_" shown above is replaced by opts.Separator
+func (d *DateInfo) StandardizedFilename(description string, ext string, opts NameOptions) string {
+	sep := opts.Separator
+	if sep == "" {
+		sep = "_"
+	}
+
+	// Clean description: remove the existing date, trim spaces, replace spaces with underscores
 	desc := description
-	desc = regexp.MustCompile(`^\d{4}[-_]?\d{0,2}[-_]?\d{0,2}_?`).ReplaceAllString(desc, "")
-	desc = regexp.MustCompile(`^\d{6}_?`).ReplaceAllString(desc, "")
-	desc = strings.TrimSpace(desc)
-	desc = strings.ReplaceAll(desc, " ", "_")
+	if opts.StripDatePrefix {
+		desc = d.stripDatePrefix(desc)
+	}
+	if opts.NormalizeWhitespace {
+		desc = normalizeDescription(desc, sep)
+	} else {
+		desc = strings.TrimSpace(desc)
+		desc = strings.ReplaceAll(desc, " ", sep)
+	}
 
 	if desc == "" {
 		desc = "photo"
 	}
 
-	// Only include time if it's not the default noon time
-	if d.Time != "" && d.Time != "12:00:00" {
+	// Only include time if it was in the original name
+	if d.HasTime {
 		timeStr := strings.ReplaceAll(d.Time, ":", "")
-		return fmt.Sprintf("%04d-%02d-%02d_%s_%s%s", d.Year, d.Month, d.Day, timeStr, desc, ext)
+		return fmt.Sprintf("%04d-%02d-%02d%s%s%s%s%s", d.Year, d.Month, d.Day, sep, timeStr, sep, desc, ext)
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d%s%s%s", d.Year, d.Month, d.Day, sep, desc, ext)
+}
+
+// HasStandardizedName reports whether name is already what StandardizedFilename gives
+// for this date: the date, the time if known and a description that wouldn't change
+func (d *DateInfo) HasStandardizedName(name string, opts NameOptions) bool {
+	sep := opts.Separator
+	if sep == "" {
+		sep = "_"
+	}
+	prefix := fmt.Sprintf("%04d-%02d-%02d%s", d.Year, d.Month, d.Day, sep)
+	if d.HasTime {
+		prefix += strings.ReplaceAll(d.Time, ":", "") + sep
 	}
 
-	return fmt.Sprintf("%04d-%02d-%02d_%s%s", d.Year, d.Month, d.Day, desc, ext)
+	ext := fileExt(name)
+	desc, ok := strings.CutPrefix(strings.TrimSuffix(name, ext), prefix)
+	return ok && desc != "" && d.StandardizedFilename(desc, ext, opts) == name
 }
 
+// ParseStandardizedName reads the date back out of a name StandardizedFilename gave,
+// e.g. "2018-10-21_143000_beach.jpg", returning nil if name isn't one
+func ParseStandardizedName(name string, opts NameOptions) *DateInfo {
+	sep := opts.Separator
+	if sep == "" {
+		sep = "_"
+	}
+	q := regexp.QuoteMeta(sep)
+	m := regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})` + q + `(?:(\d{2})(\d{2})(\d{2})` + q + `)?.`).FindStringSubmatch(name)
+	if m == nil {
+		return nil
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	if t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC); t.Month() != time.Month(month) || t.Day() != day {
+		return nil
+	}
+	d := &DateInfo{Year: year, Month: month, Day: day, Original: name, Source: DateSourceFilename}
+	if m[4] != "" {
+		hour, _ := strconv.Atoi(m[4])
+		minute, _ := strconv.Atoi(m[5])
+		second, _ := strconv.Atoi(m[6])
+		if hour < 24 && minute < 60 && second < 60 {
+			d.Time, d.HasTime = fmt.Sprintf("%s:%s:%s", m[4], m[5], m[6]), true
+		}
+	}
+
+	// A six-digit description that isn't a time would read as one, so check the round trip
+	if !d.HasStandardizedName(name, opts) {
+		d.Time, d.HasTime = "", false
+		if !d.HasStandardizedName(name, opts) {
+			return nil
+		}
+	}
+	return d
+}
+
+// normalizeDescription collapses each run of whitespace (including tabs), underscores
+// and separator characters into a single sep and trims them from both ends, so
+// "wedding   official " becomes "wedding_official"
+func normalizeDescription(desc, sep string) string {
+	words := strings.FieldsFunc(desc, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || strings.ContainsRune(sep, r)
+	})
+	return strings.Join(words, sep)
+}
+
+// datePrefixRegex matches a whole date token at the start of a description: YYYY, YYYY-MM,
+// YYYY-MM-DD (any of -_. between parts), YYYYMMDD or YYMMDD, optionally followed by a
+// time, and ending at a word boundary so "500px" or "1st" are never touched
+var datePrefixRegex = regexp.MustCompile(`^((?:18|19|20)\d{2}(?:[-_.](?:0[1-9]|1[0-2])(?:[-_.](?:0[1-9]|[12]\d|3[01]))?)?|(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])|\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01]))` +
+	`(?:T\d{2}[.:]?\d{2}[.:]?\d{2}(?:Z|[+-]\d{2}:?\d{2})?|[\s_-]+\d{2}\.\d{2}\.\d{2}|-\d{2}-\d{2}-\d{2}(?:-\d{1,3})?)?` +
+	`(?:[^0-9A-Za-z]+|$)`)
+
+// stripDatePrefix removes a leading date token from desc if it is the parsed date,
+// so numbers that merely look like years (e.g. "2048 game" dated 2015) are kept
+func (d *DateInfo) stripDatePrefix(desc string) string {
+	m := datePrefixRegex.FindStringSubmatch(desc)
+	if m == nil {
+		return desc
+	}
+
+	token := m[1]
+	year := fmt.Sprintf("%04d", d.Year)
+	isYYMMDD := len(token) == 6 && !strings.HasPrefix(token, year)
+	if isYYMMDD {
+		if token[:2] != year[2:] {
+			return desc
+		}
+	} else if !strings.HasPrefix(token, year) {
+		return desc
+	}
+	return desc[len(m[0]):]
+}
+
+// Destination folder layouts
+const (
+	LayoutYear         = "year"           // YYYY/
+	LayoutYearMonth    = "year-month"     // YYYY/YYYY-MM/ (the default)
+	LayoutYearMonthDay = "year-month-day" // YYYY/YYYY-MM/YYYY-MM-DD/
+	LayoutFlat         = "flat"           // Everything directly in the destination
+)
+
 // GetDirectoryPath returns the standardized directory path for this date
 // Format: YYYY/YYYY-MM/
 func (d *DateInfo) GetDirectoryPath() string {
-	return fmt.Sprintf("%04d/%04d-%02d", d.Year, d.Year, d.Month)
+	return d.DirectoryPath(LayoutYearMonth)
+}
+
+// DirectoryPath returns the directory path for this date in the given layout
+// ("" means LayoutYearMonth); the flat layout returns ""
+func (d *DateInfo) DirectoryPath(layout string) string {
+	switch layout {
+	case LayoutYear:
+		return fmt.Sprintf("%04d", d.Year)
+	case LayoutYearMonthDay:
+		return fmt.Sprintf("%04d/%04d-%02d/%04d-%02d-%02d", d.Year, d.Year, d.Month, d.Year, d.Month, d.Day)
+	case LayoutFlat:
+		return ""
+	default:
+		return fmt.Sprintf("%04d/%04d-%02d", d.Year, d.Year, d.Month)
+	}
+}
+
+// validateLayout checks a destination layout name ("" means LayoutYearMonth)
+func validateLayout(layout string) error {
+	switch layout {
+	case "", LayoutYear, LayoutYearMonth, LayoutYearMonthDay, LayoutFlat:
+		return nil
+	default:
+		return fmt.Errorf("unsupported destination layout %q (use %s, %s, %s or %s)", layout, LayoutYear, LayoutYearMonth, LayoutYearMonthDay, LayoutFlat)
+	}
 }
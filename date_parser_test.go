@@ -0,0 +1,428 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStandardizedFilenameSeparator(t *testing.T) {
+	date := &DateInfo{Year: 2018, Month: 10, Day: 21}
+	timed := &DateInfo{Year: 2018, Month: 10, Day: 21, Time: "14:30:00", HasTime: true}
+
+	tests := []struct {
+		name string
+		date *DateInfo
+		desc string
+		sep  string
+		want string
+	}{
+		{"default", date, "beach day", "", "2018-10-21_beach_day.jpg"},
+		{"underscore", date, "beach day", "_", "2018-10-21_beach_day.jpg"},
+		{"hyphen", date, "beach day", "-", "2018-10-21-beach-day.jpg"},
+		{"hyphen with time", timed, "beach day", "-", "2018-10-21-143000-beach-day.jpg"},
+		{"period", date, "beach day", ".", "2018-10-21.beach.day.jpg"},
+		{"period with time", timed, "beach day", ".", "2018-10-21.143000.beach.day.jpg"},
+		{"hyphen strips date", date, "2018-10-21 beach", "-", "2018-10-21-beach.jpg"},
+		{"period empty description", date, "", ".", "2018-10-21.photo.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := NameOptions{Separator: tt.sep, StripDatePrefix: true}
+			if got := tt.date.StandardizedFilename(tt.desc, ".jpg", opts); got != tt.want {
+				t.Errorf("StandardizedFilename(%q) with %q = %q, want %q", tt.desc, tt.sep, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanDirectoryNameSeparator(t *testing.T) {
+	tests := []struct {
+		dir, sep, want string
+	}{
+		{"2018-10-21 Summer Trip", "_", "Summer_Trip"},
+		{"2018-10-21 Summer Trip", "-", "Summer-Trip"},
+		{"2018-10-21 Summer Trip", ".", "Summer.Trip"},
+		{"2010-2019 Family", "-", "Family"},
+	}
+	for _, tt := range tests {
+		if got := cleanDirectoryName(tt.dir, tt.sep); got != tt.want {
+			t.Errorf("cleanDirectoryName(%q, %q) = %q, want %q", tt.dir, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSeparator(t *testing.T) {
+	for _, sep := range []string{"_", "-", "."} {
+		if err := validateSeparator(sep); err != nil {
+			t.Errorf("validateSeparator(%q) = %v, want nil", sep, err)
+		}
+	}
+	for _, sep := range []string{"", "--", " ", "/", `\`, ":", "*"} {
+		if err := validateSeparator(sep); err == nil {
+			t.Errorf("validateSeparator(%q) = nil, want an error", sep)
+		}
+	}
+}
+
+// parseCase is a filename and the date ParseDateFromFilenameWithOptions should give it,
+// as DateInfo.String(); an empty want means the name must not parse
+type parseCase struct {
+	name string
+	want string
+}
+
+func checkParse(t *testing.T, tests []parseCase, opts ParseOptions) {
+	t.Helper()
+	for _, tt := range tests {
+		got, err := ParseDateFromFilenameWithOptions(tt.name, opts)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%q parsed as %s, want no date", tt.name, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("%q: %v, want %s", tt.name, err, tt.want)
+		case tt.want != "" && got.String() != tt.want:
+			t.Errorf("%q parsed as %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDateCaptureYearPhrases(t *testing.T) {
+	checkParse(t, []parseCase{
+		// The capture year in the phrase beats the scan date
+		{"scanned-2021 from 1985 trip.jpg", "1985-01-01"},
+		{"scan_2021_taken_1970.jpg", "1970-01-01"},
+		{"2021-03-04 scan from 1985-06 trip.jpg", "1985-06-01"},
+		{"Scan 2021-05-01 from 1970s.jpg", "1970-01-01"},
+		{"shot in 1999.jpg", "1999-01-01"},
+		{"taken 1985.06.12.jpg", "1985-06-12"},
+
+		// A full date after the keyword keeps its day and time
+		{"Screen Shot 2018-10-21 at 2.30.05 PM.png", "2018-10-21"},
+		{"photos from 2018-10-21 party.jpg", "2018-10-21"},
+		{"from 2018-10-21T14.30.00.jpg", "2018-10-21 14:30:00"},
+		{"taken_2018_10_21.jpg", "2018-10-21"},
+
+		// Words that only contain the keywords aren't phrases
+		{"screenshot 2015-03-02.png", "2015-03-02"},
+		{"shotgun 2015.jpg", ""},
+	}, ParseOptions{})
+}
+
+func TestParseDatePreferEarliestYear(t *testing.T) {
+	tests := []parseCase{
+		{"2021-03-04 scan 1985.jpg", "1985-01-01"},
+		{"2018-10-21_beach.jpg", "2018-10-21"},
+		{"Screen Shot 2018-10-21 at 2.30.05 PM.png", "2018-10-21"},
+	}
+	checkParse(t, tests, ParseOptions{PreferEarliestYear: true})
+	checkParse(t, []parseCase{{"2021-03-04 scan 1985.jpg", "2021-03-04"}}, ParseOptions{})
+}
+
+func TestParseDateISOTimestamps(t *testing.T) {
+	tests := []struct {
+		name       string
+		want       string
+		wantOffset string
+	}{
+		{"2018-10-21T143000.jpg", "2018-10-21 14:30:00", ""},
+		{"2018-10-21T14.30.00.jpg", "2018-10-21 14:30:00", ""},
+		{"2018-10-21T14:30:00.jpg", "2018-10-21 14:30:00", ""},
+		{"IMG_2018-10-21T143000.jpg", "2018-10-21 14:30:00", ""},
+		{"2018-10-21T143000+0200.jpg", "2018-10-21 14:30:00", "+0200"},
+		{"2018-10-21T14.30.00+0200.jpg", "2018-10-21 14:30:00", "+0200"},
+		{"2018-10-21T14.30.00+02:00_beach.jpg", "2018-10-21 14:30:00", "+02:00"},
+		{"2018-10-21T14.30.00-0500.jpg", "2018-10-21 14:30:00", "-0500"},
+		{"2018-10-21T143000Z.jpg", "2018-10-21 14:30:00", "Z"},
+		// An impossible time falls back to the date alone
+		{"2018-10-21T253000.jpg", "2018-10-21", ""},
+	}
+	for _, tt := range tests {
+		got, err := ParseDateFromFilename(tt.name)
+		if err != nil {
+			t.Errorf("%q: %v", tt.name, err)
+			continue
+		}
+		if got.String() != tt.want || got.Offset != tt.wantOffset {
+			t.Errorf("%q parsed as %s offset %q, want %s offset %q", tt.name, got, got.Offset, tt.want, tt.wantOffset)
+		}
+		if wantTime := len(tt.want) > len("2018-10-21"); got.HasTime != wantTime {
+			t.Errorf("%q: HasTime = %v, want %v", tt.name, got.HasTime, wantTime)
+		}
+	}
+}
+
+func TestStandardizedFilenameDatePrefix(t *testing.T) {
+	date := &DateInfo{Year: 2018, Month: 10, Day: 21}
+	tests := []struct {
+		desc  string
+		strip bool
+		want  string
+	}{
+		// A leading copy of the parsed date is removed, whatever its shape
+		{"2018-10-21 beach", true, "2018-10-21_beach.jpg"},
+		{"2018_10_21_beach", true, "2018-10-21_beach.jpg"},
+		{"2018.10.21 beach", true, "2018-10-21_beach.jpg"},
+		{"20181021_beach", true, "2018-10-21_beach.jpg"},
+		{"181021 beach", true, "2018-10-21_beach.jpg"},
+		{"2018-10 beach", true, "2018-10-21_beach.jpg"},
+		{"2018 beach", true, "2018-10-21_beach.jpg"},
+		{"2018-10-21T143000+0200 beach", true, "2018-10-21_beach.jpg"},
+		{"2018-10-21 14.30.00 beach", true, "2018-10-21_beach.jpg"},
+		{"2018-10-21", true, "2018-10-21_photo.jpg"},
+
+		// Descriptions that really start with digits are kept
+		{"500px export", true, "2018-10-21_500px_export.jpg"},
+		{"1st birthday", true, "2018-10-21_1st_birthday.jpg"},
+		{"2048 game", true, "2018-10-21_2048_game.jpg"},
+		{"1999 reunion photos", true, "2018-10-21_1999_reunion_photos.jpg"},
+		{"2018abc", true, "2018-10-21_2018abc.jpg"},
+		{"12 days of christmas", true, "2018-10-21_12_days_of_christmas.jpg"},
+
+		// With stripping off, the description is used as-is
+		{"2018-10-21 beach", false, "2018-10-21_2018-10-21_beach.jpg"},
+		{"20181021_beach", false, "2018-10-21_20181021_beach.jpg"},
+	}
+	for _, tt := range tests {
+		opts := NameOptions{Separator: "_", StripDatePrefix: tt.strip}
+		if got := date.StandardizedFilename(tt.desc, ".jpg", opts); got != tt.want {
+			t.Errorf("StandardizedFilename(%q, strip=%v) = %q, want %q", tt.desc, tt.strip, got, tt.want)
+		}
+	}
+}
+
+func TestGenuineNoonKeepsItsTime(t *testing.T) {
+	noon, err := ParseDateFromFilename("2018-10-21 12.00.00.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !noon.HasTime || noon.Time != "12:00:00" {
+		t.Fatalf("parsed noon as %+v, want HasTime with 12:00:00", noon)
+	}
+	dateOnly, err := ParseDateFromFilename("2018-10-21.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultNameOptions()
+	if got, want := noon.StandardizedFilename("lunch", ".jpg", opts), "2018-10-21_120000_lunch.jpg"; got != want {
+		t.Errorf("noon StandardizedFilename = %q, want %q", got, want)
+	}
+	if got, want := dateOnly.StandardizedFilename("lunch", ".jpg", opts), "2018-10-21_lunch.jpg"; got != want {
+		t.Errorf("date-only StandardizedFilename = %q, want %q", got, want)
+	}
+
+	// The configured default time applies only to the date-only name
+	for _, defaultTime := range []time.Duration{0, 9 * time.Hour, DefaultTimeOfDay} {
+		if got := noon.ToTimeWithDefault(defaultTime); got.Hour() != 12 || got.Minute() != 0 {
+			t.Errorf("noon.ToTimeWithDefault(%s) = %s, want 12:00", defaultTime, got)
+		}
+		if got, want := dateOnly.ToTimeWithDefault(defaultTime), time.Date(2018, 10, 21, 0, 0, 0, 0, time.UTC).Add(defaultTime); !got.Equal(want) {
+			t.Errorf("dateOnly.ToTimeWithDefault(%s) = %s, want %s", defaultTime, got, want)
+		}
+	}
+
+	// The noon name reads back with its time
+	back := ParseStandardizedName("2018-10-21_120000_lunch.jpg", opts)
+	if back == nil || !back.HasTime || back.Time != "12:00:00" {
+		t.Errorf("ParseStandardizedName of the noon name = %+v, want 12:00:00", back)
+	}
+}
+
+func TestDateInfoEqualBeforeString(t *testing.T) {
+	day := &DateInfo{Year: 2018, Month: 10, Day: 21}
+	sameDay := &DateInfo{Year: 2018, Month: 10, Day: 21}
+	noon := &DateInfo{Year: 2018, Month: 10, Day: 21, Time: "12:00:00", HasTime: true}
+	sameNoon := &DateInfo{Year: 2018, Month: 10, Day: 21, Time: "12:00:00", HasTime: true}
+	afternoon := &DateInfo{Year: 2018, Month: 10, Day: 21, Time: "14:30:00", HasTime: true}
+	nextDay := &DateInfo{Year: 2018, Month: 10, Day: 22}
+
+	tests := []struct {
+		name   string
+		a, b   *DateInfo
+		equal  bool
+		before bool
+		after  bool // b.Before(a)
+	}{
+		{"same date without time", day, sameDay, true, false, false},
+		{"same date and time", noon, sameNoon, true, false, false},
+		// Both sort at noon, but a date-only name isn't the same as an explicit 12:00:00
+		{"date-only vs noon", day, noon, false, false, false},
+		{"different times", noon, afternoon, false, true, false},
+		{"date-only vs later time", day, afternoon, false, true, false},
+		{"different days", afternoon, nextDay, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Equal(tt.b); got != tt.equal {
+				t.Errorf("%s.Equal(%s) = %v, want %v", tt.a, tt.b, got, tt.equal)
+			}
+			if got := tt.b.Equal(tt.a); got != tt.equal {
+				t.Errorf("%s.Equal(%s) = %v, want %v", tt.b, tt.a, got, tt.equal)
+			}
+			if got := tt.a.Before(tt.b); got != tt.before {
+				t.Errorf("%s.Before(%s) = %v, want %v", tt.a, tt.b, got, tt.before)
+			}
+			if got := tt.b.Before(tt.a); got != tt.after {
+				t.Errorf("%s.Before(%s) = %v, want %v", tt.b, tt.a, got, tt.after)
+			}
+		})
+	}
+
+	if got := day.String(); got != "2018-10-21" {
+		t.Errorf("String() = %q, want 2018-10-21", got)
+	}
+	if got := afternoon.String(); got != "2018-10-21 14:30:00" {
+		t.Errorf("String() = %q, want 2018-10-21 14:30:00", got)
+	}
+}
+
+func TestParseDatePhoneFormats(t *testing.T) {
+	checkParse(t, []parseCase{
+		// Dash-delimited date and time, with or without milliseconds
+		{"Screenshot_2018-10-21-14-30-05-123_com.app.png", "2018-10-21 14:30:05"},
+		{"Screenshot_2018-10-21-14-30-05_com.app.png", "2018-10-21 14:30:05"},
+
+		// "Month DD, YYYY, H MM SS AM/PM", converted to 24-hour time
+		{"Photo Oct 21, 2018, 2 30 05 PM.jpg", "2018-10-21 14:30:05"},
+		{"Photo October 21, 2018, 2 30 05 PM.jpg", "2018-10-21 14:30:05"},
+		{"Photo Oct 1, 2018, 9 05 07 am.jpg", "2018-10-01 09:05:07"},
+		{"Photo Oct 21, 2018, 12 05 00 AM.jpg", "2018-10-21 00:05:00"},
+		{"Photo Oct 21, 2018, 12 05 00 PM.jpg", "2018-10-21 12:05:00"},
+		{"Photo Oct 21, 2018, 13 30 05 PM.jpg", ""},
+	}, ParseOptions{})
+}
+
+func TestNormalizeDescription(t *testing.T) {
+	tests := []struct {
+		desc, sep, want string
+	}{
+		{"wedding   official ", "_", "wedding_official"},
+		{"wedding\tofficial", "_", "wedding_official"},
+		{"\t wedding \t\t official\t", "_", "wedding_official"},
+		{"wedding__official_", "_", "wedding_official"},
+		{"wedding _ official", "_", "wedding_official"},
+		{"wedding\n\rofficial", "_", "wedding_official"},
+		{"wedding official", "_", "wedding_official"},
+		{"wedding   official", "-", "wedding-official"},
+		{"wedding -_- official", "-", "wedding-official"},
+		{"wedding\t official", ".", "wedding.official"},
+		{"  \t ", "_", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeDescription(tt.desc, tt.sep); got != tt.want {
+			t.Errorf("normalizeDescription(%q, %q) = %q, want %q", tt.desc, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestStandardizedFilenameNormalizeWhitespace(t *testing.T) {
+	date := &DateInfo{Year: 2018, Month: 10, Day: 21}
+	tests := []struct {
+		desc      string
+		normalize bool
+		want      string
+	}{
+		{"wedding   official ", true, "2018-10-21_wedding_official.jpg"},
+		{"wedding\t\tofficial", true, "2018-10-21_wedding_official.jpg"},
+		{"2018-10-21 \t wedding", true, "2018-10-21_wedding.jpg"},
+		{" \t ", true, "2018-10-21_photo.jpg"},
+		// Without normalizing, only single spaces are replaced
+		{"wedding   official ", false, "2018-10-21_wedding___official.jpg"},
+	}
+	for _, tt := range tests {
+		opts := NameOptions{Separator: "_", StripDatePrefix: true, NormalizeWhitespace: tt.normalize}
+		if got := date.StandardizedFilename(tt.desc, ".jpg", opts); got != tt.want {
+			t.Errorf("StandardizedFilename(%q, normalize=%v) = %q, want %q", tt.desc, tt.normalize, got, tt.want)
+		}
+	}
+}
+
+func TestParseDateRejectsNumbersThatAreNotDates(t *testing.T) {
+	checkParse(t, []parseCase{
+		// Resolutions
+		{"1080p_clip.jpg", ""},
+		{"2160p.mp4", ""},
+		{"720p_clip.mp4", ""},
+		{"1920x1080.jpg", ""},
+		{"3840x2160_wallpaper.jpg", ""},
+		{"1920_1080.jpg", ""},
+		{"/wallpapers/1920_1080.jpg", ""},
+		{"2048_wide.jpg", ""},
+		{"4096_texture.png", ""},
+
+		// Counters
+		{"0001_scan.jpg", ""},
+		{"0012_scan.jpg", ""},
+		{"1012_foo.jpg", ""},
+		{"1105_IMG.jpg", ""},
+		{"1512 party.jpg", ""},
+		{"1806_0042.jpg", ""},
+		{"IMG_1012.jpg", ""},
+		{"DSC_2048.jpg", ""},
+		{"2048.jpg", ""},
+
+		// Serial numbers
+		{"P1012345.jpg", ""},
+		{"12345678.jpg", ""},
+		{"SN1012_3.jpg", ""},
+		{"/exports/2099_batch/IMG_0001.jpg", ""},
+	}, ParseOptions{})
+}
+
+func TestParseDateYearOnlyAndYYMM(t *testing.T) {
+	checkParse(t, []parseCase{
+		// Real years at the start of a name or folder still count
+		{"1999_party.jpg", "1999-01-01"},
+		{"1903_Ivan.jpg", "1903-01-01"},
+		{"1933Lilian.jpg", "1933-01-01"},
+		{"/photos/2019_trip/IMG_0001.jpg", "2019-01-01"},
+		{"/photos/1999/IMG_0001.jpg", "1999-01-01"},
+
+		// YYMM needs the full year somewhere in the path to back it up
+		{"/photos/2010/1012_foo.jpg", "2010-12-01"},
+		{"/photos/2018 Paris/1806 trip.jpg", "2018-06-01"},
+		{"/photos/1999/9912-party.jpg", "1999-12-01"},
+		// A folder for another year dates it by the folder alone
+		{"/photos/2011/1012_foo.jpg", "2011-01-01"},
+	}, ParseOptions{})
+}
+
+func TestParseDateDayMonthOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		want map[string]string // By DateOrder; "" means no date
+	}{
+		// Unambiguous: a number above 12 can only be the day
+		{"21-10-2018.jpg", map[string]string{"": "2018-10-21", DateOrderDMY: "2018-10-21", DateOrderMDY: "2018-10-21", DateOrderYMD: ""}},
+		{"10-21-2018.jpg", map[string]string{"": "2018-10-21", DateOrderDMY: "2018-10-21", DateOrderMDY: "2018-10-21", DateOrderYMD: ""}},
+		{"21.10.2018 party.jpg", map[string]string{"": "2018-10-21", DateOrderDMY: "2018-10-21", DateOrderMDY: "2018-10-21", DateOrderYMD: ""}},
+		{"IMG_21_10_2018.jpg", map[string]string{"": "2018-10-21", DateOrderDMY: "2018-10-21", DateOrderMDY: "2018-10-21", DateOrderYMD: ""}},
+		{"05-05-2018.jpg", map[string]string{"": "2018-05-05", DateOrderDMY: "2018-05-05", DateOrderMDY: "2018-05-05", DateOrderYMD: ""}},
+
+		// Ambiguous: rejected unless a date order is set
+		{"10-05-2018.jpg", map[string]string{"": "", DateOrderDMY: "2018-05-10", DateOrderMDY: "2018-10-05", DateOrderYMD: ""}},
+		{"1-5-2018.jpg", map[string]string{"": "", DateOrderDMY: "2018-05-01", DateOrderMDY: "2018-01-05", DateOrderYMD: ""}},
+
+		// Impossible whatever the order
+		{"13-13-2018.jpg", map[string]string{"": "", DateOrderDMY: "", DateOrderMDY: "", DateOrderYMD: ""}},
+		{"32-01-2018.jpg", map[string]string{"": "", DateOrderDMY: "", DateOrderMDY: "", DateOrderYMD: ""}},
+		{"31-02-2018.jpg", map[string]string{"": "", DateOrderDMY: "", DateOrderMDY: "", DateOrderYMD: ""}},
+		{"02-30-2018.jpg", map[string]string{"": "", DateOrderDMY: "", DateOrderMDY: "", DateOrderYMD: ""}},
+		{"29-02-2019.jpg", map[string]string{"": "", DateOrderDMY: "", DateOrderMDY: "", DateOrderYMD: ""}},
+		{"29-02-2020.jpg", map[string]string{"": "2020-02-29", DateOrderDMY: "2020-02-29", DateOrderMDY: "2020-02-29", DateOrderYMD: ""}},
+	}
+	for _, order := range []string{"", DateOrderDMY, DateOrderMDY, DateOrderYMD} {
+		var cases []parseCase
+		for _, tt := range tests {
+			cases = append(cases, parseCase{tt.name, tt.want[order]})
+		}
+		t.Run("order "+order, func(t *testing.T) {
+			checkParse(t, cases, ParseOptions{DateOrder: order})
+		})
+	}
+
+	// Year-first dates don't depend on the order
+	for _, order := range []string{"", DateOrderDMY, DateOrderMDY, DateOrderYMD} {
+		checkParse(t, []parseCase{{"2018-05-10.jpg", "2018-05-10"}, {"20180231.jpg", ""}}, ParseOptions{DateOrder: order})
+	}
+}
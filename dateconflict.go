@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// Which date wins when a file's name and its folder give different years (Config.DateConflictPrefer)
+const (
+	DateConflictPreferFilename = "filename" // The file's own name (default)
+	DateConflictPreferFolder   = "folder"   // The folders between the source and the file
+)
+
+// validateDateConflictPrefer checks a date conflict precedence ("" means filename)
+func validateDateConflictPrefer(prefer string) error {
+	switch prefer {
+	case "", DateConflictPreferFilename, DateConflictPreferFolder:
+		return nil
+	default:
+		return fmt.Errorf("unsupported date conflict preference %q (use %s or %s)", prefer, DateConflictPreferFilename, DateConflictPreferFolder)
+	}
+}
+
+// dateConflict returns the dates a file's name and its folders (below the source) give
+// when both parse but disagree, or nil for both when they agree or only one gives a date.
+// Folder names rarely carry more than a year ("2015 misc"), so only different years
+// count as a conflict; "2015 misc/2015-10-21.jpg" agrees.
+func (p *PhotoProcessor) dateConflict(filePath string) (nameDate, folderDate *DateInfo) {
+	relDir, err := filepath.Rel(p.config.SourceDir, filepath.Dir(filePath))
+	if err != nil || relDir == "." || relDir == ".." || filepath.IsAbs(relDir) {
+		return nil, nil
+	}
+
+	nameDate, err = ParseDateFromFilenameWithOptions(filepath.Base(filePath), p.parseOptions())
+	if err != nil {
+		return nil, nil
+	}
+	folderDate = parseFolderDate(relDir, p.parseOptions())
+	if folderDate == nil || folderDate.Year == nameDate.Year {
+		return nil, nil
+	}
+
+	base := filepath.Base(filePath)
+	return nameDate.withOriginal(base).withSource(DateSourceFilename), folderDate.withOriginal(base).withSource(DateSourceFolder)
+}
+
+// parseFolderDate dates a relative folder path as a file name would be dated, falling back
+// to a standalone year in the nearest folder that has one ("2015 misc" is January 1st, 2015)
+func parseFolderDate(relDir string, opts ParseOptions) *DateInfo {
+	if dateInfo, err := ParseDateFromFilenameWithOptions(relDir, opts); err == nil {
+		return dateInfo
+	}
+	for dir := relDir; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if m := yearTokenRegex.FindStringSubmatch(filepath.Base(dir)); m != nil {
+			year, _ := strconv.Atoi(m[1])
+			if validYear(year) {
+				return &DateInfo{Year: year, Month: 1, Day: 1, Original: filepath.Base(relDir), Precision: PrecisionYear}
+			}
+		}
+	}
+	return nil
+}
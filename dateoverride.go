@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dateOverrideLayouts are the date formats accepted in a date override CSV
+var dateOverrideLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006:01:02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006:01:02",
+}
+
+// dateOverrides holds manually corrected dates, keyed by source path or file name
+type dateOverrides struct {
+	byPath map[string]DateInfo // Keys containing a directory: full source paths or paths relative to SourceDir
+	byName map[string]DateInfo // Plain file names, matching a file in any directory
+}
+
+// loadDateOverrides reads a CSV of "<file name or path>,<date>" rows. Dates are
+// YYYY-MM-DD, optionally followed by HH:MM:SS; a header row is allowed.
+func loadDateOverrides(path string) (*dateOverrides, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open date override CSV: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	overrides := &dateOverrides{byPath: make(map[string]DateInfo), byName: make(map[string]DateInfo)}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read date override CSV: %w", err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("date override CSV row %d: expected <file>,<date>", row)
+		}
+
+		key, value := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		dateInfo, err := parseOverrideDate(value)
+		if err != nil {
+			if row == 1 {
+				continue // Header
+			}
+			return nil, fmt.Errorf("date override CSV row %d: %w", row, err)
+		}
+
+		entries := overrides.byName
+		if strings.ContainsAny(key, `/\`) {
+			entries, key = overrides.byPath, filepath.Clean(key)
+		}
+		if _, ok := entries[key]; ok {
+			log.Printf("Warning: date override for %s on row %d replaces an earlier row", key, row)
+		}
+		entries[key] = dateInfo
+	}
+
+	log.Printf("Loaded %d date overrides from %s", len(overrides.byPath)+len(overrides.byName), path)
+	return overrides, nil
+}
+
+// parseOverrideDate parses a date override value
+func parseOverrideDate(value string) (DateInfo, error) {
+	for _, layout := range dateOverrideLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		dateInfo := DateInfo{Year: t.Year(), Month: int(t.Month()), Day: t.Day()}
+		if strings.Contains(layout, "15") {
+			dateInfo.Time, dateInfo.HasTime = t.Format("15:04:05"), true
+		}
+		return dateInfo, nil
+	}
+	return DateInfo{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD or YYYY-MM-DD HH:MM:SS)", value)
+}
+
+// lookup returns the override for a source file, or nil. A path key (the full source
+// path, or the path relative to sourceDir) wins over a file name key.
+func (o *dateOverrides) lookup(sourcePath, sourceDir string) *DateInfo {
+	dateInfo, ok := o.byPath[filepath.Clean(sourcePath)]
+	if !ok {
+		if rel, err := filepath.Rel(sourceDir, sourcePath); err == nil {
+			dateInfo, ok = o.byPath[rel]
+		}
+	}
+	if !ok {
+		dateInfo, ok = o.byName[filepath.Base(sourcePath)]
+	}
+	if !ok {
+		return nil
+	}
+	dateInfo.Original = filepath.Base(sourcePath)
+	return &dateInfo
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+)
+
+// Dedupe report output formats
+const (
+	DedupeFormatText = "text" // Human-readable groups with a summary
+	DedupeFormatTSV  = "tsv"  // One "hash<TAB>size<TAB>path" line per duplicate file, groups separated by hash
+)
+
+// duplicateGroup is a set of destination files with identical content
+type duplicateGroup struct {
+	Hash  string
+	Size  int64
+	Paths []string
+}
+
+// reclaimable is the space freed by keeping only one file of the group
+func (g duplicateGroup) reclaimable() int64 {
+	return g.Size * int64(len(g.Paths)-1)
+}
+
+// DedupeReport walks the destination, hashes every file and writes groups of
+// duplicates to w. Nothing is deleted.
+func (p *PhotoProcessor) DedupeReport(w io.Writer) error {
+	var walker Source = localSource{pruneDirs: p.junkDirs()}
+	switch {
+	case p.config.S3Bucket != "":
+		return fmt.Errorf("dedupe report is not supported for S3 destinations")
+	case p.config.RemoteDest:
+		if p.config.DestSSHHost == "" {
+			return fmt.Errorf("remote destination requires -dest-ssh-host or -ssh-host")
+		}
+		client, err := NewSSHClient(p.config.DestSSHHost, p.config.DestSSHPort)
+		if err != nil {
+			return fmt.Errorf("failed to create SSH client for destination: %w", err)
+		}
+		defer client.Close()
+		client.StartKeepalive(p.config.SSHKeepalive)
+		walker = sshSource{client: client, parallelism: p.config.ParallelWalks, pruneDirs: p.junkDirs()}
+	}
+
+	files, err := walker.Walk(p.config.DestDir)
+	if err != nil {
+		return fmt.Errorf("failed to walk destination: %w", err)
+	}
+	log.Printf("Hashing %d destination files with %s", len(files), hashAlgoName(p.config.HashAlgo))
+
+	groups := make(map[string]*duplicateGroup)
+	for i, file := range files {
+		sum, size, err := hashSourceFile(walker, file.Path, p.config.HashAlgo)
+		if err != nil {
+			log.Printf("Warning: failed to hash %s: %v", file.Path, err)
+			continue
+		}
+
+		// Identical hashes with different sizes can't be the same content
+		key := fmt.Sprintf("%s:%d", sum, size)
+		if groups[key] == nil {
+			groups[key] = &duplicateGroup{Hash: sum, Size: size}
+		}
+		groups[key].Paths = append(groups[key].Paths, file.Path)
+
+		if p.config.Verbose && (i+1)%100 == 0 {
+			log.Printf("Hashed %d/%d files", i+1, len(files))
+		}
+	}
+
+	var duplicates []duplicateGroup
+	for _, g := range groups {
+		if len(g.Paths) > 1 {
+			sort.Strings(g.Paths)
+			duplicates = append(duplicates, *g)
+		}
+	}
+	// Biggest savings first
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].reclaimable() != duplicates[j].reclaimable() {
+			return duplicates[i].reclaimable() > duplicates[j].reclaimable()
+		}
+		return duplicates[i].Paths[0] < duplicates[j].Paths[0]
+	})
+
+	if p.config.DedupeReportFormat == DedupeFormatTSV {
+		for _, g := range duplicates {
+			for _, path := range g.Paths {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", g.Hash, g.Size, path)
+			}
+		}
+		return nil
+	}
+
+	var total int64
+	for _, g := range duplicates {
+		fmt.Fprintf(w, "%s (%s each, %d copies)\n", g.Hash, formatBytes(g.Size), len(g.Paths))
+		for _, path := range g.Paths {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+		total += g.reclaimable()
+	}
+	fmt.Fprintf(w, "\n%d duplicate groups, %s reclaimable\n", len(duplicates), formatBytes(total))
+	return nil
+}
+
+// hashSourceFile streams a file from a source through the hashing helper
+func hashSourceFile(src Source, path, algo string) (string, int64, error) {
+	r, err := src.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer r.Close()
+	return HashReader(r, algo)
+}
+
+// hashAlgoName returns the effective hash algorithm name
+func hashAlgoName(algo string) string {
+	if algo == "" {
+		return DefaultHashAlgo
+	}
+	return algo
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
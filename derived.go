@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// derivedPreviewOriginals maps an extension to the extensions of originals it is
+// usually exported from. Phones set to "most compatible" save a JPEG next to the
+// HEIC/HEIF original with the same name, so organizing both would duplicate the photo.
+var derivedPreviewOriginals = map[string][]string{
+	".jpg":  {".heic", ".heif"},
+	".jpeg": {".heic", ".heif"},
+}
+
+// derivedPreviewDirs are directories that only hold generated thumbnails (e.g. Android's DCIM/.thumbnails)
+var derivedPreviewDirs = []string{".thumbnails", ".thumbs"}
+
+// derivedPreviewSuffixes mark exported previews and thumbnails by name, e.g. IMG_1234_thumb.jpg
+var derivedPreviewSuffixes = []string{"_thumb", "-thumb", ".thumb", "_thumbnail", "-thumbnail", "_preview", "-preview", ".preview"}
+
+// findDerivedPreviews returns the media files that are previews or thumbnails of another file
+// exts overrides a file's extension (e.g. after content detection); missing entries use the name
+func findDerivedPreviews(mediaFiles []string, exts map[string]string) map[string]bool {
+	extOf := func(path string) string {
+		if ext, ok := exts[path]; ok {
+			return strings.ToLower(ext)
+		}
+		return strings.ToLower(filepath.Ext(path))
+	}
+
+	// Index every media file's extensions by directory and case-insensitive stem
+	stems := make(map[string]map[string]bool)
+	stemKey := func(path string) string {
+		return strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))
+	}
+	for _, path := range mediaFiles {
+		key := stemKey(path)
+		if stems[key] == nil {
+			stems[key] = make(map[string]bool)
+		}
+		stems[key][extOf(path)] = true
+	}
+
+	derived := make(map[string]bool)
+	for _, path := range mediaFiles {
+		if isDerivedPreviewName(path) {
+			derived[path] = true
+			continue
+		}
+		for _, originalExt := range derivedPreviewOriginals[extOf(path)] {
+			if stems[stemKey(path)][originalExt] {
+				derived[path] = true
+				break
+			}
+		}
+	}
+	return derived
+}
+
+// isDerivedPreviewName reports whether a path looks like a generated thumbnail or preview
+func isDerivedPreviewName(path string) bool {
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		for _, previewDir := range derivedPreviewDirs {
+			if strings.EqualFold(dir, previewDir) {
+				return true
+			}
+		}
+	}
+
+	stem := strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	for _, suffix := range derivedPreviewSuffixes {
+		if strings.HasSuffix(stem, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Destination abstracts where organized files are written (local filesystem, SSH host or object storage)
+type Destination interface {
+	// Exists reports whether a file exists at destPath
+	Exists(destPath string) (bool, error)
+	// MkdirAll creates a directory and any missing parents
+	MkdirAll(dir string) error
+	// Write stores the contents of a local file at destPath
+	Write(localPath, destPath string) error
+	// Update runs fn against a local copy of the file at destPath and stores the result back
+	// If fn returns an error the destination is left untouched
+	Update(destPath string, fn func(localPath string) error) error
+	// Fetch makes a destination file available locally, returning its local path
+	// and a cleanup function to call once the local copy is no longer needed
+	Fetch(destPath string) (string, func(), error)
+	// SetModTime sets the file's modification time to t's wall clock in the destination's time zone
+	SetModTime(destPath string, t time.Time) error
+	// SetAttrs applies a source file's permission bits, and its owner where the destination allows it
+	SetAttrs(destPath string, attrs fileAttrs) error
+}
+
+// inPlaceStager is a Destination that can prepare a file right next to where it goes,
+// so a file is copied once instead of through the system temp folder
+type inPlaceStager interface {
+	// Stage creates an empty partial file for destPath and returns its local path
+	Stage(destPath string) (string, error)
+	// Commit moves a partial file from Stage into place at destPath
+	Commit(partialPath, destPath string) error
+}
+
+// localDestination writes to the local filesystem
+type localDestination struct {
+	dirMode  os.FileMode // Mode for created directories
+	fileMode os.FileMode // Mode for written files (0 keeps the default)
+}
+
+// Exists reports whether a local file exists
+func (localDestination) Exists(destPath string) (bool, error) {
+	_, err := os.Stat(destPath)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// MkdirAll creates a local directory tree, applying dirMode to every directory it creates
+func (d localDestination) MkdirAll(dir string) error {
+	// Remember which directories are new so only those get chmod'ed
+	var created []string
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+		created = append(created, p)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(dir, d.dirMode); err != nil {
+		return err
+	}
+
+	// MkdirAll is subject to the umask, so set the mode explicitly
+	for _, p := range created {
+		if err := os.Chmod(p, d.dirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write copies a local file into place. The copy is staged under a hidden partial name
+// in the destination folder and renamed over destPath once complete, so destPath only
+// ever holds a whole file; a failed copy removes the partial file.
+func (d localDestination) Write(localPath, destPath string) error {
+	partialPath := filepath.Join(filepath.Dir(destPath), "."+filepath.Base(destPath)+".partial")
+	if err := d.stage(localPath, partialPath); err != nil {
+		os.Remove(partialPath)
+		return err
+	}
+	if err := os.Rename(partialPath, destPath); err != nil {
+		os.Remove(partialPath)
+		return err
+	}
+	return nil
+}
+
+// stage copies a local file to partialPath with the destination's file mode
+func (d localDestination) stage(localPath, partialPath string) error {
+	if err := copyFile(localPath, partialPath); err != nil {
+		return err
+	}
+	if d.fileMode != 0 {
+		return os.Chmod(partialPath, d.fileMode)
+	}
+	return nil
+}
+
+// stageCounter numbers the partial files Stage creates, so concurrent writes of one name don't collide
+var stageCounter atomic.Uint64
+
+// Stage creates an empty hidden partial file next to destPath, keeping its extension so
+// metadata writers recognize the format, and returns its path
+func (localDestination) Stage(destPath string) (string, error) {
+	ext := filepath.Ext(destPath)
+	stem := strings.TrimSuffix(filepath.Base(destPath), ext)
+	for {
+		partialPath := filepath.Join(filepath.Dir(destPath), fmt.Sprintf(".%s.%d.partial%s", stem, stageCounter.Add(1), ext))
+		// Unlike os.CreateTemp this keeps the umask's default mode, as a plain copy would
+		f, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return partialPath, f.Close()
+	}
+}
+
+// Commit renames a partial file from Stage over destPath with the destination's file mode
+func (d localDestination) Commit(partialPath, destPath string) error {
+	if d.fileMode != 0 {
+		if err := os.Chmod(partialPath, d.fileMode); err != nil {
+			return err
+		}
+	}
+	return os.Rename(partialPath, destPath)
+}
+
+// Update edits the local file in place
+func (localDestination) Update(destPath string, fn func(localPath string) error) error {
+	return fn(destPath)
+}
+
+// Fetch returns the local path as-is
+func (localDestination) Fetch(destPath string) (string, func(), error) {
+	return destPath, func() {}, nil
+}
+
+// SetModTime sets the local file's access and modification times
+func (localDestination) SetModTime(destPath string, t time.Time) error {
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+	return os.Chtimes(destPath, local, local)
+}
+
+// SetAttrs sets the local file's mode and, when permitted, its owner
+// Changing the owner needs root (or CAP_CHOWN), so a permission error there is ignored
+func (localDestination) SetAttrs(destPath string, attrs fileAttrs) error {
+	if err := os.Chmod(destPath, attrs.mode); err != nil {
+		return err
+	}
+	if attrs.uid < 0 || attrs.gid < 0 {
+		return nil
+	}
+	if err := os.Lchown(destPath, attrs.uid, attrs.gid); err != nil && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return nil
+}
+
+// sshDestination writes to a remote host over SSH
+type sshDestination struct {
+	client   *SSHClient
+	dirMode  os.FileMode // Mode for created directories
+	fileMode os.FileMode // Mode for written files (0 keeps the default)
+}
+
+// Exists reports whether a remote file exists
+func (d sshDestination) Exists(destPath string) (bool, error) {
+	return d.client.FileExists(destPath)
+}
+
+// MkdirAll creates a remote directory tree
+func (d sshDestination) MkdirAll(dir string) error {
+	return d.client.CreateDirectory(dir, d.dirMode)
+}
+
+// Write uploads a local file to the remote host
+func (d sshDestination) Write(localPath, destPath string) error {
+	if err := d.client.UploadFile(localPath, destPath); err != nil {
+		return err
+	}
+	if d.fileMode != 0 {
+		return d.client.Chmod(destPath, d.fileMode)
+	}
+	return nil
+}
+
+// Update downloads the remote file, runs fn on it and uploads the result
+func (d sshDestination) Update(destPath string, fn func(localPath string) error) error {
+	return updateViaTempFile(destPath, d.client.DownloadFile, d.client.UploadFile, fn)
+}
+
+// Fetch downloads the remote file to a temp file
+func (d sshDestination) Fetch(destPath string) (string, func(), error) {
+	return fetchViaTempFile(destPath, d.client.DownloadFile)
+}
+
+// SetModTime sets the remote file's access and modification times
+func (d sshDestination) SetModTime(destPath string, t time.Time) error {
+	return d.client.SetModTime(destPath, t)
+}
+
+// SetAttrs sets the remote file's mode; owners differ between hosts, so they aren't copied
+func (d sshDestination) SetAttrs(destPath string, attrs fileAttrs) error {
+	return d.client.Chmod(destPath, attrs.mode)
+}
+
+// s3Destination writes objects to S3-compatible storage
+type s3Destination struct {
+	client *S3Client
+}
+
+// Exists reports whether an object exists
+func (d s3Destination) Exists(destPath string) (bool, error) {
+	return d.client.FileExists(destPath)
+}
+
+// MkdirAll is a no-op since object storage has no directories
+func (d s3Destination) MkdirAll(dir string) error {
+	return nil
+}
+
+// Write uploads a local file as an object
+func (d s3Destination) Write(localPath, destPath string) error {
+	return d.client.UploadFile(localPath, destPath)
+}
+
+// Update downloads the object, runs fn on it and uploads the result
+func (d s3Destination) Update(destPath string, fn func(localPath string) error) error {
+	return updateViaTempFile(destPath, d.client.DownloadFile, d.client.UploadFile, fn)
+}
+
+// Fetch downloads the object to a temp file
+func (d s3Destination) Fetch(destPath string) (string, func(), error) {
+	return fetchViaTempFile(destPath, d.client.DownloadFile)
+}
+
+// SetModTime is unsupported since object modification times are set by the service
+func (d s3Destination) SetModTime(destPath string, t time.Time) error {
+	return fmt.Errorf("setting modification times is not supported for S3 destinations")
+}
+
+// SetAttrs is unsupported since objects have no permission bits
+func (d s3Destination) SetAttrs(destPath string, attrs fileAttrs) error {
+	return fmt.Errorf("preserving permissions is not supported for S3 destinations")
+}
+
+// updateViaTempFile implements Update for destinations that can't be edited in place
+func updateViaTempFile(destPath string, download, upload func(string, string) error, fn func(string) error) error {
+	tempFile, err := os.CreateTemp("", "photo-dest-*"+filepath.Ext(destPath))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for dest: %w", err)
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempPath)
+
+	if err := download(destPath, tempPath); err != nil {
+		return fmt.Errorf("failed to download dest file: %w", err)
+	}
+
+	if err := fn(tempPath); err != nil {
+		return err
+	}
+
+	if err := upload(tempPath, destPath); err != nil {
+		return fmt.Errorf("failed to upload updated file: %w", err)
+	}
+	return nil
+}
+
+// fetchViaTempFile implements Fetch for destinations that aren't on the local filesystem
+func fetchViaTempFile(destPath string, download func(string, string) error) (string, func(), error) {
+	tempFile, err := os.CreateTemp("", "photo-dest-*"+filepath.Ext(destPath))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file for dest: %w", err)
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	cleanup := func() { os.Remove(tempPath) }
+
+	if err := download(destPath, tempPath); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download dest file: %w", err)
+	}
+	return tempPath, cleanup, nil
+}
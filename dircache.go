@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// dirCachingDestination wraps a Destination so each directory is created at most once
+// per run. Every file in a folder asks for its directory; without the cache each of
+// those is a mkdir -p round trip on a remote destination. Concurrent requests for the
+// same directory wait for the first one instead of racing it.
+type dirCachingDestination struct {
+	Destination
+	mutex sync.Mutex           // Protects dirs
+	dirs  map[string]*dirEntry // Directories created, or being created, by this run
+}
+
+// dirEntry is one directory in the cache
+type dirEntry struct {
+	mutex   sync.Mutex // Held while the directory is being created
+	created bool
+}
+
+// newDirCachingDestination wraps dest with a directory cache
+func newDirCachingDestination(dest Destination) *dirCachingDestination {
+	return &dirCachingDestination{Destination: dest, dirs: make(map[string]*dirEntry)}
+}
+
+// MkdirAll creates dir unless this run already has. A failure isn't cached, so the
+// next file in the directory tries again.
+func (d *dirCachingDestination) MkdirAll(dir string) error {
+	dir = filepath.Clean(dir)
+	entry := d.entry(dir)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	if entry.created {
+		return nil
+	}
+
+	if err := d.Destination.MkdirAll(dir); err != nil {
+		return err
+	}
+	entry.created = true
+
+	// Creating dir created its parents too
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		d.markCreated(parent)
+	}
+	return nil
+}
+
+// entry returns the cache entry of dir, adding it if needed
+func (d *dirCachingDestination) entry(dir string) *dirEntry {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	entry, ok := d.dirs[dir]
+	if !ok {
+		entry = &dirEntry{}
+		d.dirs[dir] = entry
+	}
+	return entry
+}
+
+// markCreated records that dir exists without creating it
+func (d *dirCachingDestination) markCreated(dir string) {
+	entry := d.entry(dir)
+	entry.mutex.Lock()
+	entry.created = true
+	entry.mutex.Unlock()
+}
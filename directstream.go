@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// directStreamHeadBytes is how much of each source file is fetched locally to read its
+// metadata when files are streamed host to host. EXIF segments are at most 64KB.
+const directStreamHeadBytes = 256 * 1024
+
+// streamToDestination pipes a source file from the source host straight into the
+// destination host, then updates its dates there with the destination's exiftool.
+// The bytes never touch local disk, so unlike writeToDestination the source file
+// isn't available for local metadata edits. With writeDate false the dates are left alone.
+func (p *PhotoProcessor) streamToDestination(sourcePath, destPath string, timestamp time.Time, writeDate bool) error {
+	destDir := filepath.Dir(destPath)
+	if err := p.dest.MkdirAll(destDir); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
+	}
+
+	if err := p.streamVerified(sourcePath, destPath); err != nil {
+		return err
+	}
+	if p.config.FileMode != 0 {
+		if err := p.destClient.Chmod(destPath, p.config.FileMode); err != nil {
+			return err
+		}
+	}
+
+	if !writeDate {
+		// Leave the file's own dates alone
+	} else if !p.remoteExiftoolAvailable() {
+		p.needsMetadata(destPath)
+	} else if err := p.destClient.UpdateExifDate(destPath, timestamp); err != nil {
+		log.Printf("Warning: failed to update metadata for %s: %v", destPath, err)
+		p.needsMetadata(destPath)
+	} else {
+		p.count(&p.stats.UpdatedMetadata)
+	}
+
+	if err := p.setFileModifyDate(destPath, timestamp); err != nil {
+		return err
+	}
+	p.count(&p.stats.MovedFiles)
+
+	p.count(&p.stats.ProcessedFiles)
+	return nil
+}
+
+// remoteExiftoolAvailable reports whether the destination host has exiftool,
+// warning once if it doesn't
+func (p *PhotoProcessor) remoteExiftoolAvailable() bool {
+	p.remoteExiftoolOnce.Do(func() {
+		p.remoteExiftool = p.destClient.HasCommand("exiftool")
+		if !p.remoteExiftool {
+			log.Printf("Warning: exiftool not found on %s. Streamed files will keep their original EXIF metadata.", p.config.DestSSHHost)
+		}
+	})
+	return p.remoteExiftool
+}
@@ -1,26 +1,70 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/rwcarlsen/goexif/exif"
 	"github.com/rwcarlsen/goexif/mknote"
+	"github.com/rwcarlsen/goexif/tiff"
 )
 
 func init() {
 	// Register maker note handlers
 	exif.RegisterParsers(mknote.All...)
+	exif.RegisterParsers(extraFieldsParser{})
+}
+
+// BodySerialNumber is the EXIF 2.3 camera body serial number, which goexif doesn't map
+const BodySerialNumber exif.FieldName = "BodySerialNumber"
+
+// extraFieldsParser loads EXIF sub-IFD tags that goexif doesn't know about
+type extraFieldsParser struct{}
+
+// Parse implements exif.Parser
+func (extraFieldsParser) Parse(x *exif.Exif) error {
+	tag, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return nil
+	}
+	offset, err := tag.Int64(0)
+	if err != nil {
+		return nil
+	}
+
+	r := bytes.NewReader(x.Raw)
+	if _, err := r.Seek(offset, 0); err != nil {
+		return nil
+	}
+	dir, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+	if err != nil {
+		// Missing extra fields are not worth failing the decode over
+		return nil
+	}
+
+	x.LoadTags(dir, map[uint16]exif.FieldName{0xa431: BodySerialNumber}, false)
+	return nil
 }
 
 // ExifMetadata represents EXIF data for a photo
 type ExifMetadata struct {
 	DateTimeOriginal time.Time
+	Dates            map[string]time.Time // Every date tag found, keyed by DateTag constant
 	Make             string
 	Model            string
 	Width            int
 	Height           int
+	SerialNumber     string // Camera body serial number, if recorded
+	LensModel        string // Lens model, if recorded
+	HasGPS           bool   // Whether GPS coordinates were recorded
+	Latitude         float64
+	Longitude        float64
+
+	SubSecTimeOriginal string // Fraction of a second of DateTimeOriginal as recorded ("" if absent); DateTimeOriginal and Dates include it
 }
 
 // ReadExifData reads EXIF metadata from a photo file
@@ -33,17 +77,25 @@ func ReadExifData(filepath string) (*ExifMetadata, error) {
 
 	x, err := exif.Decode(f)
 	if err != nil {
+		// RAW files are TIFF-based, but some vendors' layouts defeat goexif
+		if isRawFile(filepath) {
+			return readRawExifFallback(filepath, &ExifMetadata{}), nil
+		}
 		// Many photos might not have EXIF data, which is okay
 		return &ExifMetadata{}, nil
 	}
 
 	metadata := &ExifMetadata{}
 
-	// Try to get DateTimeOriginal
+	// Try to get DateTimeOriginal; goexif drops its fraction of a second, so add it back
+	metadata.SubSecTimeOriginal = exifString(x, exif.SubSecTimeOriginal)
 	if tm, err := x.DateTime(); err == nil {
-		metadata.DateTimeOriginal = tm
+		metadata.DateTimeOriginal = tm.Add(parseSubSec(metadata.SubSecTimeOriginal))
 	}
 
+	// Get every other date tag, for CaptureTime
+	metadata.Dates = readExifDates(x)
+
 	// Try to get camera make
 	if make, err := x.Get(exif.Make); err == nil {
 		if val, err := make.StringVal(); err == nil {
@@ -58,6 +110,23 @@ func ReadExifData(filepath string) (*ExifMetadata, error) {
 		}
 	}
 
+	// Try to get the body serial number, falling back to maker note serials
+	for _, field := range []exif.FieldName{BodySerialNumber, mknote.SerialNumber, mknote.Nikon_SerialNO} {
+		if metadata.SerialNumber = exifString(x, field); metadata.SerialNumber != "" {
+			break
+		}
+	}
+
+	// Try to get lens model
+	metadata.LensModel = exifString(x, exif.LensModel)
+
+	// Try to get GPS coordinates (0,0 is what some cameras write without a fix)
+	if lat, long, err := x.LatLong(); err == nil && (lat != 0 || long != 0) {
+		metadata.HasGPS = true
+		metadata.Latitude = lat
+		metadata.Longitude = long
+	}
+
 	// Try to get image dimensions
 	if width, err := x.Get(exif.PixelXDimension); err == nil {
 		if val, err := width.Int(0); err == nil {
@@ -71,38 +140,128 @@ func ReadExifData(filepath string) (*ExifMetadata, error) {
 		}
 	}
 
+	if _, ok := metadata.CaptureTime(); isRawFile(filepath) && !ok {
+		return readRawExifFallback(filepath, metadata), nil
+	}
 	return metadata, nil
 }
 
-// UpdateExifDate updates the EXIF DateTimeOriginal field in a photo
-// Note: This is a placeholder. Updating EXIF data is complex and typically
-// requires external tools like exiftool
+// readRawExifFallback fills in what goexif couldn't read from a RAW file using exiftool,
+// keeping metadata unchanged if exiftool isn't installed or fails
+func readRawExifFallback(filePath string, metadata *ExifMetadata) *ExifMetadata {
+	fallback, err := ReadExifDataWithExiftool(filePath)
+	if err != nil {
+		return metadata
+	}
+
+	if metadata.DateTimeOriginal.IsZero() {
+		metadata.DateTimeOriginal = fallback.DateTimeOriginal
+	}
+	for tag, t := range fallback.Dates {
+		if _, ok := metadata.Dates[tag]; !ok {
+			if metadata.Dates == nil {
+				metadata.Dates = make(map[string]time.Time)
+			}
+			metadata.Dates[tag] = t
+		}
+	}
+	if metadata.Make == "" {
+		metadata.Make = fallback.Make
+	}
+	if metadata.Model == "" {
+		metadata.Model = fallback.Model
+	}
+	if metadata.SerialNumber == "" {
+		metadata.SerialNumber = fallback.SerialNumber
+	}
+	if metadata.LensModel == "" {
+		metadata.LensModel = fallback.LensModel
+	}
+	return metadata
+}
+
+// exifString returns a trimmed string tag value, or "" if the tag is missing or not a string
+func exifString(x *exif.Exif, field exif.FieldName) string {
+	tag, err := x.Get(field)
+	if err != nil {
+		return ""
+	}
+	val, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(val, "\x00"))
+}
+
+// UpdateExifDate updates the EXIF DateTimeOriginal, CreateDate and ModifyDate fields in a photo
+// JPEGs are written natively; other formats, and JPEGs the native writer can't
+// patch, fall back to exiftool. PDFs get their CreationDate and ModDate instead.
 func UpdateExifDate(filepath string, date time.Time) error {
-	// For now, we'll use exiftool as it's the most reliable way
-	// The actual implementation will shell out to exiftool
+	if isPDFFile(filepath) {
+		return updatePDFDateWithExiftool(filepath, date)
+	}
+	if err := writeJPEGExifDate(filepath, date); !errors.Is(err, errNativeExifUnsupported) {
+		return err
+	}
 	return updateExifWithExiftool(filepath, date)
 }
 
-// DetermineCorrectTimestamp decides which timestamp to use:
-// - If original EXIF/metadata has a timestamp and its year matches the parsed year, use original
-// - Otherwise, use the parsed date
-// Returns: (timestamp, isFromEXIF)
-func DetermineCorrectTimestamp(sourcePath string, parsedDate *DateInfo) (time.Time, bool) {
-	var originalTimestamp time.Time
-	var hasTimestamp bool
+// VerifyExifDate re-reads a file's timestamp and checks it matches the intended date to the second
+func VerifyExifDate(filePath string, date time.Time) error {
+	var written time.Time
+	if isVideoFile(filePath) || isPDFFile(filePath) {
+		tm, ok := ReadTimestampWithExiftool(filePath)
+		if !ok {
+			return fmt.Errorf("no timestamp found after write")
+		}
+		written = tm
+	} else {
+		exifData, err := ReadExifData(filePath)
+		if err != nil {
+			return err
+		}
+		if exifData.DateTimeOriginal.IsZero() {
+			return fmt.Errorf("no DateTimeOriginal found after write")
+		}
+		written = exifData.DateTimeOriginal
+	}
 
+	// Compare wall-clock values since EXIF dates carry no reliable time zone
+	const layout = "2006-01-02 15:04:05"
+	if written.Format(layout) != date.Format(layout) {
+		return fmt.Errorf("timestamp mismatch after write: wanted %s, found %s", date.Format(layout), written.Format(layout))
+	}
+	return nil
+}
+
+// ReadCaptureTime reads the original capture time from a file's metadata,
+// using exiftool for videos and the EXIF library for images
+func ReadCaptureTime(sourcePath string) (time.Time, bool) {
 	// Check if it's a video file - use exiftool for videos
 	if isVideoFile(sourcePath) {
-		originalTimestamp, hasTimestamp = ReadTimestampWithExiftool(sourcePath)
-	} else {
-		// For images, use the EXIF library first (faster)
-		exifData, err := ReadExifData(sourcePath)
-		if err == nil && !exifData.DateTimeOriginal.IsZero() {
-			originalTimestamp = exifData.DateTimeOriginal
-			hasTimestamp = true
-		}
+		return ReadTimestampWithExiftool(sourcePath)
+	}
+
+	// PDFs have no EXIF; their Info dictionary dates are usually when they were scanned
+	if isPDFFile(sourcePath) {
+		return time.Time{}, false
 	}
 
+	// For images, use the EXIF library first (faster), taking the first plausible
+	// date tag in priority order
+	exifData, err := ReadExifData(sourcePath)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return exifData.CaptureTime()
+}
+
+// DetermineCorrectTimestamp decides which timestamp to use:
+// - If original EXIF/metadata has a timestamp and its year matches the parsed year, use original
+// - Otherwise, use the parsed date
+// Returns: (timestamp, isFromEXIF)
+func DetermineCorrectTimestamp(sourcePath string, parsedDate *DateInfo) (time.Time, bool) {
+	originalTimestamp, hasTimestamp := ReadCaptureTime(sourcePath)
 	if !hasTimestamp {
 		// No metadata, use parsed date
 		return parsedDate.ToTime(), false
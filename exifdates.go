@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// EXIF date tags a capture time can be read from
+const (
+	DateTagOriginal  = "DateTimeOriginal"
+	DateTagDigitized = "DateTimeDigitized" // exiftool's CreateDate
+	DateTagModified  = "DateTime"          // IFD0 DateTime, exiftool's ModifyDate
+	DateTagGPS       = "GPSDateTime"       // GPSDateStamp and GPSTimeStamp, recorded in UTC
+)
+
+// DefaultExifDateTags is the order EXIF date tags are consulted in unless Config.ExifDateTags is set
+var DefaultExifDateTags = []string{DateTagOriginal, DateTagDigitized, DateTagModified, DateTagGPS}
+
+// exifDateTagAliases maps lowercased tag names, including exiftool's, to their DateTag constant
+var exifDateTagAliases = map[string]string{
+	"datetimeoriginal":  DateTagOriginal,
+	"datetimedigitized": DateTagDigitized,
+	"createdate":        DateTagDigitized,
+	"datetime":          DateTagModified,
+	"modifydate":        DateTagModified,
+	"gpsdatetime":       DateTagGPS,
+	"gpsdatestamp":      DateTagGPS,
+}
+
+// exifDateTags is the order EXIF date tags are actually consulted in (Config.ExifDateTags overrides it)
+var exifDateTags = DefaultExifDateTags
+
+// DefaultClockDates are days cameras reset their clock to when the backup battery dies:
+// the Unix and DOS epochs and the starts of 2000 and 2001. EXIF dates on these days are
+// treated as missing unless Config.DefaultClockDates says otherwise.
+var DefaultClockDates = []string{"1970-01-01", "1980-01-01", "2000-01-01", "2001-01-01"}
+
+// defaultClockDates holds the days, as "YYYY-MM-DD", whose EXIF dates are ignored
+// (Config.DefaultClockDates overrides it)
+var defaultClockDates = DefaultClockDates
+
+// validateClockDates checks that every default clock date is a YYYY-MM-DD day
+func validateClockDates(days []string) error {
+	for _, day := range days {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return fmt.Errorf("invalid default clock date %q (use YYYY-MM-DD)", day)
+		}
+	}
+	return nil
+}
+
+// isDefaultClockDate reports whether t falls on a day a reset camera clock starts from,
+// so it says nothing about when the photo was taken
+func isDefaultClockDate(t time.Time) bool {
+	day := t.Format("2006-01-02")
+	for _, bogus := range defaultClockDates {
+		if day == bogus {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalExifDateTags maps configured tag names to DateTag constants
+func canonicalExifDateTags(names []string) ([]string, error) {
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag, ok := exifDateTagAliases[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown EXIF date tag %q (use %s)", name, strings.Join(DefaultExifDateTags, ", "))
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// CaptureTime returns the first plausible date among the metadata's date tags, in
+// exifDateTags order. Dates from a reset camera clock don't count.
+func (m *ExifMetadata) CaptureTime() (time.Time, bool) {
+	for _, tag := range exifDateTags {
+		if t, ok := m.Dates[tag]; ok && validYear(t.Year()) && !isDefaultClockDate(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// exifSubSecFields are the tags holding the fraction of a second of each date tag;
+// burst frames often differ only there
+var exifSubSecFields = map[string]exif.FieldName{
+	DateTagOriginal:  exif.SubSecTimeOriginal,
+	DateTagDigitized: exif.SubSecTimeDigitized,
+	DateTagModified:  exif.SubSecTime,
+}
+
+// parseSubSec converts a SubSec tag value, the decimal digits after the point ("5" is
+// half a second, "050" 50ms), to a duration; anything else counts as no fraction
+func parseSubSec(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > 9 || strings.Trim(value, "0123456789") != "" {
+		return 0
+	}
+	ns, _ := strconv.Atoi((value + "000000000")[:9])
+	return time.Duration(ns)
+}
+
+// formatSubSec returns the fraction of a second of t as SubSec tag digits, without
+// trailing zeros ("0" for a whole second)
+func formatSubSec(t time.Time) string {
+	digits := strings.TrimRight(fmt.Sprintf("%09d", t.Nanosecond()), "0")
+	if digits == "" {
+		return "0"
+	}
+	return digits
+}
+
+// readExifDates reads every date tag present in x, with its SubSec fraction. Local times are taken to be in the
+// camera's time zone when a maker note records it, otherwise the local time zone;
+// the GPS time is converted from UTC to the local time zone.
+func readExifDates(x *exif.Exif) map[string]time.Time {
+	zone := time.Local
+	if tz, _ := x.TimeZone(); tz != nil {
+		zone = tz
+	}
+
+	dates := make(map[string]time.Time)
+	fields := map[string]exif.FieldName{
+		DateTagOriginal:  exif.DateTimeOriginal,
+		DateTagDigitized: exif.DateTimeDigitized,
+		DateTagModified:  exif.DateTime,
+	}
+	for tag, field := range fields {
+		if t, err := time.ParseInLocation("2006:01:02 15:04:05", exifString(x, field), zone); err == nil {
+			dates[tag] = t.Add(parseSubSec(exifString(x, exifSubSecFields[tag])))
+		}
+	}
+	if t, ok := exifGPSTime(x); ok {
+		dates[DateTagGPS] = t.In(time.Local)
+	}
+	return dates
+}
+
+// exifGPSTime combines GPSDateStamp ("YYYY:MM:DD") and GPSTimeStamp (hours, minutes
+// and seconds as rationals) into a UTC time
+func exifGPSTime(x *exif.Exif) (time.Time, bool) {
+	day, err := time.Parse("2006:01:02", exifString(x, exif.GPSDateStamp))
+	if err != nil {
+		return time.Time{}, false
+	}
+	tag, err := x.Get(exif.GPSTimeStamp)
+	if err != nil || tag.Count < 3 {
+		return time.Time{}, false
+	}
+
+	var parts [3]time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		num, den, err := tag.Rat2(i)
+		if err != nil || den == 0 {
+			return time.Time{}, false
+		}
+		parts[i] = time.Duration(float64(num) / float64(den) * float64(unit))
+	}
+	return day.Add(parts[0] + parts[1] + parts[2]).Truncate(time.Second), true
+}
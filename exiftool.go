@@ -1,15 +1,111 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
 var useDockerExiftool = false
 
+// DefaultExiftoolDockerImage is the Docker image used when exiftool isn't installed natively
+const DefaultExiftoolDockerImage = "exiftool/exiftool"
+
+// exiftoolDockerImage is the Docker image actually used (Config.ExiftoolDockerImage overrides it)
+var exiftoolDockerImage = DefaultExiftoolDockerImage
+
+// exiftoolBinary is the exiftool command run natively: a path (Config.ExiftoolPath) or a name looked up in PATH
+var exiftoolBinary = "exiftool"
+
+// exifOffset is the UTC offset ("+02:00") written to the OffsetTime tags alongside each
+// date, leaving the wall-clock time as it is (Config.ExifOffset; empty writes none)
+var exifOffset string
+
+// exiftoolTimeout bounds each exiftool run so a hung process can't block a worker forever (0 disables)
+var exiftoolTimeout time.Duration
+
+// errExiftoolTimeout is returned (wrapped) when an exiftool run is killed for taking too long
+var errExiftoolTimeout = errors.New("timed out")
+
+// runExiftool runs an exiftool (or Docker exiftool) command, killing it if it outlives
+// exiftoolTimeout, and returns its standard output. Errors include the command's stderr.
+func runExiftool(name string, args ...string) ([]byte, error) {
+	return runExiftoolWithTimeout(exiftoolTimeout, name, args...)
+}
+
+// runExiftoolWithTimeout is runExiftool with its own time limit (0 waits forever)
+func runExiftoolWithTimeout(timeout time.Duration, name string, args ...string) ([]byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Don't wait on pipes held open by children of a killed process
+	cmd.WaitDelay = 2 * time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, fmt.Errorf("%s %w after %s", name, errExiftoolTimeout, timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return output, fmt.Errorf("%w: %s", err, msg)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+// exiftoolDateFields are the date tags exiftool writes, kept equal so every reader agrees
+var exiftoolDateFields = []string{
+	"DateTimeOriginal",
+	"CreateDate",
+	"ModifyDate",
+}
+
+// exiftoolSubSecFields names the tag holding each date field's fraction of a second
+var exiftoolSubSecFields = map[string]string{
+	"DateTimeOriginal": "SubSecTimeOriginal",
+	"CreateDate":       "SubSecTimeDigitized",
+	"ModifyDate":       "SubSecTime",
+}
+
+// exiftoolOffsetFields names the tag holding each date field's UTC offset
+var exiftoolOffsetFields = map[string]string{
+	"DateTimeOriginal": "OffsetTimeOriginal",
+	"CreateDate":       "OffsetTimeDigitized",
+	"ModifyDate":       "OffsetTime",
+}
+
+// exiftoolDateArgs returns the exiftool arguments setting one date field and its
+// fraction of a second to date, so burst frames keep their order within a second,
+// and its UTC offset when exifOffset is set
+func exiftoolDateArgs(field string, date time.Time) []string {
+	args := []string{
+		fmt.Sprintf("-%s=%s", field, date.Format("2006:01:02 15:04:05")),
+		fmt.Sprintf("-%s=%s", exiftoolSubSecFields[field], formatSubSec(date)),
+	}
+	if exifOffset != "" {
+		args = append(args, fmt.Sprintf("-%s=%s", exiftoolOffsetFields[field], exifOffset))
+	}
+	return args
+}
+
 // updateExifWithExiftool uses the exiftool command to update EXIF metadata
 func updateExifWithExiftool(filePath string, date time.Time) error {
 	// Check if we should use Docker
@@ -18,28 +114,16 @@ func updateExifWithExiftool(filePath string, date time.Time) error {
 	}
 
 	// Check if exiftool is available natively
-	if _, err := exec.LookPath("exiftool"); err != nil {
-		return fmt.Errorf("exiftool not found in PATH. Please install it: %w", err)
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
+		return fmt.Errorf("exiftool not found (%s). Please install it: %w", exiftoolBinary, err)
 	}
 
-	// Format date for EXIF (YYYY:MM:DD HH:MM:SS)
-	dateStr := date.Format("2006:01:02 15:04:05")
-
 	// Update multiple date/time fields to ensure consistency
-	fields := []string{
-		"DateTimeOriginal",
-		"CreateDate",
-		"ModifyDate",
-	}
-
-	for _, field := range fields {
-		cmd := exec.Command("exiftool",
-			"-overwrite_original",
-			fmt.Sprintf("-%s=%s", field, dateStr),
-			filePath,
-		)
+	for _, field := range exiftoolDateFields {
+		args := append([]string{"-overwrite_original"}, exiftoolDateArgs(field, date)...)
+		_, err := runExiftool(exiftoolBinary, append(args, filePath)...)
 
-		if err := cmd.Run(); err != nil {
+		if err != nil {
 			return fmt.Errorf("failed to update %s: %w", field, err)
 		}
 	}
@@ -49,9 +133,6 @@ func updateExifWithExiftool(filePath string, date time.Time) error {
 
 // updateExifWithDocker uses Docker to run exiftool
 func updateExifWithDocker(filePath string, date time.Time) error {
-	// Format date for EXIF (YYYY:MM:DD HH:MM:SS)
-	dateStr := date.Format("2006:01:02 15:04:05")
-
 	// Get absolute path and directory
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
@@ -62,22 +143,16 @@ func updateExifWithDocker(filePath string, date time.Time) error {
 	filename := filepath.Base(absPath)
 
 	// Update multiple date/time fields to ensure consistency
-	fields := []string{
-		"DateTimeOriginal",
-		"CreateDate",
-		"ModifyDate",
-	}
-
-	for _, field := range fields {
-		cmd := exec.Command("docker", "run", "--rm",
+	for _, field := range exiftoolDateFields {
+		args := []string{"run", "--rm",
 			"-v", fmt.Sprintf("%s:/work", dir),
-			"exiftool/exiftool",
+			exiftoolDockerImage,
 			"-overwrite_original",
-			fmt.Sprintf("-%s=%s", field, dateStr),
-			fmt.Sprintf("/work/%s", filename),
-		)
+		}
+		args = append(args, exiftoolDateArgs(field, date)...)
+		_, err := runExiftool("docker", append(args, fmt.Sprintf("/work/%s", filename))...)
 
-		if err := cmd.Run(); err != nil {
+		if err != nil {
 			return fmt.Errorf("failed to update %s with Docker: %w", field, err)
 		}
 	}
@@ -85,72 +160,297 @@ func updateExifWithDocker(filePath string, date time.Time) error {
 	return nil
 }
 
+// copyMetadataWithExiftool copies all metadata tags from src to dst
+// Only native exiftool is used; without it the copy is skipped
+func copyMetadataWithExiftool(src, dst string) error {
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
+		return nil
+	}
+
+	if _, err := runExiftool(exiftoolBinary, "-overwrite_original", "-TagsFromFile", src, "-all:all", dst); err != nil {
+		return fmt.Errorf("exiftool failed: %w", err)
+	}
+	return nil
+}
+
+var (
+	exiftoolOnce      sync.Once
+	exiftoolAvailable bool
+)
+
 // checkExiftoolAvailable checks if exiftool is installed (native or Docker)
+// The check runs once; workers call this concurrently for every file
 func checkExiftoolAvailable() bool {
+	exiftoolOnce.Do(func() {
+		exiftoolAvailable = detectExiftool()
+	})
+	return exiftoolAvailable
+}
+
+// detectExiftool looks for native exiftool, then for the exiftool Docker image
+func detectExiftool() bool {
 	// First check for native exiftool
-	if _, err := exec.LookPath("exiftool"); err == nil {
+	if _, err := exec.LookPath(exiftoolBinary); err == nil {
 		return true
 	}
 
 	// Check if Docker is available
 	if _, err := exec.LookPath("docker"); err == nil {
 		// Test if we can use the exiftool Docker image
-		cmd := exec.Command("docker", "image", "inspect", "exiftool/exiftool")
+		cmd := exec.Command("docker", "image", "inspect", exiftoolDockerImage)
 		if cmd.Run() == nil {
+			log.Printf("Using Docker exiftool (image %s)", exiftoolDockerImage)
 			useDockerExiftool = true
 			return true
 		}
 
 		// Try to pull the image
-		fmt.Println("Pulling exiftool Docker image (this may take a moment)...")
-		cmd = exec.Command("docker", "pull", "exiftool/exiftool")
-		if cmd.Run() == nil {
-			useDockerExiftool = true
-			return true
+		if err := pullExiftoolImage(); err != nil {
+			log.Printf("Warning: failed to prepare Docker exiftool: %v", err)
+			return false
 		}
+		log.Printf("Using Docker exiftool (image %s, freshly pulled)", exiftoolDockerImage)
+		useDockerExiftool = true
+		return true
 	}
 
 	return false
 }
 
+// Docker image pulls are bounded and retried so a slow or flaky connection can't hang the run
+const (
+	dockerPullTimeout  = 5 * time.Minute
+	dockerPullAttempts = 3
+)
+
+// pullExiftoolImage pulls exiftoolDockerImage, retrying with a growing pause between attempts
+func pullExiftoolImage() error {
+	var err error
+	for attempt := 1; attempt <= dockerPullAttempts; attempt++ {
+		if attempt > 1 {
+			pause := time.Duration(attempt-1) * 5 * time.Second
+			log.Printf("Retrying Docker pull in %s (attempt %d of %d)", pause, attempt, dockerPullAttempts)
+			time.Sleep(pause)
+		}
+
+		log.Printf("Pulling exiftool Docker image %s (this may take a moment)...", exiftoolDockerImage)
+		ctx, cancel := context.WithTimeout(context.Background(), dockerPullTimeout)
+		var output []byte
+		output, err = exec.CommandContext(ctx, "docker", "pull", exiftoolDockerImage).CombinedOutput()
+		timedOut := ctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		switch {
+		case timedOut:
+			err = fmt.Errorf("docker pull %s timed out after %s", exiftoolDockerImage, dockerPullTimeout)
+		case err != nil:
+			err = fmt.Errorf("docker pull %s: %w: %s", exiftoolDockerImage, err, strings.TrimSpace(string(output)))
+		default:
+			return nil
+		}
+		log.Printf("Warning: %v", err)
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", dockerPullAttempts, err)
+}
+
 // ReadTimestampWithExiftool reads timestamp from any media file (image or video) using exiftool
 // Returns the timestamp and true if found, or zero time and false if not found
 func ReadTimestampWithExiftool(filePath string) (time.Time, bool) {
 	// Check if exiftool is available
-	if _, err := exec.LookPath("exiftool"); err != nil {
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
 		return time.Time{}, false
 	}
 
 	// Use exiftool to read DateTimeOriginal, CreateDate, or MediaCreateDate
 	// Try DateTimeOriginal first (standard for photos)
-	cmd := exec.Command("exiftool", "-DateTimeOriginal", "-CreateDate", "-MediaCreateDate", "-s", "-s", "-s", filePath)
-	output, err := cmd.Output()
+	output, err := runExiftool(exiftoolBinary, "-DateTimeOriginal", "-CreateDate", "-MediaCreateDate", "-s", "-s", "-s", filePath)
 	if err != nil || len(output) == 0 {
 		return time.Time{}, false
 	}
 
-	// Parse the first non-empty timestamp
+	// Parse the first non-empty timestamp that isn't from a reset clock
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+		if t, ok := parseExiftoolTime(line); ok && !isDefaultClockDate(t) {
+			return t, true
 		}
+	}
 
-		// Try multiple timestamp formats
-		formats := []string{
-			"2006:01:02 15:04:05",
-			"2006:01:02 15:04:05-07:00",
-			"2006:01:02 15:04:05Z",
-			"2006-01-02T15:04:05",
+	return time.Time{}, false
+}
+
+// parseExiftoolTime parses a date/time value as printed by exiftool
+func parseExiftoolTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	// Try multiple timestamp formats
+	formats := []string{
+		"2006:01:02 15:04:05",
+		"2006:01:02 15:04:05-07:00",
+		"2006:01:02 15:04:05Z",
+		"2006-01-02T15:04:05",
+	}
+
+	for _, format := range formats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t, true
 		}
+	}
+	return time.Time{}, false
+}
 
-		for _, format := range formats {
-			if t, err := time.Parse(format, line); err == nil {
-				return t, true
-			}
+// ReadExifDataWithExiftool reads the metadata goexif may not reach in vendor RAW
+// containers using exiftool -json. Only native exiftool is used.
+func ReadExifDataWithExiftool(filePath string) (*ExifMetadata, error) {
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
+		return nil, err
+	}
+
+	output, err := runExiftool(exiftoolBinary, "-json", "-DateTimeOriginal", "-CreateDate", "-ModifyDate", "-GPSDateTime", "-SubSecTimeOriginal", "-SubSecTimeDigitized", "-SubSecTime", "-Make", "-Model", "-SerialNumber", "-LensModel", filePath)
+	if err != nil {
+		return nil, err
+	}
+	// Serial numbers may come back as JSON numbers, so keep numbers as their original text
+	var results []map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(output))
+	decoder.UseNumber()
+	if err := decoder.Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to parse exiftool output: %w", err)
+	}
+	if len(results) == 0 {
+		return &ExifMetadata{}, nil
+	}
+
+	field := func(name string) string {
+		if v, ok := results[0][name]; ok {
+			return strings.TrimSpace(fmt.Sprint(v))
 		}
+		return ""
 	}
 
-	return time.Time{}, false
+	metadata := &ExifMetadata{
+		Make:         field("Make"),
+		Model:        field("Model"),
+		SerialNumber: field("SerialNumber"),
+		LensModel:    field("LensModel"),
+	}
+	metadata.SubSecTimeOriginal = field("SubSecTimeOriginal")
+	subSec := map[string]string{"DateTimeOriginal": "SubSecTimeOriginal", "CreateDate": "SubSecTimeDigitized", "ModifyDate": "SubSecTime"}
+	for _, name := range []string{"DateTimeOriginal", "CreateDate"} {
+		if t, ok := parseExiftoolTime(field(name)); ok {
+			metadata.DateTimeOriginal = t.Add(parseSubSec(field(subSec[name])))
+			break
+		}
+	}
+	metadata.Dates = make(map[string]time.Time)
+	for _, name := range []string{"DateTimeOriginal", "CreateDate", "ModifyDate", "GPSDateTime"} {
+		if t, ok := parseExiftoolTime(field(name)); ok {
+			metadata.Dates[exifDateTagAliases[strings.ToLower(name)]] = t.Add(parseSubSec(field(subSec[name])))
+		}
+	}
+	if t, ok := metadata.Dates[DateTagGPS]; ok {
+		metadata.Dates[DateTagGPS] = t.In(time.Local)
+	}
+	return metadata, nil
+}
+
+// processedTagConfig defines the custom XMP-pmeta:ProcessedBy tag for exiftool
+const processedTagConfig = `%Image::ExifTool::UserDefined = (
+    'Image::ExifTool::XMP::Main' => {
+        pmeta => { SubDirectory => { TagTable => 'Image::ExifTool::UserDefined::pmeta' } },
+    },
+);
+%Image::ExifTool::UserDefined::pmeta = (
+    GROUPS => { 0 => 'XMP', 1 => 'XMP-pmeta', 2 => 'Image' },
+    NAMESPACE => { 'pmeta' => 'https://github.com/redgoat650/picture-metadata/ns/1.0/' },
+    WRITABLE => 'string',
+    ProcessedBy => { },
+);
+1;
+`
+
+// withProcessedTagConfig writes the exiftool config defining the processed tag into dir
+// and calls fn with the config's path
+func withProcessedTagConfig(dir string, fn func(configPath string) error) error {
+	configFile, err := os.CreateTemp(dir, ".picture-metadata-exiftool-*.config")
+	if err != nil {
+		return fmt.Errorf("failed to create exiftool config: %w", err)
+	}
+	configPath := configFile.Name()
+	defer os.Remove(configPath)
+
+	_, err = configFile.WriteString(processedTagConfig)
+	configFile.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write exiftool config: %w", err)
+	}
+	return fn(configPath)
+}
+
+// writeProcessedTag records in XMP-pmeta:ProcessedBy that the file was organized by this tool
+func writeProcessedTag(filePath, value string) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	// Keep the config next to the file so a Docker exiftool can see it too
+	return withProcessedTagConfig(filepath.Dir(absPath), func(configPath string) error {
+		var err error
+		if useDockerExiftool {
+			dir := filepath.Dir(absPath)
+			_, err = runExiftool("docker", "run", "--rm",
+				"-v", fmt.Sprintf("%s:/work", dir),
+				exiftoolDockerImage,
+				"-config", "/work/"+filepath.Base(configPath),
+				"-overwrite_original",
+				"-XMP-pmeta:ProcessedBy="+value,
+				"/work/"+filepath.Base(absPath),
+			)
+		} else {
+			_, err = runExiftool(exiftoolBinary,
+				"-config", configPath,
+				"-overwrite_original",
+				"-XMP-pmeta:ProcessedBy="+value,
+				absPath,
+			)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write processed tag: %w", err)
+		}
+		return nil
+	})
+}
+
+// readProcessedTag returns the XMP-pmeta:ProcessedBy value of a file, or "" if it has none
+// Only native exiftool is used for reading
+func readProcessedTag(filePath string) string {
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
+		return ""
+	}
+
+	var value string
+	withProcessedTagConfig("", func(configPath string) error {
+		output, err := runExiftool(exiftoolBinary, "-config", configPath, "-XMP-pmeta:ProcessedBy", "-s", "-s", "-s", filePath)
+		if err == nil {
+			value = strings.TrimSpace(string(output))
+		}
+		return nil
+	})
+	return value
+}
+
+// exifOffsetRegex matches an EXIF UTC offset: a sign, hours and minutes, e.g. "+02:00" or "-05:30"
+var exifOffsetRegex = regexp.MustCompile(`^[+-](?:0\d|1[0-4]):[0-5]\d$`)
+
+// validateExifOffset checks an EXIF offset ("" writes none)
+func validateExifOffset(offset string) error {
+	if offset != "" && !exifOffsetRegex.MatchString(offset) {
+		return fmt.Errorf("invalid EXIF offset %q (use +HH:MM or -HH:MM, e.g. +02:00)", offset)
+	}
+	return nil
 }
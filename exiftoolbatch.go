@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// exiftoolBatchEntry is a file waiting for the batch exiftool run to date it
+type exiftoolBatchEntry struct {
+	path      string
+	timestamp time.Time
+	err       error         // The batch run's error, set before done is closed
+	done      chan struct{} // Closed once the batch holding the file has run
+}
+
+// exiftoolBatcher dates the files that workers hand it with one exiftool run per batch.
+// Each worker waits for its own file, so a batch runs once it holds Config.ExiftoolBatchSize
+// files or every worker still running is waiting on it, whichever comes first.
+type exiftoolBatcher struct {
+	size    int
+	tag     string // Processed tag written with each date ("" writes none)
+	verbose bool
+
+	mu      sync.Mutex
+	workers int // Workers that may still add to the batch; 0 runs each file on its own
+	pending []*exiftoolBatchEntry
+}
+
+// newExiftoolBatcher returns a batcher of up to size files, writing tag as the processed tag unless it's empty
+func newExiftoolBatcher(size int, tag string, verbose bool) *exiftoolBatcher {
+	return &exiftoolBatcher{size: size, tag: tag, verbose: verbose}
+}
+
+// batchesExiftool reports whether a file written to destPath is dated by the batch
+// exiftool run instead of on its own. JPEGs keep the native writer, which needs no
+// exiftool at all, and PDFs take different tags than the batch writes.
+func (p *PhotoProcessor) batchesExiftool(destPath string) bool {
+	return p.exiftoolBatch != nil && !isJPEGExt(filepath.Ext(destPath)) && !isPDFFile(destPath)
+}
+
+// batchTag returns the processed tag value the batch writes with each date ("" writes none)
+func (p *PhotoProcessor) batchTag() string {
+	if !p.config.TagProcessed {
+		return ""
+	}
+	return processedTagValue()
+}
+
+// setWorkers sets how many workers may add files to the batch
+func (b *exiftoolBatcher) setWorkers(n int) {
+	b.mu.Lock()
+	b.workers = n
+	b.mu.Unlock()
+}
+
+// workerDone records that a worker has stopped adding files, running the batch
+// if the workers left are all waiting on it
+func (b *exiftoolBatcher) workerDone() {
+	b.mu.Lock()
+	b.workers--
+	batch := b.takeIfReady()
+	b.mu.Unlock()
+	b.run(batch)
+}
+
+// date writes timestamp (and the processed tag) into the file at path with the next batch
+// run, returning once that has run. The error is the whole batch's.
+func (b *exiftoolBatcher) date(path string, timestamp time.Time) error {
+	entry := &exiftoolBatchEntry{path: path, timestamp: timestamp, done: make(chan struct{})}
+	b.mu.Lock()
+	b.pending = append(b.pending, entry)
+	batch := b.takeIfReady()
+	b.mu.Unlock()
+
+	b.run(batch)
+	<-entry.done
+	return entry.err
+}
+
+// takeIfReady empties and returns the pending files if they make a full batch or no
+// other worker can add to them (nil otherwise). b.mu must be held.
+func (b *exiftoolBatcher) takeIfReady() []*exiftoolBatchEntry {
+	if len(b.pending) == 0 || (len(b.pending) < b.size && len(b.pending) < max(b.workers, 1)) {
+		return nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// run dates a batch with one exiftool process and releases the workers waiting on it
+func (b *exiftoolBatcher) run(batch []*exiftoolBatchEntry) {
+	if len(batch) == 0 {
+		return
+	}
+	start := time.Now()
+	err := writeExiftoolBatch(batch, b.tag)
+	if b.verbose {
+		log.Printf("exiftool batch of %d files took %s", len(batch), time.Since(start).Round(time.Millisecond))
+	}
+	if err != nil {
+		log.Printf("Warning: exiftool batch of %d files failed, dating them one at a time: %v", len(batch), err)
+	}
+	for _, entry := range batch {
+		entry.err = err
+		close(entry.done)
+	}
+}
+
+// dateInBatch dates a temp copy with the batch exiftool run, re-reading it afterwards when
+// write verification is enabled. If the batch run fails, the file is dated (and tagged)
+// on its own, so one bad file doesn't cost the others their date.
+func (p *PhotoProcessor) dateInBatch(tempPath, destPath string, timestamp time.Time) error {
+	if err := p.exiftoolBatch.date(tempPath, timestamp); err != nil {
+		if err := p.updateMetadata(tempPath, timestamp); err != nil {
+			return err
+		}
+		if p.config.TagProcessed {
+			if err := writeProcessedTag(tempPath, processedTagValue()); err != nil {
+				log.Printf("Warning: failed to tag %s as processed: %v", destPath, err)
+			}
+		}
+		return nil
+	}
+
+	if p.config.VerifyExifWrite {
+		if err := VerifyExifDate(tempPath, timestamp); err != nil {
+			p.count(&p.stats.ExifVerifyFailures)
+			return fmt.Errorf("verification failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeExiftoolBatch writes each file's date, and tag as the processed tag unless it's
+// empty, with one exiftool process. The files go in an argument file (-@), each with its
+// own date tags and separated by -execute.
+func writeExiftoolBatch(batch []*exiftoolBatchEntry, tag string) error {
+	argFile, err := os.CreateTemp("", "photo-exiftool-*.args")
+	if err != nil {
+		return fmt.Errorf("failed to create argument file: %w", err)
+	}
+	defer os.Remove(argFile.Name())
+
+	w := bufio.NewWriter(argFile)
+	for i, entry := range batch {
+		if i > 0 {
+			fmt.Fprintln(w, "-execute")
+		}
+		for _, field := range exiftoolDateFields {
+			for _, arg := range exiftoolDateArgs(field, entry.timestamp) {
+				fmt.Fprintln(w, arg)
+			}
+		}
+		if tag != "" {
+			fmt.Fprintln(w, "-XMP-pmeta:ProcessedBy="+tag)
+		}
+		fmt.Fprintln(w, entry.path)
+	}
+	err = w.Flush()
+	if closeErr := argFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write argument file: %w", err)
+	}
+
+	run := func(args ...string) error {
+		args = append(args, "-@", argFile.Name(), "-common_args", "-overwrite_original")
+		// The timeout bounds each file, so the batch gets one per file
+		_, err := runExiftoolWithTimeout(exiftoolTimeout*time.Duration(len(batch)), exiftoolBinary, args...)
+		return err
+	}
+	if tag == "" {
+		return run()
+	}
+	// -config has to come first on the command line, ahead of the argument file
+	return withProcessedTagConfig(os.TempDir(), func(configPath string) error {
+		return run("-config", configPath)
+	})
+}
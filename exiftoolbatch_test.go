@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubExiftool points exiftoolBinary at a script that appends each argument file it is
+// given to the returned log, failing if destPath already exists, for the rest of the test
+func stubExiftool(t *testing.T, destPath string) string {
+	t.Helper()
+	dir := t.TempDir()
+	argsLog := filepath.Join(dir, "args")
+	stub := filepath.Join(dir, "exiftool")
+	script := fmt.Sprintf(`#!/bin/sh
+[ -e %q ] && exit 1
+while [ $# -gt 0 ]; do
+  [ "$1" = "-@" ] && { cat "$2"; echo "-run"; } >> %q
+  shift
+done
+`, destPath, argsLog)
+	if err := os.WriteFile(stub, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	binary := exiftoolBinary
+	t.Cleanup(func() { exiftoolBinary = binary })
+	exiftoolBinary = stub
+	return argsLog
+}
+
+func TestExiftoolBatchDatesBeforeWritingDestination(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	destPath := filepath.Join(destDir, "2020", "clip.mp4")
+	argsLog := stubExiftool(t, destPath)
+
+	source := filepath.Join(srcDir, "clip.mp4")
+	if err := os.WriteFile(source, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPhotoProcessor(&Config{DestDir: destDir, ExiftoolBatchSize: 10, TagProcessed: true})
+	p.dest = localDestination{dirMode: 0755}
+	p.exiftoolBatch = newExiftoolBatcher(10, p.batchTag(), false)
+
+	// Outside a worker pool nothing else can join the batch, so it runs right away
+	timestamp := time.Date(2020, 5, 17, 9, 30, 0, 0, time.UTC)
+	if err := p.writeToDestination(source, source, destPath, timestamp, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := os.ReadFile(destPath); err != nil || string(data) != "video" {
+		t.Fatalf("destination holds %q, %v; want the source", data, err)
+	}
+	args, err := os.ReadFile(argsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-DateTimeOriginal=2020:05:17 09:30:00", "-XMP-pmeta:ProcessedBy=" + processedTagValue()} {
+		if !strings.Contains(string(args), want+"\n") {
+			t.Errorf("argument file lacks %q:\n%s", want, args)
+		}
+	}
+	if p.stats.ProcessedFiles != 1 || p.stats.UpdatedMetadata != 1 || len(p.stats.NeedsMetadata) != 0 {
+		t.Errorf("stats = %d processed, %d updated, %d needing metadata; want 1, 1, 0",
+			p.stats.ProcessedFiles, p.stats.UpdatedMetadata, len(p.stats.NeedsMetadata))
+	}
+}
+
+func TestExiftoolBatchRunsOnceAllWorkersWait(t *testing.T) {
+	argsLog := stubExiftool(t, filepath.Join(t.TempDir(), "none"))
+
+	const workers = 3
+	b := newExiftoolBatcher(10, "", false)
+	b.setWorkers(workers)
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			errs <- b.date(fmt.Sprintf("file%d.mp4", i), time.Date(2020, 5, 17, 0, 0, i, 0, time.UTC))
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("workers still waiting on the batch")
+		}
+	}
+
+	args, err := os.ReadFile(argsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := strings.Count(string(args), "-run\n"); runs != 1 {
+		t.Errorf("exiftool ran %d times, want once for all workers", runs)
+	}
+	if files := strings.Count(string(args), ".mp4\n"); files != workers {
+		t.Errorf("batch held %d files, want %d", files, workers)
+	}
+}
+
+func TestExiftoolBatchRunsWhenWorkerFinishes(t *testing.T) {
+	stubExiftool(t, filepath.Join(t.TempDir(), "none"))
+
+	b := newExiftoolBatcher(10, "", false)
+	b.setWorkers(2)
+	done := make(chan error, 1)
+	go func() { done <- b.date("file.mp4", time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC)) }()
+
+	// The other worker runs out of files instead of joining the batch
+	for {
+		b.mu.Lock()
+		queued := len(b.pending)
+		b.mu.Unlock()
+		if queued == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	b.workerDone()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch never ran after the other worker finished")
+	}
+}
+
+// BenchmarkExiftoolBatch dates the same files with an exiftool run per date tag and with one
+// batch run, as -exiftool-batch-size does: go test -bench ExiftoolBatch -benchtime 5x
+func BenchmarkExiftoolBatch(b *testing.B) {
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
+		b.Skip("exiftool not installed")
+	}
+
+	const files = 50
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		b.Fatal(err)
+	}
+	dir := b.TempDir()
+	timestamp := time.Date(2020, 5, 17, 9, 30, 0, 0, time.UTC)
+	batch := make([]*exiftoolBatchEntry, files)
+	for i := range batch {
+		path := filepath.Join(dir, fmt.Sprintf("img%d.png", i))
+		if err := os.WriteFile(path, img.Bytes(), 0644); err != nil {
+			b.Fatal(err)
+		}
+		batch[i] = &exiftoolBatchEntry{path: path, timestamp: timestamp}
+	}
+
+	b.Run("per-file", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, entry := range batch {
+				if err := updateExifWithExiftool(entry.path, entry.timestamp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Milliseconds())/float64(b.N*files), "ms/file")
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := writeExiftoolBatch(batch, ""); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Milliseconds())/float64(b.N*files), "ms/file")
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileOwner returns -1, -1 since file ownership isn't available on this platform
+func fileOwner(info os.FileInfo) (uid, gid int) {
+	return -1, -1
+}
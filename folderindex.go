@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultFolderIndexName is the per-folder index file name used when none is configured
+const DefaultFolderIndexName = "index.json"
+
+// folderIndexEntry describes one file placed in a destination folder
+type folderIndexEntry struct {
+	Name      string `json:"name"`      // File name in the folder
+	Original  string `json:"original"`  // Source path the file was copied from
+	Date      string `json:"date"`      // Date parsed from the source name, YYYY-MM-DD
+	Timestamp string `json:"timestamp"` // Date written to the file's metadata, "YYYY-MM-DD HH:MM:SS"
+}
+
+// folderIndex is the JSON document written to each destination folder
+type folderIndex struct {
+	Files []folderIndexEntry `json:"files"`
+}
+
+// addToFolderIndex records a file written to the destination for its folder's index
+func (p *PhotoProcessor) addToFolderIndex(sourcePath, destPath string, dateInfo *DateInfo, timestamp time.Time) {
+	p.folderIndexMutex.Lock()
+	defer p.folderIndexMutex.Unlock()
+
+	dir := filepath.Dir(destPath)
+	p.folderIndexes[dir] = append(p.folderIndexes[dir], folderIndexEntry{
+		Name:      filepath.Base(destPath),
+		Original:  sourcePath,
+		Date:      fmt.Sprintf("%04d-%02d-%02d", dateInfo.Year, dateInfo.Month, dateInfo.Day),
+		Timestamp: timestamp.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// writeFolderIndexes writes the index of every folder that received files, once the run
+// has finished with it. Entries from an existing index are kept unless the file was
+// written again, so re-runs that skip existing files don't shrink the index.
+func (p *PhotoProcessor) writeFolderIndexes() {
+	name := p.config.FolderIndexName
+	if name == "" {
+		name = DefaultFolderIndexName
+	}
+
+	dirs := make([]string, 0, len(p.folderIndexes))
+	for dir := range p.folderIndexes {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		indexPath := filepath.Join(dir, name)
+		if err := p.writeFolderIndex(indexPath, p.folderIndexes[dir]); err != nil {
+			log.Printf("Warning: failed to write folder index %s: %v", indexPath, err)
+		} else if p.config.Verbose {
+			log.Printf("Wrote folder index: %s (%d new entries)", indexPath, len(p.folderIndexes[dir]))
+		}
+	}
+}
+
+// writeFolderIndex merges entries into the index at indexPath and stores it
+func (p *PhotoProcessor) writeFolderIndex(indexPath string, entries []folderIndexEntry) error {
+	byName := make(map[string]folderIndexEntry)
+
+	exists, err := p.dest.Exists(indexPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		localPath, cleanup, err := p.dest.Fetch(indexPath)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(localPath)
+		cleanup()
+		if err != nil {
+			return err
+		}
+		var existing folderIndex
+		if err := json.Unmarshal(data, &existing); err != nil {
+			log.Printf("Warning: replacing unreadable folder index %s: %v", indexPath, err)
+		}
+		for _, entry := range existing.Files {
+			byName[entry.Name] = entry
+		}
+	}
+	for _, entry := range entries {
+		byName[entry.Name] = entry
+	}
+
+	index := folderIndex{Files: make([]folderIndexEntry, 0, len(byName))}
+	for _, entry := range byName {
+		index.Files = append(index.Files, entry)
+	}
+	sort.Slice(index.Files, func(i, j int) bool { return index.Files[i].Name < index.Files[j].Name })
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp("", "photo-index-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for index: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(append(data, '\n'))
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temp index: %w", err)
+	}
+
+	return p.dest.Write(tempFile.Name(), indexPath)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxGeocodeDistanceKm is how far a photo may be from the nearest known city
+// before the place is considered unknown
+const maxGeocodeDistanceKm = 50
+
+// geoCity is a named location in the offline geocoding database
+type geoCity struct {
+	name string
+	lat  float64
+	lon  float64
+}
+
+// Geocoder maps coordinates to place names using an offline city database,
+// so no network calls are made during processing
+type Geocoder struct {
+	// cells buckets cities by whole-degree latitude/longitude for fast lookup
+	cells map[[2]int][]geoCity
+}
+
+// NewGeocoder loads a city database from a GeoNames dump (e.g. cities1000.txt from
+// https://download.geonames.org/export/dump/) or a simple "name<TAB>lat<TAB>lon" file
+func NewGeocoder(dbPath string) (*Geocoder, error) {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geocode database: %w", err)
+	}
+	defer f.Close()
+
+	g := &Geocoder{cells: make(map[[2]int][]geoCity)}
+	count := 0
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+
+		var name, latStr, lonStr string
+		switch {
+		case len(fields) >= 6:
+			// GeoNames: geonameid, name, asciiname, alternatenames, latitude, longitude, ...
+			name, latStr, lonStr = fields[2], fields[4], fields[5]
+			if name == "" {
+				name = fields[1]
+			}
+		case len(fields) == 3:
+			name, latStr, lonStr = fields[0], fields[1], fields[2]
+		default:
+			continue
+		}
+
+		lat, err1 := strconv.ParseFloat(latStr, 64)
+		lon, err2 := strconv.ParseFloat(lonStr, 64)
+		if err1 != nil || err2 != nil || name == "" {
+			continue
+		}
+
+		cell := geoCell(lat, lon)
+		g.cells[cell] = append(g.cells[cell], geoCity{name: name, lat: lat, lon: lon})
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read geocode database: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("geocode database %s contains no cities", dbPath)
+	}
+
+	return g, nil
+}
+
+// Lookup returns the name of the nearest city to the coordinates, or "" if none is close enough
+func (g *Geocoder) Lookup(lat, lon float64) string {
+	cell := geoCell(lat, lon)
+
+	best := ""
+	bestDist := math.Inf(1)
+	// One degree of latitude is ~111km, so neighbouring cells cover the search radius
+	for dLat := -1; dLat <= 1; dLat++ {
+		for dLon := -1; dLon <= 1; dLon++ {
+			for _, city := range g.cells[[2]int{cell[0] + dLat, cell[1] + dLon}] {
+				if d := haversineKm(lat, lon, city.lat, city.lon); d < bestDist {
+					best, bestDist = city.name, d
+				}
+			}
+		}
+	}
+
+	if bestDist > maxGeocodeDistanceKm {
+		return ""
+	}
+	return best
+}
+
+// geoCell returns the whole-degree bucket for a coordinate
+func geoCell(lat, lon float64) [2]int {
+	return [2]int{int(math.Floor(lat)), int(math.Floor(lon))}
+}
+
+// haversineKm returns the great-circle distance between two coordinates in kilometres
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// cleanPlaceName makes a place name safe for a directory name, joining words with sep
+// e.g. "Saint-Denis d'Oléron" -> "Saint_Denis_d_Oléron"
+func cleanPlaceName(name, sep string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, sep)
+}
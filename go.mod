@@ -5,8 +5,13 @@ go 1.24.0
 toolchain go1.24.11
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
 	golang.org/x/crypto v0.46.0
+	lukechampine.com/blake3 v1.4.1
 )
 
-require golang.org/x/sys v0.39.0 // indirect
+require (
+	github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+)
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// Supported content hash algorithms for verification and dedup
+const (
+	HashSHA256 = "sha256"
+	HashMD5    = "md5"
+	HashXXHash = "xxhash"
+	HashBLAKE3 = "blake3"
+)
+
+// DefaultHashAlgo is used when no hash algorithm is configured
+const DefaultHashAlgo = HashSHA256
+
+// newHasher returns a fresh hash.Hash for the named algorithm
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "", HashSHA256:
+		return sha256.New(), nil
+	case HashMD5:
+		return md5.New(), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	case HashBLAKE3:
+		return blake3.New(32, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %q (use %s, %s, %s or %s)", algo, HashSHA256, HashMD5, HashXXHash, HashBLAKE3)
+	}
+}
+
+// HashFile returns the hex-encoded hash of a file's contents using the named algorithm
+func HashFile(filePath, algo string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sum, _, err := HashReader(f, algo)
+	return sum, err
+}
+
+// HashReader returns the hex-encoded hash of everything read from r and the number of bytes read
+func HashReader(r io.Reader, algo string) (string, int64, error) {
+	h, err := newHasher(algo)
+	if err != nil {
+		return "", 0, err
+	}
+
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", n, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// heicDecoder converts a HEIC/HEIF file to JPEG using an external tool
+type heicDecoder struct {
+	name string
+	args func(src, dst string) []string
+}
+
+// heicDecoders are tried in order; the first one on the PATH is used
+// ImageMagick is asked for frame [0], the primary image, so it writes a single JPEG
+var heicDecoders = []heicDecoder{
+	{"heif-convert", func(src, dst string) []string { return []string{"-q", "92", src, dst} }},
+	{"magick", func(src, dst string) []string { return []string{src + "[0]", "-quality", "92", dst} }},
+	{"convert", func(src, dst string) []string { return []string{src + "[0]", "-quality", "92", dst} }},
+	{"sips", func(src, dst string) []string { return []string{"-s", "format", "jpeg", src, "--out", dst} }},
+}
+
+var (
+	heicDecoderOnce  sync.Once
+	heicDecoderFound *heicDecoder
+)
+
+// findHEICDecoder returns the first available HEIC decoder, or nil if none is installed
+func findHEICDecoder() *heicDecoder {
+	heicDecoderOnce.Do(func() {
+		for i := range heicDecoders {
+			if commandAvailable(heicDecoders[i].name) {
+				heicDecoderFound = &heicDecoders[i]
+				return
+			}
+		}
+	})
+	return heicDecoderFound
+}
+
+// isHEICExt reports whether ext is a HEIC/HEIF extension
+func isHEICExt(ext string) bool {
+	ext = strings.ToLower(ext)
+	return ext == ".heic" || ext == ".heif"
+}
+
+// heicAuxSuffixRegex matches the extra images heif-convert writes next to its output for a
+// container's depth map and auxiliary images, e.g. "primary-depth.jpg" or "primary-urn:com:apple:...jpg"
+var heicAuxSuffixRegex = regexp.MustCompile(`-(?:depth|urn[:_].*|aux.*)$`)
+
+// heicImageIndexRegex matches the numbered name a decoder gives each top-level image of
+// a multi-image container instead of the requested name, e.g. "primary-1.jpg"
+var heicImageIndexRegex = regexp.MustCompile(`-(\d+)$`)
+
+// convertHEICToJPG decodes a HEIC file to a JPEG and carries over its metadata. A HEIC
+// can be a container of several images (depth maps, HDR gain maps, burst frames), which
+// some decoders write out as extra files, so decoding happens in a scratch directory
+// and only the primary image becomes dst.
+func convertHEICToJPG(src, dst string) error {
+	decoder := findHEICDecoder()
+	if decoder == nil {
+		return fmt.Errorf("no HEIC decoder available")
+	}
+
+	scratchDir, err := os.MkdirTemp("", "photo-heic-*")
+	if err != nil {
+		return fmt.Errorf("failed to create HEIC scratch directory: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	out := filepath.Join(scratchDir, "primary.jpg")
+	output, err := exec.Command(decoder.name, decoder.args(src, out)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", decoder.name, err, strings.TrimSpace(string(output)))
+	}
+	primary, err := heicPrimaryOutput(scratchDir, out)
+	if err != nil {
+		return fmt.Errorf("%s: %w", decoder.name, err)
+	}
+	if err := os.Rename(primary, dst); err != nil {
+		// The scratch directory may be on another filesystem
+		if err := copyFile(primary, dst); err != nil {
+			return fmt.Errorf("failed to copy converted image: %w", err)
+		}
+	}
+
+	// Decoders don't all keep EXIF, so copy it explicitly when exiftool is around
+	if err := copyMetadataWithExiftool(src, dst); err != nil {
+		log.Printf("Warning: failed to copy metadata to %s: %v", filepath.Base(dst), err)
+	}
+	return nil
+}
+
+// heicPrimaryOutput finds the primary image among what a decoder wrote to dir when asked
+// for out: out itself, or else the lowest-numbered image, never a depth or auxiliary image
+func heicPrimaryOutput(dir, out string) (string, error) {
+	if _, err := os.Stat(out); err == nil {
+		return out, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	primary, lowest := "", -1
+	for _, entry := range entries {
+		stem := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if heicAuxSuffixRegex.MatchString(stem) {
+			continue
+		}
+		m := heicImageIndexRegex.FindStringSubmatch(stem)
+		if m == nil {
+			continue
+		}
+		if index, _ := strconv.Atoi(m[1]); lowest < 0 || index < lowest {
+			primary, lowest = filepath.Join(dir, entry.Name()), index
+		}
+	}
+	if primary == "" {
+		return "", fmt.Errorf("no image written")
+	}
+	return primary, nil
+}
+
+// copyOrConvert writes src to dst, transcoding HEIC to JPEG when dst is a .jpg
+func copyOrConvert(src, dst string) error {
+	if isHEICExt(filepath.Ext(src)) && strings.EqualFold(filepath.Ext(dst), ".jpg") {
+		return convertHEICToJPG(src, dst)
+	}
+	return copyFile(src, dst)
+}
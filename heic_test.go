@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// multiImageOutputs is what a decoder writes for a multi-image HEIC instead of the
+// requested primary.jpg: each top-level image numbered, plus auxiliary images
+var multiImageOutputs = map[string]string{
+	"primary-1.jpg":       "primary image",
+	"primary-2.jpg":       "second image",
+	"primary-10.jpg":      "tenth image",
+	"primary-1-depth.jpg": "depth map",
+
+	"primary-urn:com:apple:photo:2020:aux:hdrgainmap.jpg": "gain map",
+}
+
+func TestHEICPrimaryOutput(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range multiImageOutputs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "primary.jpg")
+	got, err := heicPrimaryOutput(dir, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != filepath.Join(dir, "primary-1.jpg") {
+		t.Errorf("primary output = %s, want primary-1.jpg", filepath.Base(got))
+	}
+
+	// The requested name wins when the decoder wrote it
+	if err := os.WriteFile(out, []byte("requested"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := heicPrimaryOutput(dir, out); err != nil || got != out {
+		t.Errorf("with primary.jpg present, primary output = %s, %v; want primary.jpg", got, err)
+	}
+
+	// Auxiliary images alone are no primary image
+	auxOnly := t.TempDir()
+	for _, name := range []string{"primary-depth.jpg", "primary-1-depth.jpg", "primary-urn:com:apple:photo:2020:aux:hdrgainmap.jpg", "primary-aux1.jpg"} {
+		if err := os.WriteFile(filepath.Join(auxOnly, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := heicPrimaryOutput(auxOnly, filepath.Join(auxOnly, "primary.jpg")); err == nil {
+		t.Errorf("only auxiliary images: primary output = %s, want an error", got)
+	}
+}
+
+// useFakeHEICDecoder puts a heif-convert on PATH that writes multiImageOutputs next to
+// the output it was asked for
+func useFakeHEICDecoder(t *testing.T) {
+	t.Helper()
+	binDir := t.TempDir()
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\ndir=$(dirname \"$4\")\n")
+	for name, data := range multiImageOutputs {
+		script.WriteString("printf '%s' '" + data + "' > \"$dir/" + name + "\"\n")
+	}
+	if err := os.WriteFile(filepath.Join(binDir, "heif-convert"), []byte(script.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	resetDecoder := func() {
+		heicDecoderOnce = sync.Once{}
+		heicDecoderFound = nil
+	}
+	resetDecoder()
+	t.Cleanup(resetDecoder)
+}
+
+func TestConvertMultiImageHEICWritesOnlyPrimary(t *testing.T) {
+	useFakeHEICDecoder(t)
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	src := filepath.Join(srcDir, "2018-10-21_beach.heic")
+	if err := os.WriteFile(src, []byte("heic container"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(destDir, "2018-10-21_beach.jpg")
+	if err := convertHEICToJPG(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "primary image" {
+		t.Errorf("converted file holds %q, want the primary image", data)
+	}
+	entries, _ := os.ReadDir(destDir)
+	if len(entries) != 1 {
+		t.Errorf("conversion left %d files in the destination, want 1", len(entries))
+	}
+}
+
+func TestMultiImageHEICYieldsOneOrganizedPhoto(t *testing.T) {
+	useFakeHEICDecoder(t)
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "2018-10-21_beach.heic"), []byte("heic container"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPhotoProcessor(&Config{SourceDir: srcDir, DestDir: destDir, ConvertHEICtoJPG: true, StripDatePrefix: true})
+	p.startTime = time.Now()
+	p.source = localSource{}
+	p.dest = newDirCachingDestination(localDestination{dirMode: 0755})
+	if err := p.walkDirectory(context.Background(), srcDir); err != nil {
+		t.Fatal(err)
+	}
+
+	var organized []string
+	filepath.WalkDir(destDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(destDir, path)
+			organized = append(organized, rel)
+		}
+		return nil
+	})
+	want := filepath.Join("2018", "2018-10", "2018-10-21_beach.jpg")
+	if len(organized) != 1 || organized[0] != want {
+		t.Fatalf("organized %v, want only %s", organized, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destDir, want)); string(data) != "primary image" {
+		t.Errorf("organized photo holds %q, want the primary image", data)
+	}
+	if p.stats.ProcessedFiles != 1 {
+		t.Errorf("ProcessedFiles = %d, want 1", p.stats.ProcessedFiles)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DefaultJunkDirs are system metadata directories skipped when walking unless Config.JunkDirs is set
+var DefaultJunkDirs = []string{"@eaDir", ".Trashes", ".Spotlight-V100"}
+
+// isPrunedDir reports whether a directory name is one of the junk directories
+func isPrunedDir(name string, junkDirs []string) bool {
+	for _, junk := range junkDirs {
+		if name == junk {
+			return true
+		}
+	}
+	return false
+}
+
+// isHiddenPath reports whether any part of path below root is hidden: a dotfile,
+// a macOS AppleDouble "._" file, or anything inside a dot-directory
+func isHiddenPath(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// junkDirs returns the directory names to prune while walking
+func (p *PhotoProcessor) junkDirs() []string {
+	if p.config.JunkDirs != nil {
+		return p.config.JunkDirs
+	}
+	return DefaultJunkDirs
+}
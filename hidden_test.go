@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIsHiddenPath(t *testing.T) {
+	root := "/photos"
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/photos/2018/IMG_0001.jpg", false},
+		{"/photos/2018/._IMG_0001.jpg", true},
+		{"/photos/._IMG_0001.jpg", true},
+		{"/photos/2018/.DS_Store.jpg", true},
+		{"/photos/.thumbnails/IMG_0001.jpg", true},
+		{"/photos/2018/trip..jpg", false},
+		{"/photos/2018/IMG_._0001.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := isHiddenPath(root, tt.path); got != tt.want {
+			t.Errorf("isHiddenPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// A hidden source root itself doesn't hide everything below it
+	if isHiddenPath("/mnt/.snapshot/photos", "/mnt/.snapshot/photos/IMG_0001.jpg") {
+		t.Error("a file below a hidden source root was treated as hidden")
+	}
+}
+
+func TestSkipHiddenDropsAppleDoubleFiles(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{
+		"2018/IMG_0001.jpg",
+		"2018/._IMG_0001.jpg",
+		".hidden/IMG_0002.jpg",
+		"2018/@eaDir/IMG_0001.jpg/SYNOPHOTO_THUMB_M.jpg",
+		".Trashes/501/IMG_0003.jpg",
+	} {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		skipHidden bool
+		want       int
+	}{
+		// Only 2018/IMG_0001.jpg; the junk directories are pruned either way
+		{true, 1},
+		// Plus .hidden/IMG_0002.jpg and 2018/._IMG_0001.jpg
+		{false, 3},
+	}
+	for _, tt := range tests {
+		p := NewPhotoProcessor(&Config{SourceDir: root, DestDir: t.TempDir(), SkipHidden: tt.skipHidden, DryRun: true})
+		p.startTime = time.Now()
+		p.source = localSource{pruneDirs: p.junkDirs()}
+		p.dest = localDestination{dirMode: 0755}
+		if err := p.walkDirectory(context.Background(), root); err != nil {
+			t.Fatal(err)
+		}
+		if p.stats.TotalFiles != tt.want {
+			t.Errorf("SkipHidden=%v found %d media files, want %d", tt.skipHidden, p.stats.TotalFiles, tt.want)
+		}
+	}
+}
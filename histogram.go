@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Histogram bucket sizes (Config.Histogram)
+const (
+	HistogramYear  = "year"
+	HistogramMonth = "month"
+	HistogramDay   = "day"
+)
+
+// Histogram output formats
+const (
+	HistogramFormatText = "text" // An aligned table with a bar per bucket
+	HistogramFormatCSV  = "csv"  // "bucket,count" rows for spreadsheets
+)
+
+// histogramBarWidth is the length of the longest bar in the text histogram
+const histogramBarWidth = 50
+
+// validateHistogram checks a histogram bucket size and output format ("" disables the histogram)
+func validateHistogram(bucket, format string) error {
+	switch bucket {
+	case "", HistogramYear, HistogramMonth, HistogramDay:
+	default:
+		return fmt.Errorf("unsupported histogram bucket %q (use %s, %s or %s)", bucket, HistogramYear, HistogramMonth, HistogramDay)
+	}
+	switch format {
+	case "", HistogramFormatText, HistogramFormatCSV:
+		return nil
+	default:
+		return fmt.Errorf("unsupported histogram format %q (use %s or %s)", format, HistogramFormatText, HistogramFormatCSV)
+	}
+}
+
+// histogramBucket names the bucket a date falls in, e.g. "2018", "2018-10" or "2018-10-21"
+func histogramBucket(d *DateInfo, bucket string) string {
+	switch bucket {
+	case HistogramYear:
+		return fmt.Sprintf("%04d", d.Year)
+	case HistogramDay:
+		return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+	default:
+		return fmt.Sprintf("%04d-%02d", d.Year, d.Month)
+	}
+}
+
+// Histogram walks the source, dates each media file from its name (and folders) as a
+// run would, and writes how many files fall in each year, month or day to w without
+// changing anything. Year and month histograms list empty buckets between the first
+// and last date too, so gaps in the collection stand out.
+func (p *PhotoProcessor) Histogram(w io.Writer) error {
+	closeSource, err := p.openSource()
+	if err != nil {
+		return err
+	}
+	defer closeSource()
+
+	files, err := p.source.Walk(p.config.SourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to walk source: %w", err)
+	}
+	mediaFiles := p.selectMediaFiles(p.config.SourceDir, files)
+	log.Printf("Dating %d media files from their names", len(mediaFiles))
+
+	counts := make(map[string]int)
+	var first, last time.Time
+	undated := 0
+	for _, path := range mediaFiles {
+		dateInfo, err := ParseDateFromFilenameWithOptions(path, p.parseOptions())
+		if err != nil {
+			undated++
+			continue
+		}
+		counts[histogramBucket(dateInfo, p.config.Histogram)]++
+		t := dateInfo.ToTime()
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+
+	var buckets []string
+	if p.config.Histogram == HistogramDay || len(counts) == 0 {
+		for bucket := range counts {
+			buckets = append(buckets, bucket)
+		}
+		sort.Strings(buckets)
+	} else {
+		step := func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+		start := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if p.config.Histogram == HistogramYear {
+			step = func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+			start = time.Date(first.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		for t := start; !t.After(last); t = step(t) {
+			buckets = append(buckets, histogramBucket(DateInfoFromTime(t, ""), p.config.Histogram))
+		}
+	}
+
+	if p.config.HistogramFormat == HistogramFormatCSV {
+		out := csv.NewWriter(w)
+		out.Write([]string{p.config.Histogram, "count"})
+		for _, bucket := range buckets {
+			out.Write([]string{bucket, strconv.Itoa(counts[bucket])})
+		}
+		out.Write([]string{"undated", strconv.Itoa(undated)})
+		out.Flush()
+		return out.Error()
+	}
+
+	most := 0
+	for _, n := range counts {
+		most = max(most, n)
+	}
+	for _, bucket := range buckets {
+		n := counts[bucket]
+		bar := ""
+		if n > 0 {
+			// Every non-empty bucket gets at least one mark so it can't be mistaken for a gap
+			bar = strings.Repeat("#", max(1, n*histogramBarWidth/most))
+		}
+		fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("%-10s %7d %s", bucket+":", n, bar), " "))
+	}
+	fmt.Fprintf(w, "\n%d dated files in %d buckets, %d undated\n", len(mediaFiles)-undated, len(counts), undated)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName marks files and folders to leave out of processing, like .gitignore
+const ignoreFileName = ".picmetaignore"
+
+// ignoreFileMaxSize bounds how much of an ignore file is read
+const ignoreFileMaxSize = 64 * 1024
+
+// ignorePattern is one rule from an ignore file
+type ignorePattern struct {
+	glob     string // path.Match pattern, slash-separated
+	anchored bool   // Contained a slash, so it matches the path relative to the ignore file's directory
+	dirOnly  bool   // Ended in a slash, so it only matches directories
+}
+
+// ignoreRules are the rules of one ignore file, which apply to its directory and everything below it
+type ignoreRules struct {
+	file     string // Path of the ignore file
+	dir      string // Directory holding the ignore file
+	patterns []ignorePattern
+}
+
+// loadIgnoreRules reads every ignore file among the walked files
+func loadIgnoreRules(src Source, files []SourceFile) []ignoreRules {
+	var rules []ignoreRules
+	for _, file := range files {
+		if filepath.Base(file.Path) != ignoreFileName {
+			continue
+		}
+		data, err := src.ReadHead(file.Path, ignoreFileMaxSize)
+		if err != nil {
+			log.Printf("Warning: failed to read %s: %v", file.Path, err)
+			continue
+		}
+		rules = append(rules, ignoreRules{file: file.Path, dir: filepath.Dir(file.Path), patterns: parseIgnorePatterns(data)})
+	}
+	return rules
+}
+
+// parseIgnorePatterns parses ignore file lines: one glob per line, blank lines and
+// lines starting with # are skipped
+func parseIgnorePatterns(data []byte) []ignorePattern {
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := ignorePattern{glob: filepath.ToSlash(line)}
+		if strings.HasSuffix(pattern.glob, "/") {
+			pattern.glob, pattern.dirOnly = strings.TrimRight(pattern.glob, "/"), true
+		}
+		if strings.Contains(pattern.glob, "/") {
+			pattern.glob, pattern.anchored = strings.TrimLeft(pattern.glob, "/"), true
+		}
+		if pattern.glob == "" {
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// ignoredBy returns the ignore file that excludes filePath, or "" if none does
+func ignoredBy(rules []ignoreRules, filePath string) string {
+	for _, r := range rules {
+		rel, err := filepath.Rel(r.dir, filePath)
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		// An ignore file without rules excludes its whole directory
+		if len(r.patterns) == 0 || r.matches(filepath.ToSlash(rel)) {
+			return r.file
+		}
+	}
+	return ""
+}
+
+// matches reports whether a slash-separated path relative to the rules' directory is excluded
+func (r ignoreRules) matches(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, pattern := range r.patterns {
+		for i := range parts {
+			isDir := i < len(parts)-1
+			if pattern.dirOnly && !isDir {
+				continue
+			}
+			// Anchored patterns match a leading run of components (a directory or the file itself);
+			// the others match any single component
+			subject := parts[i]
+			if pattern.anchored {
+				subject = strings.Join(parts[:i+1], "/")
+			}
+			if ok, _ := path.Match(pattern.glob, subject); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
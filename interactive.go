@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// promptAnswer is what the user chose for an undated file
+type promptAnswer int
+
+const (
+	answerUnknown promptAnswer = iota // Send it to unknown/ as usual
+	answerDate                        // Use the entered date
+	answerSkip                        // Leave it alone
+)
+
+// promptForDate asks on the terminal for the date of a file nothing could date
+// (Config.InteractiveUnknown). Prompts are serialized across workers; once the user
+// quits or input ends, every further file goes to unknown/ without asking.
+func (p *PhotoProcessor) promptForDate(filePath string, src *fetchedFile) (*DateInfo, promptAnswer) {
+	p.promptMutex.Lock()
+	defer p.promptMutex.Unlock()
+
+	if p.promptDone {
+		return nil, answerUnknown
+	}
+	if p.promptIn == nil {
+		p.promptIn = bufio.NewReader(os.Stdin)
+	}
+
+	fmt.Printf("\nUndated file: %s\n", filePath)
+	if summary := exifSummary(src); summary != "" {
+		fmt.Printf("  EXIF: %s\n", summary)
+	}
+	for {
+		fmt.Print("Date (YYYY-MM-DD [HH:MM:SS]), empty for unknown/, s to skip, q to stop asking: ")
+		line, err := p.promptIn.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if err != nil && (err != io.EOF || answer == "") {
+			fmt.Println()
+			log.Println("No more input: remaining undated files go to unknown/")
+			p.promptDone = true
+			return nil, answerUnknown
+		}
+
+		switch strings.ToLower(answer) {
+		case "":
+			return nil, answerUnknown
+		case "s", "skip":
+			return nil, answerSkip
+		case "q", "quit":
+			p.promptDone = true
+			return nil, answerUnknown
+		}
+		dateInfo, err := parseOverrideDate(answer)
+		if err != nil {
+			fmt.Printf("  %v\n", err)
+			continue
+		}
+		dateInfo.Original = filepath.Base(src.path)
+		return &dateInfo, answerDate
+	}
+}
+
+// exifSummary describes the camera and any (even implausible) dates in a file's EXIF,
+// or returns "" if it has none
+func exifSummary(src *fetchedFile) string {
+	localPath, err := src.MetadataPath()
+	if err != nil {
+		return ""
+	}
+	metadata, err := ReadExifData(localPath)
+	if err != nil {
+		return ""
+	}
+
+	var parts []string
+	if camera := strings.TrimSpace(metadata.Make + " " + metadata.Model); camera != "" {
+		parts = append(parts, camera)
+	}
+	tags := make([]string, 0, len(metadata.Dates))
+	for tag := range metadata.Dates {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		parts = append(parts, fmt.Sprintf("%s %s", tag, metadata.Dates[tag].Format(time.DateTime)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// inventoryRecord is what the inventory records about one media file
+type inventoryRecord struct {
+	Path         string  `json:"path"`
+	Size         int64   `json:"size"`                    // -1 when the walk didn't report sizes
+	FilenameDate string  `json:"filename_date,omitempty"` // Date parsed from the name
+	ExifDate     string  `json:"exif_date,omitempty"`     // Capture time from EXIF, "YYYY-MM-DD HH:MM:SS"
+	Make         string  `json:"make,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	SerialNumber string  `json:"serial_number,omitempty"`
+	LensModel    string  `json:"lens_model,omitempty"`
+	HasGPS       bool    `json:"has_gps"`
+	Latitude     float64 `json:"latitude,omitempty"`
+	Longitude    float64 `json:"longitude,omitempty"`
+	Error        string  `json:"error,omitempty"` // Why the metadata couldn't be read
+}
+
+// inventoryColumns is the CSV header, in the order written by csvRecord
+var inventoryColumns = []string{"path", "size", "filename_date", "exif_date", "make", "model", "serial_number", "lens_model", "has_gps", "latitude", "longitude", "error"}
+
+// csvRecord returns the record as a CSV row matching inventoryColumns
+func (r inventoryRecord) csvRecord() []string {
+	lat, lon := "", ""
+	if r.HasGPS {
+		lat = strconv.FormatFloat(r.Latitude, 'f', -1, 64)
+		lon = strconv.FormatFloat(r.Longitude, 'f', -1, 64)
+	}
+	return []string{r.Path, strconv.FormatInt(r.Size, 10), r.FilenameDate, r.ExifDate, r.Make, r.Model, r.SerialNumber, r.LensModel, strconv.FormatBool(r.HasGPS), lat, lon, r.Error}
+}
+
+// indexedRecord carries a record to the writer with its position in the file list
+type indexedRecord struct {
+	index  int
+	record inventoryRecord
+}
+
+// Inventory walks the source and writes one record per media file (dates, camera,
+// lens and GPS) to Config.InventoryPath without changing anything. EXIF is read by
+// a pool of workers; a single writer goroutine emits the records in file order, so
+// the output is the same whatever the worker count.
+func (p *PhotoProcessor) Inventory() error {
+	p.configureExiftool()
+
+	closeSource, err := p.openSource()
+	if err != nil {
+		return err
+	}
+	defer closeSource()
+
+	files, err := p.source.Walk(p.config.SourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to walk source: %w", err)
+	}
+	sizes := make(map[string]int64, len(files))
+	for _, file := range files {
+		sizes[file.Path] = file.Size
+	}
+	mediaFiles := p.selectMediaFiles(p.config.SourceDir, files)
+	naturalSort(mediaFiles)
+	log.Printf("Reading metadata of %d media files with %d workers", len(mediaFiles), p.inventoryWorkers())
+
+	out, err := os.Create(p.config.InventoryPath)
+	if err != nil {
+		return fmt.Errorf("failed to create inventory: %w", err)
+	}
+	defer out.Close()
+
+	records := make(chan indexedRecord)
+	written := make(chan error, 1)
+	go func() {
+		written <- writeInventory(out, p.config.InventoryFormat, records)
+	}()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < p.inventoryWorkers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				records <- indexedRecord{index: i, record: p.inventoryRecord(mediaFiles[i], sizes[mediaFiles[i]])}
+			}
+		}()
+	}
+	for i := range mediaFiles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	close(records)
+
+	if err := <-written; err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	log.Printf("Wrote inventory of %d files to %s", len(mediaFiles), p.config.InventoryPath)
+	return nil
+}
+
+// inventoryWorkers is the number of files whose metadata is read at once
+func (p *PhotoProcessor) inventoryWorkers() int {
+	if p.config.InventoryWorkers > 0 {
+		return p.config.InventoryWorkers
+	}
+	return p.workers()
+}
+
+// inventoryRecord reads what the inventory records about one file
+func (p *PhotoProcessor) inventoryRecord(path string, size int64) inventoryRecord {
+	record := inventoryRecord{Path: path, Size: size}
+	if dateInfo, err := ParseDateFromFilenameWithOptions(path, p.parseOptions()); err == nil {
+		record.FilenameDate = dateInfo.String()
+	}
+
+	metadata, err := p.readSourceExif(path)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	if t, ok := metadata.CaptureTime(); ok {
+		record.ExifDate = t.Format("2006-01-02 15:04:05")
+	}
+	record.Make, record.Model = metadata.Make, metadata.Model
+	record.SerialNumber, record.LensModel = metadata.SerialNumber, metadata.LensModel
+	record.HasGPS = metadata.HasGPS
+	if metadata.HasGPS {
+		record.Latitude, record.Longitude = metadata.Latitude, metadata.Longitude
+	}
+	return record
+}
+
+// writeInventory writes records to w in index order as they arrive in any order,
+// holding back those that arrive early. It is the only writer, so rows can't interleave.
+func writeInventory(w io.Writer, format string, records <-chan indexedRecord) error {
+	buf := bufio.NewWriter(w)
+	var csvWriter *csv.Writer
+	switch format {
+	case ReportFormatCSV:
+		csvWriter = csv.NewWriter(buf)
+		csvWriter.Write(inventoryColumns)
+	case ReportFormatJSON, "":
+		buf.WriteString("[")
+	}
+
+	var writeErr error
+	pending := make(map[int]inventoryRecord)
+	next := 0
+	for r := range records {
+		// Keep draining after an error so the workers never block
+		if writeErr != nil {
+			continue
+		}
+		pending[r.index] = r.record
+		for {
+			record, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if writeErr = writeInventoryRecord(buf, csvWriter, format, record, next == 0); writeErr != nil {
+				break
+			}
+			next++
+		}
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	if format == ReportFormatJSON || format == "" {
+		buf.WriteString("\n]\n")
+	}
+	return buf.Flush()
+}
+
+// writeInventoryRecord writes one record in the inventory format
+func writeInventoryRecord(buf *bufio.Writer, csvWriter *csv.Writer, format string, record inventoryRecord, first bool) error {
+	if csvWriter != nil {
+		csvWriter.Write(record.csvRecord())
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if format == ReportFormatNDJSON {
+		data = append(data, '\n')
+	} else {
+		// Keep one element per line so the array is still easy to grep
+		sep := ",\n"
+		if first {
+			sep = "\n"
+		}
+		buf.WriteString(sep)
+	}
+	_, err = buf.Write(data)
+	return err
+}
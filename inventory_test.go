@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inventorySource writes n JPEGs, each with its own EXIF date, and returns their
+// paths in natural sort order with the date each one holds
+func inventorySource(t *testing.T, n int) (dir string, paths []string, dates map[string]string) {
+	t.Helper()
+	dir = t.TempDir()
+	jpegData := testJPEG(t, false)
+	dates = make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		// Commas and quotes need CSV quoting, so a torn row would show
+		path := filepath.Join(dir, fmt.Sprintf("IMG_%d, \"copy\".jpg", i))
+		if err := os.WriteFile(path, jpegData, 0644); err != nil {
+			t.Fatal(err)
+		}
+		date := time.Date(2001, 3, 4, 10, 0, 0, 0, time.UTC).Add(time.Duration(i) * 37 * time.Hour)
+		if err := writeJPEGExifDate(path, date); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+		dates[path] = date.Format("2006-01-02 15:04:05")
+	}
+	naturalSort(paths)
+	return dir, paths, dates
+}
+
+func TestInventoryOutputUnderConcurrency(t *testing.T) {
+	const files = 150
+	dir, paths, dates := inventorySource(t, files)
+
+	for _, format := range []string{ReportFormatCSV, ReportFormatJSON, ReportFormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			outPath := filepath.Join(t.TempDir(), "inventory."+format)
+			p := NewPhotoProcessor(&Config{SourceDir: dir, InventoryPath: outPath, InventoryFormat: format, InventoryWorkers: 16})
+			if err := p.Inventory(); err != nil {
+				t.Fatal(err)
+			}
+
+			records := readInventory(t, outPath, format)
+			if len(records) != files {
+				t.Fatalf("inventory has %d records, want %d", len(records), files)
+			}
+			for i, record := range records {
+				if record.Path != paths[i] {
+					t.Fatalf("record %d is %q, want %q (out of order)", i, record.Path, paths[i])
+				}
+				if record.ExifDate != dates[record.Path] || record.Error != "" {
+					t.Errorf("record %d: exif date %q error %q, want %q", i, record.ExifDate, record.Error, dates[record.Path])
+				}
+			}
+		})
+	}
+}
+
+// readInventory parses an inventory, failing on any malformed row
+func readInventory(t *testing.T, path, format string) []inventoryRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []inventoryRecord
+	switch format {
+	case ReportFormatCSV:
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = len(inventoryColumns)
+		rows, err := r.ReadAll()
+		if err != nil {
+			t.Fatalf("malformed CSV: %v", err)
+		}
+		if strings.Join(rows[0], ",") != strings.Join(inventoryColumns, ",") {
+			t.Fatalf("CSV header = %v", rows[0])
+		}
+		for _, row := range rows[1:] {
+			records = append(records, inventoryRecord{Path: row[0], ExifDate: row[3], Error: row[11]})
+		}
+	case ReportFormatNDJSON:
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var record inventoryRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("malformed NDJSON line %q: %v", scanner.Text(), err)
+			}
+			records = append(records, record)
+		}
+	default:
+		if err := json.NewDecoder(f).Decode(&records); err != nil {
+			t.Fatalf("malformed JSON: %v", err)
+		}
+	}
+	return records
+}
+
+func TestWriteInventoryOrdersRecordsFromManyWriters(t *testing.T) {
+	const n = 500
+	records := make(chan indexedRecord)
+	var wg sync.WaitGroup
+	for w := 0; w < 20; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Each sender goes backwards through its share, so records arrive out of order
+			for i := n - 1 - w; i >= 0; i -= 20 {
+				records <- indexedRecord{index: i, record: inventoryRecord{Path: fmt.Sprintf("/src/%d.jpg", i), Size: int64(i)}}
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(records)
+	}()
+
+	var out strings.Builder
+	if err := writeInventory(&out, ReportFormatNDJSON, records); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != n {
+		t.Fatalf("wrote %d lines, want %d", len(lines), n)
+	}
+	for i, line := range lines {
+		var record inventoryRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %d malformed: %v", i, err)
+		}
+		if record.Size != int64(i) {
+			t.Fatalf("line %d holds record %d", i, record.Size)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errNativeExifUnsupported means the native writer can't update a file, so exiftool has to
+var errNativeExifUnsupported = errors.New("not supported by the native EXIF writer")
+
+// exifDateLength is the size of an EXIF date value: "YYYY:MM:DD HH:MM:SS" and a NUL
+const exifDateLength = 20
+
+// TIFF tags the native writer reads or writes
+const (
+	tagDateTime          = 0x0132 // IFD0, exiftool's ModifyDate
+	tagExifIFDPointer    = 0x8769
+	tagExifVersion       = 0x9000
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004 // exiftool's CreateDate
+	tagSubSecTime        = 0x9290 // Fraction of a second of DateTime
+	tagSubSecOriginal    = 0x9291
+	tagSubSecDigitized   = 0x9292
+	tagOffsetTime        = 0x9010 // UTC offset of DateTime
+	tagOffsetOriginal    = 0x9011
+	tagOffsetDigitized   = 0x9012
+)
+
+// exifOffsetLength is the size of an EXIF offset value: "+HH:MM" and a NUL
+const exifOffsetLength = 7
+
+// TIFF field types
+const (
+	tiffASCII     = 2
+	tiffLong      = 4
+	tiffUndefined = 7
+	tiffIFD       = 13
+)
+
+// isJPEGExt reports whether ext is a JPEG extension
+func isJPEGExt(ext string) bool {
+	ext = strings.ToLower(ext)
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// canUpdateMetadata reports whether a file's dates can be written: natively for JPEGs,
+// otherwise with exiftool
+func canUpdateMetadata(path string) bool {
+	return isJPEGExt(filepath.Ext(path)) || checkExiftoolAvailable()
+}
+
+// writeJPEGExifDate sets DateTimeOriginal, DateTimeDigitized (CreateDate) and DateTime
+// (ModifyDate) in a JPEG without external tools, and their OffsetTime tags when
+// exifOffset is set. A JPEG without EXIF gets a new EXIF segment holding just those
+// tags and, for a date with a fraction of a second, its SubSec tags; existing EXIF is
+// patched in place, so maker notes and other offsets stay valid.
+// Returns errNativeExifUnsupported (wrapped) for files that aren't JPEGs or whose EXIF
+// lacks one of the tags to write, since adding a tag would move the data after it.
+func writeJPEGExifDate(path string, date time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return fmt.Errorf("not a JPEG: %w", errNativeExifUnsupported)
+	}
+
+	value := []byte(date.Format("2006:01:02 15:04:05") + "\x00")
+	var offset []byte
+	if exifOffset != "" {
+		offset = []byte(exifOffset + "\x00")
+	}
+	tiffStart, tiffEnd, insertAt, err := findJPEGExif(data)
+	if err != nil {
+		return err
+	}
+	if tiffStart < 0 {
+		segment := newExifSegment(value, offset, date.Nanosecond())
+		data = append(data[:insertAt:insertAt], append(segment, data[insertAt:]...)...)
+	} else if err := patchExifDates(data[tiffStart:tiffEnd], value, date.Nanosecond(), offset); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// findJPEGExif locates the TIFF data of a JPEG's EXIF segment, returning a tiffStart of
+// -1 if there is none. insertAt is where a new EXIF segment belongs: after SOI and any
+// leading JFIF APP0 segment.
+func findJPEGExif(data []byte) (tiffStart, tiffEnd, insertAt int, err error) {
+	tiffStart, insertAt = -1, 2
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return -1, 0, 0, fmt.Errorf("malformed JPEG segment at %d: %w", pos, errNativeExifUnsupported)
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			pos++ // Fill byte
+			continue
+		}
+		// Image data starts at SOS; metadata segments all come before it
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return -1, 0, 0, fmt.Errorf("truncated JPEG segment at %d: %w", pos, errNativeExifUnsupported)
+		}
+		payload := data[pos+4 : end]
+		switch {
+		case marker == 0xE0 && pos == insertAt:
+			insertAt = end
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			return pos + 10, end, insertAt, nil
+		}
+		pos = end
+	}
+	return -1, 0, insertAt, nil
+}
+
+// patchExifDates overwrites the three date values in existing TIFF data, the
+// fractions of a second in any SubSec tags, which keep their recorded number of digits,
+// and, when offset isn't nil, the three OffsetTime values, which must all be present
+func patchExifDates(tiff, value []byte, nanos int, offset []byte) error {
+	order, ifd0, err := tiffHeader(tiff)
+	if err != nil {
+		return err
+	}
+
+	ifd0Entries, err := tiffEntries(tiff, order, ifd0)
+	if err != nil {
+		return err
+	}
+	pointer, ok := ifd0Entries[tagExifIFDPointer]
+	if !ok || (pointer.typ != tiffLong && pointer.typ != tiffIFD) {
+		return fmt.Errorf("no EXIF sub-IFD: %w", errNativeExifUnsupported)
+	}
+	exifEntries, err := tiffEntries(tiff, order, pointer.value)
+	if err != nil {
+		return err
+	}
+
+	// Check all three first so a file is never left half-updated
+	targets := []tiffEntry{ifd0Entries[tagDateTime], exifEntries[tagDateTimeOriginal], exifEntries[tagDateTimeDigitized]}
+	for _, entry := range targets {
+		if entry.typ != tiffASCII || entry.count != exifDateLength || int(entry.value)+exifDateLength > len(tiff) {
+			return fmt.Errorf("missing or unusual date tag: %w", errNativeExifUnsupported)
+		}
+	}
+	offsetTargets := []tiffEntry{exifEntries[tagOffsetTime], exifEntries[tagOffsetOriginal], exifEntries[tagOffsetDigitized]}
+	if offset != nil {
+		for _, entry := range offsetTargets {
+			if entry.typ != tiffASCII || entry.count != exifOffsetLength || int(entry.value)+exifOffsetLength > len(tiff) {
+				return fmt.Errorf("missing or unusual offset tag: %w", errNativeExifUnsupported)
+			}
+		}
+	}
+	for _, entry := range targets {
+		copy(tiff[entry.value:], value)
+	}
+	if offset != nil {
+		for _, entry := range offsetTargets {
+			copy(tiff[entry.value:], offset)
+		}
+	}
+
+	digits := fmt.Sprintf("%09d", nanos)
+	for _, ifd := range []uint32{ifd0, pointer.value} {
+		for _, field := range subSecValues(tiff, order, ifd) {
+			// The value is NUL-terminated, normally; a fraction finer than nanoseconds is zero-padded
+			width := len(field)
+			if width > 0 && field[width-1] == 0 {
+				width--
+			}
+			for i := 0; i < width; i++ {
+				field[i] = '0'
+				if i < len(digits) {
+					field[i] = digits[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// subSecValues returns the value bytes of the SubSec tags in the IFD at offset,
+// skipping any that aren't ASCII or lie outside the TIFF data
+func subSecValues(tiff []byte, order binary.ByteOrder, offset uint32) [][]byte {
+	start := int(offset)
+	count := int(order.Uint16(tiff[start:]))
+
+	var values [][]byte
+	for i := 0; i < count; i++ {
+		e := tiff[start+2+i*12:]
+		switch order.Uint16(e) {
+		case tagSubSecTime, tagSubSecOriginal, tagSubSecDigitized:
+		default:
+			continue
+		}
+		length := int(order.Uint32(e[4:]))
+		if order.Uint16(e[2:]) != tiffASCII {
+			continue
+		}
+		if length <= 4 {
+			// Short values are stored inline in the entry
+			values = append(values, e[8:8+length])
+			continue
+		}
+		if at := int(order.Uint32(e[8:])); at+length <= len(tiff) {
+			values = append(values, tiff[at:at+length])
+		}
+	}
+	return values
+}
+
+// tiffEntry is one IFD entry; value is the inline value or the offset of the data
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value uint32
+}
+
+// tiffHeader returns the byte order and first IFD offset of TIFF data
+func tiffHeader(tiff []byte) (binary.ByteOrder, uint32, error) {
+	if len(tiff) < 8 {
+		return nil, 0, fmt.Errorf("truncated TIFF header: %w", errNativeExifUnsupported)
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, 0, fmt.Errorf("bad TIFF byte order: %w", errNativeExifUnsupported)
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return nil, 0, fmt.Errorf("bad TIFF magic: %w", errNativeExifUnsupported)
+	}
+	return order, order.Uint32(tiff[4:]), nil
+}
+
+// tiffEntries reads the entries of the IFD at offset, keyed by tag
+func tiffEntries(tiff []byte, order binary.ByteOrder, offset uint32) (map[uint16]tiffEntry, error) {
+	start := int(offset)
+	if start+2 > len(tiff) {
+		return nil, fmt.Errorf("IFD offset out of range: %w", errNativeExifUnsupported)
+	}
+	count := int(order.Uint16(tiff[start:]))
+	if start+2+count*12 > len(tiff) {
+		return nil, fmt.Errorf("truncated IFD: %w", errNativeExifUnsupported)
+	}
+
+	entries := make(map[uint16]tiffEntry, count)
+	for i := 0; i < count; i++ {
+		e := tiff[start+2+i*12:]
+		entries[order.Uint16(e)] = tiffEntry{typ: order.Uint16(e[2:]), count: order.Uint32(e[4:]), value: order.Uint32(e[8:])}
+	}
+	return entries, nil
+}
+
+// newExifSegment builds an APP1 EXIF segment holding only the date tags and the
+// mandatory ExifVersion: IFD0 (DateTime, ExifIFDPointer), the EXIF sub-IFD
+// (ExifVersion, DateTimeOriginal, DateTimeDigitized, when offset isn't nil the three
+// OffsetTime tags, and when nanos isn't 0 the three SubSec tags, to the millisecond),
+// then the three date values and the offset values
+func newExifSegment(value, offset []byte, nanos int) []byte {
+	order := binary.LittleEndian
+	exifTags := 3
+	if offset != nil {
+		exifTags += 3
+	}
+	if nanos != 0 {
+		exifTags += 3
+	}
+	const (
+		ifd0Offset    = 8
+		exifIFDOffset = ifd0Offset + 2 + 2*12 + 4
+	)
+	var (
+		valuesOffset    = exifIFDOffset + 2 + exifTags*12 + 4
+		dateTimeOffset  = valuesOffset
+		originalOffset  = valuesOffset + exifDateLength
+		digitizedOffset = valuesOffset + 2*exifDateLength
+		offsetOffset    = valuesOffset + 3*exifDateLength
+		tiffLength      = offsetOffset
+	)
+	if offset != nil {
+		tiffLength += 3 * exifOffsetLength
+	}
+
+	tiff := make([]byte, tiffLength)
+	copy(tiff, "II")
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], ifd0Offset)
+
+	putIFD := func(offset int, entries []tiffEntry, tags []uint16) {
+		order.PutUint16(tiff[offset:], uint16(len(entries)))
+		for i, entry := range entries {
+			e := tiff[offset+2+i*12:]
+			order.PutUint16(e, tags[i])
+			order.PutUint16(e[2:], entry.typ)
+			order.PutUint32(e[4:], entry.count)
+			order.PutUint32(e[8:], entry.value)
+		}
+		// The next-IFD offset stays 0: there is no thumbnail IFD
+	}
+	putIFD(ifd0Offset,
+		[]tiffEntry{{tiffASCII, exifDateLength, uint32(dateTimeOffset)}, {tiffLong, 1, exifIFDOffset}},
+		[]uint16{tagDateTime, tagExifIFDPointer})
+	entries := []tiffEntry{{tiffUndefined, 4, order.Uint32([]byte("0232"))}, {tiffASCII, exifDateLength, uint32(originalOffset)}, {tiffASCII, exifDateLength, uint32(digitizedOffset)}}
+	tags := []uint16{tagExifVersion, tagDateTimeOriginal, tagDateTimeDigitized}
+	if offset != nil {
+		// IFD entries are sorted by tag, and each offset tag gets its own copy of the value
+		for i, tag := range []uint16{tagOffsetTime, tagOffsetOriginal, tagOffsetDigitized} {
+			at := offsetOffset + i*exifOffsetLength
+			entries = append(entries, tiffEntry{tiffASCII, exifOffsetLength, uint32(at)})
+			tags = append(tags, tag)
+			copy(tiff[at:], offset)
+		}
+	}
+	if nanos != 0 {
+		// Three digits and a NUL fit inline in each entry
+		subSec := order.Uint32(fmt.Appendf(nil, "%03d\x00", nanos/int(time.Millisecond)))
+		for _, tag := range []uint16{tagSubSecTime, tagSubSecOriginal, tagSubSecDigitized} {
+			entries = append(entries, tiffEntry{tiffASCII, 4, subSec})
+			tags = append(tags, tag)
+		}
+	}
+	putIFD(exifIFDOffset, entries, tags)
+	copy(tiff[dateTimeOffset:], value)
+	copy(tiff[originalOffset:], value)
+	copy(tiff[digitizedOffset:], value)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+6+len(tiff)))
+	segment = append(segment, "Exif\x00\x00"...)
+	return append(segment, tiff...)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testJPEG encodes a small image. Go's encoder writes no APP0 or EXIF segment, so
+// withJFIF adds the JFIF APP0 segment most cameras and editors write after SOI.
+func testJPEG(t *testing.T, withJFIF bool) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		img.Set(x, x%8, color.RGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !withJFIF {
+		return data
+	}
+	app0 := []byte{0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00, 0x01, 0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00}
+	return append(append(append([]byte{}, data[:2]...), app0...), data[2:]...)
+}
+
+// checkExifDates reads back a JPEG's three written dates and checks it still decodes
+func checkExifDates(t *testing.T, path string, want time.Time) {
+	t.Helper()
+	metadata, err := ReadExifData(path)
+	if err != nil {
+		t.Fatalf("ReadExifData after write: %v", err)
+	}
+	for _, tag := range []string{DateTagOriginal, DateTagDigitized, DateTagModified} {
+		if got, ok := metadata.Dates[tag]; !ok || !got.Equal(want) {
+			t.Errorf("%s = %v (found %v), want %v", tag, got, ok, want)
+		}
+	}
+	if got, ok := ReadCaptureTime(path); !ok || !got.Equal(want) {
+		t.Errorf("ReadCaptureTime = %v, %v; want %v", got, ok, want)
+	}
+	if err := VerifyExifDate(path, want); err != nil {
+		t.Errorf("VerifyExifDate: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := jpeg.Decode(f); err != nil {
+		t.Errorf("image no longer decodes after the write: %v", err)
+	}
+}
+
+func TestWriteJPEGExifDateRoundTrip(t *testing.T) {
+	first := time.Date(2018, 10, 21, 14, 30, 5, 0, time.UTC)
+	second := time.Date(1999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	for _, tt := range []struct {
+		name     string
+		withJFIF bool
+	}{
+		{"bare JPEG", false},
+		{"JFIF JPEG", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "photo.jpg")
+			if err := os.WriteFile(path, testJPEG(t, tt.withJFIF), 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			// The first write adds an EXIF segment
+			if err := writeJPEGExifDate(path, first); err != nil {
+				t.Fatalf("first write: %v", err)
+			}
+			checkExifDates(t, path, first)
+			data, _ := os.ReadFile(path)
+			if tt.withJFIF && !bytes.Equal(data[2:6], []byte{0xFF, 0xE0, 0x00, 0x10}) {
+				t.Error("the JFIF APP0 segment no longer follows SOI")
+			}
+			sizeAfterInsert := len(data)
+
+			// Writing again patches the existing values in place
+			if err := writeJPEGExifDate(path, second); err != nil {
+				t.Fatalf("second write: %v", err)
+			}
+			checkExifDates(t, path, second)
+			data, _ = os.ReadFile(path)
+			if len(data) != sizeAfterInsert {
+				t.Errorf("rewrite changed the file size from %d to %d", sizeAfterInsert, len(data))
+			}
+
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+				t.Errorf("file mode after write = %v, %v; want 0640", info.Mode().Perm(), err)
+			}
+		})
+	}
+}
+
+func TestUpdateExifDateWritesJPEGsNatively(t *testing.T) {
+	// With no exiftool on PATH, JPEG dates are still written
+	t.Setenv("PATH", t.TempDir())
+	path := filepath.Join(t.TempDir(), "photo.jpeg")
+	if err := os.WriteFile(path, testJPEG(t, true), 0644); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2018, 10, 21, 14, 30, 5, 0, time.UTC)
+	if err := UpdateExifDate(path, date); err != nil {
+		t.Fatal(err)
+	}
+	checkExifDates(t, path, date)
+}
+
+func TestWriteJPEGExifDateUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	png := filepath.Join(dir, "image.png")
+	if err := os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeJPEGExifDate(png, time.Now()); !errors.Is(err, errNativeExifUnsupported) {
+		t.Errorf("writing a PNG natively = %v, want errNativeExifUnsupported", err)
+	}
+}
+
+func TestSubSecondPairKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Date(2018, 10, 21, 14, 30, 5, 250*int(time.Millisecond), time.UTC)
+	second := first.Add(500 * time.Millisecond)
+	parsed := &DateInfo{Year: 2018, Month: 10, Day: 21}
+
+	// Two burst frames from the same second, as a camera records them
+	var paths []string
+	for i, date := range []time.Time{first, second} {
+		path := filepath.Join(dir, fmt.Sprintf("IMG_1234_BURST%d.jpg", i+1))
+		if err := os.WriteFile(path, testJPEG(t, false), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeJPEGExifDate(path, date); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	metadata, err := ReadExifData(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.SubSecTimeOriginal != "250" || !metadata.DateTimeOriginal.Equal(first) {
+		t.Errorf("read back %v with SubSecTimeOriginal %q, want %v and 250", metadata.DateTimeOriginal, metadata.SubSecTimeOriginal, first)
+	}
+
+	// Processing keeps each frame's EXIF time and writes it to the destination copy
+	var written []time.Time
+	for _, path := range paths {
+		timestamp, fromExif := DetermineCorrectTimestamp(path, parsed)
+		if !fromExif {
+			t.Fatalf("%s: EXIF time not used", path)
+		}
+		dest := filepath.Join(dir, "dest_"+filepath.Base(path))
+		if err := os.WriteFile(dest, testJPEG(t, true), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateExifDate(dest, timestamp); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := ReadCaptureTime(dest)
+		if !ok {
+			t.Fatalf("%s: no capture time after write", dest)
+		}
+		written = append(written, got)
+	}
+	if !written[0].Equal(first) || !written[1].Equal(second) {
+		t.Errorf("destination times = %v, %v; want %v, %v", written[0], written[1], first, second)
+	}
+	if !written[0].Before(written[1]) {
+		t.Error("frames one sub-second apart lost their order")
+	}
+
+	// Rewriting patches the existing SubSec tags in place
+	if err := writeJPEGExifDate(paths[0], second); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ReadCaptureTime(paths[0]); !got.Equal(second) {
+		t.Errorf("after rewrite, capture time = %v, want %v", got, second)
+	}
+}
@@ -5,8 +5,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
+// version is reported in the processed tag; set it at build time with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 func main() {
 	// Command-line flags
 	sourceDir := flag.String("source", "", "Source directory containing photos (can be remote SSH path like user@host:path)")
@@ -14,24 +22,184 @@ func main() {
 	dryRun := flag.Bool("dry-run", false, "Perform a dry run without making changes")
 	sshHost := flag.String("ssh-host", "", "SSH host for source (e.g., nas-photos or user@host:port)")
 	destSSHHost := flag.String("dest-ssh-host", "", "SSH host for destination (defaults to same as source)")
+	sshPort := flag.Int("ssh-port", 0, "SSH port for the source, overriding any port in -ssh-host (0 uses the host's port, or 22)")
+	destSSHPort := flag.Int("dest-ssh-port", 0, "SSH port for the destination, overriding any port in -dest-ssh-host (0 uses the host's port, or 22; defaults to -ssh-port when -dest-ssh-host is not set)")
 	remoteDest := flag.Bool("remote-dest", false, "Whether destination is on remote server (requires -dest-ssh-host or -ssh-host)")
+	sshKeepalive := flag.Duration("ssh-keepalive", 30*time.Second, "Interval between SSH keepalive requests (0 disables)")
+	limit := flag.Int("limit", 0, "Process only the first N media files (after filtering and sorting) for a quick trial run (0 processes all)")
+	minFileSize := flag.String("min-file-size", "", "Skip media files smaller than this size, e.g. 10KB (units B, KB, MB, GB; 1KB = 1024 bytes; remote sources need GNU find)")
+	parallelWalks := flag.Int("parallel-walks", 0, "Walk remote sources with this many concurrent find commands, one per top-level subdirectory (0 runs a single find)")
+	directRemoteStream := flag.Bool("direct-remote-stream", false, "With a remote source and -remote-dest, pipe files straight from the source host to the destination host and update dates with the destination's exiftool")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
 	skipExisting := flag.Bool("skip-existing", false, "Skip files that already exist at destination (for resuming interrupted runs)")
-	workers := flag.Int("workers", 2, "Number of concurrent workers for parallel processing")
+	workers := flag.String("workers", "2", "Number of concurrent workers for parallel processing, or auto (CPU count locally, fewer for SSH and S3 transfers)")
+	processOrder := flag.String("process-order", ProcessOrderSorted, "Order files are processed in: sorted (natural sort of paths, identical across runs) or asfound (the order the walk listed them)")
 	testDir := flag.String("test-dir", "", "Optional: specific subdirectory under -source to process (e.g., '2010-2019/2018/2018_10_21wedding official')")
 	fixMetadata := flag.Bool("fix-metadata", false, "Fix metadata mode: restore original EXIF timestamps where appropriate instead of copying files")
+	dryRunSamples := flag.Int("dry-run-samples", 0, "In dry-run mode, write this many renamed, EXIF-updated sample copies for inspection")
+	dryRunSampleDir := flag.String("dry-run-sample-dir", "", "Directory for dry-run samples (defaults to a new temporary directory)")
+	dirMode := flag.String("dir-mode", "0755", "Octal mode for created destination directories")
+	fileMode := flag.String("file-mode", "", "Octal mode for written destination files (default: leave as created)")
+	preservePermissions := flag.Bool("preserve-permissions", false, "Give written files the source file's mode (and owner on local destinations, when run as root); not supported for S3")
+	defaultTimeOfDay := flag.String("default-time-of-day", "00:00:00", "Time of day (HH:MM[:SS]) given to date-only files without EXIF; later files in a sequence follow one second apart")
+	interactiveUnknown := flag.Bool("interactive-unknown", false, "Prompt for the date of each file that can't be dated (or skip it) instead of sending it straight to unknown/")
+	rangeFolderDate := flag.String("range-folder-date", "", "Date files that nothing else dates from a year-range folder above them (e.g. 2010-2019), as a low-confidence last resort: start or midpoint of the range (empty sends them to unknown/)")
+	dateFromFolderOnly := flag.Bool("date-from-folder-only", false, "Date every file from its parent folder (e.g. 2018-10-21/) alone, ignoring what its own name says")
+	dateOrder := flag.String("date-order", "", "How to read day- or month-first names like 10-05-2018 when both numbers could be the month: dmy, mdy, or ymd to only accept year-first dates (empty accepts only unambiguous ones such as 21-10-2018)")
+	preferEarliestYear := flag.Bool("prefer-earliest-year", false, "When a name contains several years, use the earliest (e.g. capture year over scan year)")
+	preferExif := flag.Bool("prefer-exif-date", false, "Date files by their EXIF capture time whenever present, falling back to the filename only when EXIF is missing")
+	dateOverrideCSV := flag.String("date-override-csv", "", "CSV of <file name or path>,<date> rows giving the correct date (YYYY-MM-DD[ HH:MM:SS]) for specific files, ignoring their names and EXIF")
+	cpuProfile := flag.String("cpu-profile", "", "Write a pprof CPU profile of the run to this file (inspect with go tool pprof)")
+	memProfile := flag.String("mem-profile", "", "Write a pprof heap profile to this file when the run finishes")
+	tracePath := flag.String("trace", "", "Write a runtime execution trace of the run to this file (inspect with go tool trace)")
+	exiftoolTimeout := flag.Duration("exiftool-timeout", 2*time.Minute, "Kill an exiftool run that takes longer than this and count the file as an error (0 waits forever)")
+	exiftoolDockerImageFlag := flag.String("exiftool-docker-image", DefaultExiftoolDockerImage, "Docker image used when exiftool isn't installed; pin a tag (e.g. exiftool/exiftool:13.10) for reproducible runs")
+	exiftoolPath := flag.String("exiftool-path", "", "Path of the exiftool binary, for installs outside PATH (e.g. /volume1/@appstore/exiftool/bin/exiftool); empty looks it up in PATH")
+	tagProcessed := flag.Bool("tag-processed", false, "Tag written files with XMP-pmeta:ProcessedBy and skip destination files that already carry it (needs exiftool)")
+	verifyExifWrite := flag.Bool("verify-exif-write", false, "Re-read the date after each metadata write and count mismatches as failures")
+	verifyUpload := flag.Bool("verify-upload", false, "Hash each file uploaded to a remote destination on the remote host (sha256sum, md5sum, xxhsum or b3sum per -hash-algo) and re-upload on mismatch")
+	verifyUploadRetries := flag.Int("verify-upload-retries", 1, "Re-uploads after a failed upload verification before the file counts as an error")
+	setFileModifyDate := flag.Bool("set-file-modify-date", false, "Set each written or fixed file's modification time to the photo date")
+	s3Endpoint := flag.String("s3-endpoint", "", "S3-compatible endpoint URL for an object storage destination (defaults to AWS)")
+	s3Bucket := flag.String("s3-bucket", "", "Upload to this S3 bucket instead of a filesystem destination (-dest becomes the key prefix)")
+	s3Region := flag.String("s3-region", "us-east-1", "S3 region used for request signing")
+	s3AccessKey := flag.String("s3-access-key", "", "S3 access key (defaults to $AWS_ACCESS_KEY_ID)")
+	s3SecretKey := flag.String("s3-secret-key", "", "S3 secret key (defaults to $AWS_SECRET_ACCESS_KEY)")
+	hashAlgo := flag.String("hash-algo", DefaultHashAlgo, "Content hash for verification and dedup: sha256, md5, xxhash or blake3")
+	geocodeDB := flag.String("geocode-db", "", "Offline city database (GeoNames cities*.txt) for appending GPS place names to month folders, e.g. 2018-08_Paris")
+	writeFolderIndex := flag.Bool("write-folder-index", false, "Write a JSON index of the files placed in each destination folder, with original names and parsed dates")
+	folderIndexName := flag.String("folder-index-name", DefaultFolderIndexName, "File name of the per-folder index")
+	reportPath := flag.String("report", "", "Write a per-file result report to this path")
+	reportFormat := flag.String("report-format", ReportFormatJSON, "Report format: json (single array), ndjson (one object per line, streamable) or csv")
+	summaryJSON := flag.String("summary-json", "", "Write the final statistics (the totals printed at the end) to this JSON file")
+	needsMetadata := flag.String("needs-metadata-file", "", "Write the destination files organized without their date in their metadata (no exiftool, or the update failed) to this file, one path per line")
+	treePlan := flag.String("tree-plan", "", "Write the destination folder hierarchy, with each file's source, new name and date, to this JSON file (for previewing a dry run)")
+	processArchives := flag.Bool("process-archives", false, "Process the photos inside .zip archives found in the source without extracting the archives")
+	skipHidden := flag.Bool("skip-hidden", true, "Skip dotfiles, macOS ._ AppleDouble files and files inside hidden directories")
+	exifDateTagsFlag := flag.String("exif-date-tags", strings.Join(DefaultExifDateTags, ","), "Comma-separated EXIF date tags to read a photo's capture time from, in priority order; the first plausible one wins (DateTimeOriginal, DateTimeDigitized/CreateDate, DateTime/ModifyDate, GPSDateTime)")
+	junkDirs := flag.String("junk-dirs", strings.Join(DefaultJunkDirs, ","), "Comma-separated directory names to skip entirely while walking (empty skips none)")
+	groupBursts := flag.Bool("group-bursts", false, "Keep burst shots (IMG_1234_BURST001.jpg, Pixel ..._BURST<timestamp>.jpg) together: every frame gets the first frame's date and folder")
+	skipDerivedPreviews := flag.Bool("skip-derived-previews", false, "Skip thumbnails and JPEG previews exported next to a same-named HEIC/HEIF original")
+	includeRaw := flag.Bool("include-raw", false, "Also process camera RAW files (NEF, CR2, ARW, DNG, ...), reading their embedded EXIF")
+	detectByContent := flag.Bool("detect-by-content", false, "Identify media by file content instead of extension (catches mislabeled files; reads the start of every file)")
+	includeCameras := flag.String("include-cameras", "", "Comma-separated camera make/model patterns to process, e.g. \"Canon EOS 5D*,iPhone\" (others are left untouched)")
+	excludeCameras := flag.String("exclude-cameras", "", "Comma-separated camera make/model patterns to leave untouched")
+	includeUnknownCamera := flag.Bool("include-unknown-camera", false, "With camera filters set, also process photos without EXIF make/model")
+	convertHEIC := flag.Bool("canonicalize-heic-to-jpg", false, "Transcode HEIC/HEIF photos to JPEG at the destination, keeping metadata (needs heif-convert, ImageMagick or sips)")
+	moveSidecars := flag.Bool("move-sidecars", false, "Copy XMP and iPhone AAE sidecars alongside their media under the matching new name")
+	walkCache := flag.String("walk-cache", "", "File caching the source file list so a restarted run skips re-enumerating the source")
+	walkCacheTTL := flag.Duration("walk-cache-ttl", 24*time.Hour, "How long a cached source file list is reused (0 means until the cache file is deleted)")
+	dedupeReport := flag.Bool("dedupe-report", false, "Report duplicate files already in -dest (by content hash) and exit without processing; nothing is deleted")
+	dedupeReportFormat := flag.String("dedupe-report-format", DedupeFormatText, "Dedupe report output: text, or tsv (hash, size, path per line) for scripts")
+	destExistsAction := flag.String("dest-exists-action", DestExistsOverwrite, "When a destination name is taken: overwrite, skip (like -skip-existing), or merge (skip the file if the existing one has identical content, otherwise write it with a _N suffix)")
+	exiftoolBatchSize := flag.Int("exiftool-batch-size", 0, "Date non-JPEG files (HEIC, RAW, video) with one exiftool run per this many files via an argument file, instead of several runs per file; a batch holds at most one file per worker (0 disables)")
+	sourceManifest := flag.String("source-manifest", "", "Process the files listed in this text file (one path per line, relative to -source or absolute) instead of walking -source")
+	defaultClockDates := flag.String("default-clock-dates", strings.Join(DefaultClockDates, ","), "Comma-separated days (YYYY-MM-DD) a camera with a dead clock battery stamps; EXIF dates on them are treated as missing (empty to trust every EXIF date)")
+	maxPathLength := flag.Int("max-path-length", 0, "With -dry-run, warn about destination paths longer than this many characters, e.g. 260 for Windows or some NAS shares (0 disables)")
+	pauseFile := flag.String("pause-file", "", "Pause the run while this file exists: files in progress finish, and the next file starts once it's removed")
+	exifWriteMinConfidence := flag.String("exif-write-min-confidence", "", "Only write a file's date into its metadata when the date's confidence is at least this (low, medium or high); below it the file is still organized by the date but its EXIF is left untouched")
+	separateScreenshots := flag.Bool("separate-screenshots", false, "File screenshots (names like Screenshot_... or Screen Shot ..., and PNGs in a Screenshots folder) under -screenshots-dir/YYYY instead of the normal date layout")
+	screenshotsDir := flag.String("screenshots-dir", DefaultScreenshotsDir, "Destination subtree for -separate-screenshots, relative to the destination")
+	relinkExisting := flag.Bool("relink-existing", false, "Walk -dest and write the date in each standardized file name (e.g. 2018-10-21_beach.jpg) into that file's metadata in place, then exit; repairs files organized before exiftool was installed without re-copying them")
+	dateConflictPrefer := flag.String("date-conflict-prefer", DateConflictPreferFilename, "When a file's name and the folders above it give different years (e.g. 2015 misc/2018-10-21.jpg), date it by the filename or the folder; conflicts are logged and listed in the report either way")
+	dedupKeep := flag.String("dedup-keep", "", "Hash source files of equal size and process only one of each set with identical content, keeping the first-seen (in path order), largest, newest-mtime or shortest-name file; the others are reported as duplicates (empty processes all)")
+	histogram := flag.String("histogram", "", "Print how many source media files are dated in each year, month or day (from their names, as a run would date them) and exit without processing")
+	histogramFormat := flag.String("histogram-format", HistogramFormatText, "Histogram output: text (table with bars) or csv")
+	exifOffsetFlag := flag.String("exif-timezone-offset", "", "Write this UTC offset (+HH:MM, e.g. +02:00) to the OffsetTimeOriginal, OffsetTimeDigitized and OffsetTime tags with each date, without shifting the recorded time")
+	retryErroredAtEnd := flag.Bool("retry-errored-at-end", false, "After the main pass, reconnect SSH and try the files that errored once more; files that now succeed are no longer counted as errors")
+	includePDF := flag.Bool("include-pdf", false, "Also organize PDFs (e.g. scanned photo album pages) by the date in their name or folders, like photos; their metadata is left alone")
+	pdfWriteDate := flag.Bool("pdf-write-date", false, "With -include-pdf, write each PDF's date into its CreationDate and ModDate with exiftool")
+	compareExif := flag.String("compare-exif-vs-filename", "", "Write every source media file whose EXIF capture time and filename date disagree (with both values and the difference) to this CSV file, log the disagreements per camera, and exit without processing")
+	compareExifTolerance := flag.Duration("compare-exif-tolerance", 0, "Differences between EXIF and filename dates up to this are not reported by -compare-exif-vs-filename (e.g. 1m or 24h); a name without a time matches any time on its day")
+	contactSheets := flag.Bool("contact-sheets", false, "Write a contact-sheet.jpg grid of the embedded EXIF thumbnails of the files placed in each destination folder (reads every file's thumbnail; uses exiftool for HEIC, RAW and video)")
+	writeMetadataSidecar := flag.Bool("write-metadata-sidecar", false, "Write <file>.json next to each organized file recording its original path, derived date and confidence, timestamp and its source, camera and GPS")
+	forceReprocess := flag.Bool("force-reprocess", false, "Also process files already named and placed the way this tool would (skipped by default, e.g. when re-running over an organized library)")
+	inventory := flag.String("inventory", "", "Write each source media file's filename date, EXIF date, camera, lens and GPS to this file and exit without processing")
+	inventoryFormat := flag.String("inventory-format", ReportFormatCSV, "Inventory format: csv, json (single array) or ndjson (one object per line)")
+	inventoryWorkers := flag.Int("inventory-workers", 0, "Files whose metadata is read at once for -inventory (0 uses -workers)")
+	progressEvery := flag.Int("progress-every", DefaultProgressEveryN, "Log a progress line every this many files (negative logs only on -progress-interval)")
+	progressInterval := flag.Duration("progress-interval", DefaultProgressInterval, "Log a progress line at least this often (negative logs only on -progress-every)")
+	transferProgress := flag.Duration("transfer-progress-interval", 10*time.Second, "How often to log progress of a long upload or download (0 disables)")
+	parseOnlyMode := flag.Bool("parse-only", false, "Read paths from stdin, print \"path<TAB>date\" (or UNPARSEABLE) for each using only the filename parser, then exit; the exit status is 1 if any were unparseable")
+	capabilities := flag.Bool("capabilities", false, "Print recognized file types, detected exiftool/Docker and SSH keys, then exit")
+	stripDatePrefix := flag.Bool("strip-date-prefix", true, "Remove a leading copy of the parsed date from descriptions (e.g. 2018-10-21 party -> party)")
+	destLayout := flag.String("dest-layout", LayoutYearMonth, "Destination folder layout: year (YYYY/), year-month (YYYY/YYYY-MM/), year-month-day (YYYY/YYYY-MM/YYYY-MM-DD/) or flat")
+	wordSeparator := flag.String("word-separator", "_", "Single character separating date, time and description words in filenames")
+	normalizeWhitespace := flag.Bool("normalize-whitespace", false, "Collapse runs of spaces, tabs and underscores in descriptions into one separator and trim them (e.g. \"wedding   official \" -> wedding_official)")
 
 	flag.Parse()
 
-	if *sourceDir == "" || *destDir == "" {
+	// The keys come from the environment here rather than as flag defaults, which
+	// the usage message would print
+	if *s3AccessKey == "" {
+		*s3AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if *s3SecretKey == "" {
+		*s3SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+
+	if *capabilities {
+		exiftoolDockerImage = *exiftoolDockerImageFlag
+		if *exiftoolPath != "" {
+			exiftoolBinary = *exiftoolPath
+		}
+		printCapabilities(os.Stdout)
+		return
+	}
+
+	if *parseOnlyMode {
+		unparseable, err := parseOnly(os.Stdin, os.Stdout, ParseOptions{PreferEarliestYear: *preferEarliestYear, DateOrder: *dateOrder})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		if unparseable > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if (*sourceDir == "" && !*dedupeReport && !*relinkExisting) || (*destDir == "" && *inventory == "" && *histogram == "" && *compareExif == "") {
 		fmt.Println("Usage: picture-metadata -source <source-dir> -dest <dest-dir> [options]")
+		fmt.Println("       picture-metadata -dedupe-report -dest <dest-dir> [options]")
+		fmt.Println("       picture-metadata -relink-existing -dest <dest-dir> [options]")
+		fmt.Println("       picture-metadata -inventory <file> -source <source-dir> [options]")
+		fmt.Println("       picture-metadata -histogram <year|month|day> -source <source-dir> [options]")
+		fmt.Println("       picture-metadata -compare-exif-vs-filename <file> -source <source-dir> [options]")
+		fmt.Println("       picture-metadata -parse-only < paths.txt")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
+	dirPerm, err := parseFileMode(*dirMode)
+	if err != nil {
+		log.Fatalf("Error: invalid -dir-mode: %v", err)
+	}
+	filePerm, err := parseFileMode(*fileMode)
+	if err != nil {
+		log.Fatalf("Error: invalid -file-mode: %v", err)
+	}
+
+	dayStart, err := parseTimeOfDay(*defaultTimeOfDay)
+	if err != nil {
+		log.Fatalf("Error: invalid -default-time-of-day: %v", err)
+	}
+
+	minSize, err := parseByteSize(*minFileSize)
+	if err != nil {
+		log.Fatalf("Error: invalid -min-file-size: %v", err)
+	}
+
+	workerCount, err := parseWorkers(*workers)
+	if err != nil {
+		log.Fatalf("Error: invalid -workers: %v", err)
+	}
+
 	// If dest-ssh-host not specified but remote-dest is true, use same as source
 	if *remoteDest && *destSSHHost == "" {
 		*destSSHHost = *sshHost
+		if *destSSHPort == 0 {
+			*destSSHPort = *sshPort
+		}
 	}
 
 	config := &Config{
@@ -43,9 +211,224 @@ func main() {
 		RemoteDest:   *remoteDest,
 		Verbose:      *verbose,
 		SkipExisting: *skipExisting,
-		Workers:      *workers,
+		Workers:      workerCount,
 		TestDir:      *testDir,
 		FixMetadata:  *fixMetadata,
+
+		ProcessOrder: *processOrder,
+
+		SSHKeepalive: *sshKeepalive,
+
+		SSHPort:     *sshPort,
+		DestSSHPort: *destSSHPort,
+
+		ParallelWalks: *parallelWalks,
+
+		DirectRemoteStream: *directRemoteStream,
+
+		MinFileSize: minSize,
+
+		Limit: *limit,
+
+		DryRunSamples:   *dryRunSamples,
+		DryRunSampleDir: *dryRunSampleDir,
+
+		DestLayout: *destLayout,
+
+		WordSeparator: *wordSeparator,
+
+		StripDatePrefix: *stripDatePrefix,
+
+		NormalizeWhitespace: *normalizeWhitespace,
+
+		DirMode:  dirPerm,
+		FileMode: filePerm,
+
+		PreservePermissions: *preservePermissions,
+
+		DefaultTimeOfDay: dayStart,
+
+		PreferEarliestYear: *preferEarliestYear,
+		DateOrder:          *dateOrder,
+
+		DateFromFolderOnly: *dateFromFolderOnly,
+
+		RangeFolderDate: *rangeFolderDate,
+
+		InteractiveUnknown: *interactiveUnknown,
+
+		PreferExif: *preferExif,
+
+		DateOverrideCSV: *dateOverrideCSV,
+
+		TagProcessed: *tagProcessed,
+
+		ExiftoolTimeout:     *exiftoolTimeout,
+		ExiftoolDockerImage: *exiftoolDockerImageFlag,
+		ExiftoolPath:        *exiftoolPath,
+
+		VerifyExifWrite: *verifyExifWrite,
+
+		CPUProfile: *cpuProfile,
+		MemProfile: *memProfile,
+		TracePath:  *tracePath,
+
+		VerifyUpload:        *verifyUpload,
+		VerifyUploadRetries: *verifyUploadRetries,
+
+		SetFileModifyDate: *setFileModifyDate,
+
+		S3Endpoint:  *s3Endpoint,
+		S3Bucket:    *s3Bucket,
+		S3Region:    *s3Region,
+		S3AccessKey: *s3AccessKey,
+		S3SecretKey: *s3SecretKey,
+
+		HashAlgo: *hashAlgo,
+
+		IncludeRaw: *includeRaw,
+
+		DetectByContent: *detectByContent,
+
+		ProcessArchives: *processArchives,
+
+		SkipHidden: *skipHidden,
+		JunkDirs:   append([]string{}, splitList(*junkDirs)...), // Non-nil so an empty -junk-dirs prunes nothing
+
+		ExifDateTags: splitList(*exifDateTagsFlag),
+
+		GroupBursts: *groupBursts,
+
+		SkipDerivedPreviews: *skipDerivedPreviews,
+
+		WriteFolderIndex: *writeFolderIndex,
+		FolderIndexName:  *folderIndexName,
+
+		ReportPath:   *reportPath,
+		ReportFormat: *reportFormat,
+
+		TreePlanPath: *treePlan,
+
+		SummaryJSONPath: *summaryJSON,
+
+		NeedsMetadataPath: *needsMetadata,
+
+		IncludeCameras:       splitList(*includeCameras),
+		ExcludeCameras:       splitList(*excludeCameras),
+		IncludeUnknownCamera: *includeUnknownCamera,
+
+		ConvertHEICtoJPG: *convertHEIC,
+
+		MoveSidecars: *moveSidecars,
+
+		WalkCachePath: *walkCache,
+		WalkCacheTTL:  *walkCacheTTL,
+
+		DedupeReport:       *dedupeReport,
+		DedupeReportFormat: *dedupeReportFormat,
+
+		DestExistsAction: *destExistsAction,
+
+		ContactSheets: *contactSheets,
+
+		ExiftoolBatchSize: *exiftoolBatchSize,
+
+		SourceManifest: *sourceManifest,
+
+		DefaultClockDates: append([]string{}, splitList(*defaultClockDates)...),
+
+		MaxPathLength: *maxPathLength,
+
+		PauseFile: *pauseFile,
+
+		ExifWriteMinConfidence: *exifWriteMinConfidence,
+
+		SeparateScreenshots: *separateScreenshots,
+		ScreenshotsDir:      *screenshotsDir,
+
+		RelinkExisting: *relinkExisting,
+
+		DedupKeep: *dedupKeep,
+
+		DateConflictPrefer: *dateConflictPrefer,
+
+		Histogram:       *histogram,
+		HistogramFormat: *histogramFormat,
+
+		ExifOffset: *exifOffsetFlag,
+
+		RetryErroredAtEnd: *retryErroredAtEnd,
+
+		IncludePDF:   *includePDF,
+		PDFWriteDate: *pdfWriteDate,
+
+		CompareExifPath:      *compareExif,
+		CompareExifTolerance: *compareExifTolerance,
+
+		WriteMetadataSidecar: *writeMetadataSidecar,
+
+		ForceReprocess: *forceReprocess,
+
+		InventoryPath:    *inventory,
+		InventoryFormat:  *inventoryFormat,
+		InventoryWorkers: *inventoryWorkers,
+
+		GeocodeDB: *geocodeDB,
+
+		TransferProgressInterval: *transferProgress,
+
+		ProgressEveryN:   *progressEvery,
+		ProgressInterval: *progressInterval,
+	}
+
+	if config.DedupeReport {
+		if err := config.Validate(); err != nil {
+			log.Fatalf("Error: invalid configuration: %v", err)
+		}
+		if err := NewPhotoProcessor(config).DedupeReport(os.Stdout); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if config.RelinkExisting {
+		if err := config.Validate(); err != nil {
+			log.Fatalf("Error: invalid configuration: %v", err)
+		}
+		if err := NewPhotoProcessor(config).RelinkExisting(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if config.Histogram != "" {
+		if err := config.Validate(); err != nil {
+			log.Fatalf("Error: invalid configuration: %v", err)
+		}
+		if err := NewPhotoProcessor(config).Histogram(os.Stdout); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if config.CompareExifPath != "" {
+		if err := config.Validate(); err != nil {
+			log.Fatalf("Error: invalid configuration: %v", err)
+		}
+		if err := NewPhotoProcessor(config).CompareExifVsFilename(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if config.InventoryPath != "" {
+		if err := config.Validate(); err != nil {
+			log.Fatalf("Error: invalid configuration: %v", err)
+		}
+		if err := NewPhotoProcessor(config).Inventory(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
 	}
 
 	if err := run(config); err != nil {
@@ -55,7 +438,81 @@ func main() {
 	fmt.Println("Photo reorganization complete!")
 }
 
+// parseFileMode parses an octal permission string like "0775"; empty means unset
+func parseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an octal mode", s)
+	}
+	return os.FileMode(mode), nil
+}
+
 func run(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	processor := NewPhotoProcessor(config)
 	return processor.Process()
 }
+
+// parseTimeOfDay parses "HH:MM" or "HH:MM:SS" into an offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+		}
+	}
+	return 0, fmt.Errorf("expected HH:MM or HH:MM:SS, got %q", s)
+}
+
+// parseWorkers parses a worker count, or "auto" for WorkersAuto
+func parseWorkers(s string) (int, error) {
+	if strings.EqualFold(s, "auto") {
+		return WorkersAuto, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number or auto, got %q", s)
+	}
+	return n, nil
+}
+
+// parseByteSize parses a size such as "10KB", "1.5M" or "2048" (bytes); units are binary (1KB = 1024 bytes)
+func parseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	number := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(s[len(number):]))
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("%q is not a size (e.g. 10KB)", s)
+	}
+	multipliers := map[string]float64{
+		"": 1, "B": 1,
+		"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+		"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+		"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+	}
+	multiplier, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q in %q (use B, KB, MB or GB)", unit, s)
+	}
+	return int64(value * multiplier), nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
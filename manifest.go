@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readSourceManifest lists the files named in Config.SourceManifest instead of walking
+// dir. Paths are one per line, relative to the source directory or absolute; blank lines
+// and lines starting with # are ignored. Entries that are missing or outside dir are
+// logged and counted in ManifestMissing rather than failing the run.
+func (p *PhotoProcessor) readSourceManifest(dir string) ([]SourceFile, error) {
+	f, err := os.Open(p.config.SourceManifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source manifest: %w", err)
+	}
+	defer f.Close()
+
+	// Every line is read before any is checked, so a remote source checks them all at once
+	type entry struct {
+		lineNum int
+		line    string
+		path    string
+	}
+	var entries []entry
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		path := line
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.config.SourceDir, path)
+		}
+		path = filepath.Clean(path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			log.Printf("Error: source manifest line %d: %s is not under %s", lineNum, line, dir)
+			p.stats.ManifestMissing++
+			continue
+		}
+		entries = append(entries, entry{lineNum: lineNum, line: line, path: path})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read source manifest: %w", err)
+	}
+
+	var readable map[string]bool
+	if checker, ok := p.source.(readableChecker); ok && len(entries) > 0 {
+		paths := make([]string, len(entries))
+		for i, e := range entries {
+			paths[i] = e.path
+		}
+		if readable, err = checker.ReadableFiles(paths); err != nil {
+			return nil, fmt.Errorf("failed to check source manifest files: %w", err)
+		}
+	}
+
+	var files []SourceFile
+	for _, e := range entries {
+		if readable != nil {
+			if !readable[e.path] {
+				log.Printf("Error: source manifest line %d: %s: not a readable file", e.lineNum, e.line)
+				p.stats.ManifestMissing++
+				continue
+			}
+		} else if _, err := p.source.ReadHead(e.path, 1); err != nil {
+			// Reading a byte checks the file exists and is readable
+			log.Printf("Error: source manifest line %d: %s: %v", e.lineNum, e.line, err)
+			p.stats.ManifestMissing++
+			continue
+		}
+
+		// Sizes aren't known without a walk, so MinFileSize keeps every listed file
+		files = append(files, SourceFile{Path: e.path, Size: -1})
+	}
+
+	log.Printf("Read %d files from source manifest %s", len(files), p.config.SourceManifest)
+	return files, nil
+}
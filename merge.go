@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// What to do when a file's destination name is already taken
+const (
+	DestExistsOverwrite = "overwrite" // Replace the existing file (default)
+	DestExistsSkip      = "skip"      // Leave the existing file and skip the source, like SkipExisting
+	DestExistsMerge     = "merge"     // Skip the source if its content matches, otherwise write it under a _N name
+)
+
+// validateDestExistsAction checks a destination-exists action name ("" means overwrite)
+func validateDestExistsAction(action string) error {
+	switch action {
+	case "", DestExistsOverwrite, DestExistsSkip, DestExistsMerge:
+		return nil
+	default:
+		return fmt.Errorf("unsupported destination-exists action %q (use %s, %s or %s)", action, DestExistsOverwrite, DestExistsSkip, DestExistsMerge)
+	}
+}
+
+// skipExisting reports whether files whose destination already exists are skipped
+func (p *PhotoProcessor) skipExisting() bool {
+	return p.config.SkipExisting || p.config.DestExistsAction == DestExistsSkip
+}
+
+// mergeDestination finds where a file goes when merging into an existing library.
+// Starting at destPath and moving on to name_1, name_2, ..., it returns the first free
+// name, or the first existing file with the same content, reported as duplicate.
+// Content matches if the existing file equals either the source or the source with its
+// date written as it would be (a library this tool organized holds the latter).
+func (p *PhotoProcessor) mergeDestination(localPath, destPath string, timestamp time.Time) (target string, duplicate bool, err error) {
+	ext := filepath.Ext(destPath)
+	stem := strings.TrimSuffix(destPath, ext)
+
+	var sourceHash, preparedHash string
+	for counter := 0; ; counter++ {
+		target = destPath
+		if counter > 0 {
+			target = fmt.Sprintf("%s_%d%s", stem, counter, ext)
+		}
+
+		// A name another worker is about to write is taken, whatever its content
+		p.reserveMutex.Lock()
+		reserved := p.reservedNames[target]
+		p.reserveMutex.Unlock()
+		if reserved {
+			continue
+		}
+
+		exists, err := p.dest.Exists(target)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to check if file exists: %w", err)
+		}
+		if !exists {
+			p.reserveMutex.Lock()
+			reserved = p.reservedNames[target]
+			p.reservedNames[target] = true
+			p.reserveMutex.Unlock()
+			if reserved {
+				continue
+			}
+			return target, false, nil
+		}
+
+		destHash, err := p.destHash(target)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to hash %s: %w", target, err)
+		}
+		if sourceHash == "" {
+			if sourceHash, err = HashFile(localPath, p.config.HashAlgo); err != nil {
+				return "", false, fmt.Errorf("failed to hash source: %w", err)
+			}
+		}
+		if destHash == sourceHash {
+			return target, true, nil
+		}
+		if preparedHash == "" {
+			if preparedHash, err = p.preparedHash(localPath, ext, timestamp); err != nil {
+				return "", false, err
+			}
+		}
+		if destHash == preparedHash {
+			return target, true, nil
+		}
+	}
+}
+
+// destHash hashes a file at the destination, remotely where the host can
+func (p *PhotoProcessor) destHash(destPath string) (string, error) {
+	if p.destClient != nil {
+		return p.destClient.HashFile(destPath, p.config.HashAlgo)
+	}
+	localPath, cleanup, err := p.dest.Fetch(destPath)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return HashFile(localPath, p.config.HashAlgo)
+}
+
+// preparedHash hashes the file as writeToDestination would write it: converted to the
+// destination extension and with timestamp written to its metadata
+func (p *PhotoProcessor) preparedHash(localPath, ext string, timestamp time.Time) (string, error) {
+	tempFile, err := os.CreateTemp("", "photo-merge-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempPath)
+
+	if err := copyOrConvert(localPath, tempPath); err != nil {
+		return "", fmt.Errorf("failed to copy temp file: %w", err)
+	}
+	if canUpdateMetadata(tempPath) {
+		// A file whose date can't be written is compared as-is
+		UpdateExifDate(tempPath, timestamp)
+	}
+	return HashFile(tempPath, p.config.HashAlgo)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// metadataSidecarExt is appended to a destination file's name to name its metadata sidecar
+const metadataSidecarExt = ".json"
+
+// Date confidence levels recorded in metadata sidecars
+const (
+	ConfidenceHigh   = "high"   // A recorded capture time or a date given by hand
+	ConfidenceMedium = "medium" // Parsed from the file name
+	ConfidenceLow    = "low"    // Inferred from a folder
+)
+
+// sidecarDate is the date the tool derived for a file
+type sidecarDate struct {
+	Year       int    `json:"year"`
+	Month      int    `json:"month"`
+	Day        int    `json:"day"`
+	Time       string `json:"time,omitempty"`   // HH:MM:SS, when the name had one
+	Offset     string `json:"offset,omitempty"` // UTC offset from the name
+	Original   string `json:"original"`         // Name the date was parsed from
+	Confidence string `json:"confidence"`       // "high", "medium" or "low"
+	Source     string `json:"source,omitempty"` // Where the date came from: a DateSource
+}
+
+// sidecarGPS is where a photo was taken
+type sidecarGPS struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// metadataSidecar is the JSON document written next to each organized file,
+// recording everything the tool decided about it
+type metadataSidecar struct {
+	Source          string      `json:"source"`           // Original path
+	Destination     string      `json:"destination"`      // Path the file was written to
+	Date            sidecarDate `json:"date"`             // Date that chose the folder and name
+	Timestamp       string      `json:"timestamp"`        // Date written to the file, "YYYY-MM-DD HH:MM:SS"
+	TimestampSource string      `json:"timestamp_source"` // Where the date came from, as in the per-file report
+	Make            string      `json:"make,omitempty"`
+	Model           string      `json:"model,omitempty"`
+	GPS             *sidecarGPS `json:"gps,omitempty"`
+}
+
+// dateConfidence rates how far a date from the given source can be trusted
+func dateConfidence(source string) string {
+	switch source {
+	case "exif", "takeout", "override", "manual":
+		return ConfidenceHigh
+	case "folder", "range":
+		return ConfidenceLow
+	default:
+		return ConfidenceMedium
+	}
+}
+
+// confidenceRanks orders the confidence levels
+var confidenceRanks = map[string]int{ConfidenceLow: 1, ConfidenceMedium: 2, ConfidenceHigh: 3}
+
+// validateConfidence checks a minimum confidence level ("" accepts every level)
+func validateConfidence(level string) error {
+	if _, ok := confidenceRanks[level]; level != "" && !ok {
+		return fmt.Errorf("unsupported confidence level %q (use %s, %s or %s)", level, ConfidenceLow, ConfidenceMedium, ConfidenceHigh)
+	}
+	return nil
+}
+
+// meetsConfidence reports whether confidence is at least minimum ("" accepts every level)
+func meetsConfidence(confidence, minimum string) bool {
+	return confidenceRanks[confidence] >= confidenceRanks[minimum]
+}
+
+// writeMetadataSidecar writes the metadata sidecar of a file written to destPath.
+// localPath is the source file, read for its camera and GPS position.
+func (p *PhotoProcessor) writeMetadataSidecar(sourcePath, localPath, destPath string, dateInfo *DateInfo, timestamp time.Time, source string) error {
+	sidecar := metadataSidecar{
+		Source:      sourcePath,
+		Destination: destPath,
+		Date: sidecarDate{
+			Year:       dateInfo.Year,
+			Month:      dateInfo.Month,
+			Day:        dateInfo.Day,
+			Offset:     dateInfo.Offset,
+			Original:   dateInfo.Original,
+			Confidence: dateConfidence(source),
+			Source:     string(dateInfo.Source),
+		},
+		Timestamp:       timestamp.Format("2006-01-02 15:04:05"),
+		TimestampSource: source,
+	}
+	if dateInfo.HasTime {
+		sidecar.Date.Time = dateInfo.Time
+	}
+	if metadata, err := ReadExifData(localPath); err == nil {
+		sidecar.Make, sidecar.Model = metadata.Make, metadata.Model
+		if metadata.HasGPS {
+			sidecar.GPS = &sidecarGPS{Latitude: metadata.Latitude, Longitude: metadata.Longitude}
+		}
+	}
+
+	data, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp("", "photo-sidecar-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for metadata sidecar: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(append(data, '\n'))
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write temp metadata sidecar: %w", err)
+	}
+
+	return p.dest.Write(tempFile.Name(), destPath+metadataSidecarExt)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// errDestinationFull stops a run once the destination has no space left, since every
+// remaining file would fail the same way
+var errDestinationFull = errors.New("destination out of space")
+
+// outOfSpaceMessages are how remote shells, Windows and object stores report a full
+// destination when there's no errno to check (compared lowercased)
+var outOfSpaceMessages = []string{
+	"no space left on device",
+	"disk quota exceeded",
+	"not enough space on the disk",
+	"xminiostoragefull",
+	"insufficientstorage",
+	"quotaexceeded",
+}
+
+// isOutOfSpace reports whether err means the destination is full
+func isOutOfSpace(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range outOfSpaceMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// unparseableMarker is printed by parseOnly in place of a date the parser couldn't find
+const unparseableMarker = "UNPARSEABLE"
+
+// parseOnly reads one path per line from r and writes "path<TAB>date" for each to w,
+// with the date as "YYYY-MM-DD" or "YYYY-MM-DD HH:MM:SS", or UNPARSEABLE. Only the
+// names are parsed; nothing is read from the filesystem. Blank lines are skipped.
+// Returns the number of paths that couldn't be dated.
+func parseOnly(r io.Reader, w io.Writer, opts ParseOptions) (int, error) {
+	out := bufio.NewWriter(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	unparseable := 0
+	for scanner.Scan() {
+		path := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(path) == "" {
+			continue
+		}
+		date := unparseableMarker
+		if dateInfo, err := ParseDateFromFilenameWithOptions(path, opts); err == nil {
+			date = dateInfo.String()
+		} else {
+			unparseable++
+		}
+		fmt.Fprintf(out, "%s\t%s\n", path, date)
+	}
+	if err := scanner.Err(); err != nil {
+		out.Flush()
+		return unparseable, fmt.Errorf("failed to read paths: %w", err)
+	}
+	return unparseable, out.Flush()
+}
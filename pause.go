@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// pausePollInterval is how often a paused run checks whether the pause file is gone
+const pausePollInterval = 2 * time.Second
+
+// waitWhilePaused blocks while Config.PauseFile exists, so no new file is started
+// until it's removed; files already in progress finish. It returns false if ctx is
+// cancelled while paused.
+func (p *PhotoProcessor) waitWhilePaused(ctx context.Context) bool {
+	if p.config.PauseFile == "" {
+		return true
+	}
+	if _, err := os.Stat(p.config.PauseFile); err != nil {
+		return true
+	}
+
+	log.Printf("Paused: remove %s to resume", p.config.PauseFile)
+	paused := time.Now()
+	ticker := time.NewTicker(pausePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if _, err := os.Stat(p.config.PauseFile); err != nil {
+				log.Printf("Resumed after %s", time.Since(paused).Round(time.Second))
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isPDFFile checks if a file is a PDF (e.g. a scanned album page), processed with Config.IncludePDF
+func isPDFFile(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".pdf")
+}
+
+// pdfDateArgs returns the exiftool arguments setting a PDF's Info dictionary
+// CreationDate and ModDate to date, with exifOffset as its UTC offset when set
+func pdfDateArgs(date time.Time) []string {
+	value := date.Format("2006:01:02 15:04:05") + exifOffset
+	return []string{"-PDF:CreateDate=" + value, "-PDF:ModifyDate=" + value}
+}
+
+// updatePDFDateWithExiftool writes date into a PDF's CreationDate and ModDate.
+// PDFs carry no EXIF, so this is the only date they get.
+func updatePDFDateWithExiftool(filePath string, date time.Time) error {
+	if useDockerExiftool {
+		absPath, err := filepath.Abs(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		args := []string{"run", "--rm",
+			"-v", fmt.Sprintf("%s:/work", filepath.Dir(absPath)),
+			exiftoolDockerImage,
+			"-overwrite_original",
+		}
+		args = append(args, pdfDateArgs(date)...)
+		if _, err := runExiftool("docker", append(args, "/work/"+filepath.Base(absPath))...); err != nil {
+			return fmt.Errorf("failed to update PDF dates with Docker: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := exec.LookPath(exiftoolBinary); err != nil {
+		return fmt.Errorf("exiftool not found (%s). Please install it: %w", exiftoolBinary, err)
+	}
+	args := append([]string{"-overwrite_original"}, pdfDateArgs(date)...)
+	if _, err := runExiftool(exiftoolBinary, append(args, filePath)...); err != nil {
+		return fmt.Errorf("failed to update PDF dates: %w", err)
+	}
+	return nil
+}
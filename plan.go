@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// planFile is one file placed in the tree plan
+type planFile struct {
+	Source    string `json:"source"`              // Source path
+	Name      string `json:"name"`                // Standardized file name in the folder
+	Date      string `json:"date,omitempty"`      // Parsed date (DateInfo.String), empty for unknown/
+	Timestamp string `json:"timestamp,omitempty"` // Date written to the file's metadata, "YYYY-MM-DD HH:MM:SS"
+}
+
+// planNode is a destination folder in the tree plan
+type planNode struct {
+	Name  string      `json:"name"`            // Folder name ("" for the destination root)
+	Path  string      `json:"path"`            // Folder path relative to the destination ("" for the root)
+	Dirs  []*planNode `json:"dirs,omitempty"`  // Subfolders, sorted by name
+	Files []planFile  `json:"files,omitempty"` // Files in this folder, sorted by name
+}
+
+// addToPlan records where a file goes for the tree plan; dirPath is relative to DestDir
+func (p *PhotoProcessor) addToPlan(dirPath string, file planFile) {
+	if p.config.TreePlanPath == "" {
+		return
+	}
+	p.planMutex.Lock()
+	defer p.planMutex.Unlock()
+	p.plan[filepath.ToSlash(dirPath)] = append(p.plan[filepath.ToSlash(dirPath)], file)
+}
+
+// buildPlanTree nests the planned files by destination folder
+func buildPlanTree(plan map[string][]planFile) *planNode {
+	root := &planNode{}
+	nodes := map[string]*planNode{"": root}
+
+	var node func(path string) *planNode
+	node = func(path string) *planNode {
+		if n, ok := nodes[path]; ok {
+			return n
+		}
+		parentPath, name := "", path
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			parentPath, name = path[:i], path[i+1:]
+		}
+		parent := node(parentPath)
+		n := &planNode{Name: name, Path: path}
+		parent.Dirs = append(parent.Dirs, n)
+		nodes[path] = n
+		return n
+	}
+
+	for dir, files := range plan {
+		if dir == "." {
+			dir = ""
+		}
+		n := node(dir)
+		n.Files = append(n.Files, files...)
+	}
+
+	for _, n := range nodes {
+		sort.Slice(n.Dirs, func(i, j int) bool { return n.Dirs[i].Name < n.Dirs[j].Name })
+		sort.Slice(n.Files, func(i, j int) bool { return n.Files[i].Name < n.Files[j].Name })
+	}
+	return root
+}
+
+// writeTreePlan writes the planned destination hierarchy as nested JSON
+func (p *PhotoProcessor) writeTreePlan() error {
+	p.planMutex.Lock()
+	tree := buildPlanTree(p.plan)
+	p.planMutex.Unlock()
+
+	data, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.config.TreePlanPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write tree plan: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// preflightRemoteDest checks that the remote destination directory exists or can be
+// created and that a small file can be written and removed there, before any source
+// files are walked. Dry runs only check that the directory can be inspected.
+func (p *PhotoProcessor) preflightRemoteDest(client *SSHClient) error {
+	host, dir := p.config.DestSSHHost, p.config.DestDir
+
+	if p.config.DryRun {
+		exists, err := client.DirExists(dir)
+		if err != nil {
+			return fmt.Errorf("destination pre-flight failed: cannot inspect %s on %s: %w", dir, host, err)
+		}
+		if !exists {
+			log.Printf("Destination %s does not exist on %s yet; a real run will create it", dir, host)
+		}
+		return nil
+	}
+
+	if err := p.dest.MkdirAll(dir); err != nil {
+		return fmt.Errorf("destination pre-flight failed: cannot create %s on %s: %w", dir, host, err)
+	}
+
+	probe, err := os.CreateTemp("", "photo-preflight-*")
+	if err != nil {
+		return fmt.Errorf("failed to create pre-flight file: %w", err)
+	}
+	probe.WriteString("picture-metadata pre-flight check\n")
+	probe.Close()
+	defer os.Remove(probe.Name())
+
+	remotePath := filepath.Join(dir, fmt.Sprintf(".picture-metadata-preflight-%d", os.Getpid()))
+	if err := client.UploadFile(probe.Name(), remotePath); err != nil {
+		return fmt.Errorf("destination pre-flight failed: %s on %s is not writable: %w", dir, host, err)
+	}
+	if p.config.VerifyUpload {
+		if _, err := client.HashFile(remotePath, p.config.HashAlgo); err != nil {
+			client.RemoveFile(remotePath)
+			return fmt.Errorf("destination pre-flight failed: cannot hash uploads on %s for verification: %w", host, err)
+		}
+	}
+	if err := client.RemoveFile(remotePath); err != nil {
+		return fmt.Errorf("destination pre-flight failed: cannot remove test file %s on %s: %w", remotePath, host, err)
+	}
+
+	if p.config.Verbose {
+		log.Printf("Destination pre-flight passed: %s on %s is writable", dir, host)
+	}
+	return nil
+}
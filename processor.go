@@ -1,17 +1,29 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // PhotoProcessor handles the photo reorganization process
@@ -19,23 +31,100 @@ type PhotoProcessor struct {
 	config               *Config
 	stats                *ProcessStats
 	sshClient            *SSHClient
-	destSSHClient        *SSHClient
+	source               Source
+	dest                 Destination
 	startTime            time.Time
 	lastProgress         time.Time
 	statsMutex           sync.Mutex
+	callbackMutex        sync.Mutex           // Delivers ProgressCallback snapshots one at a time, outside statsMutex
 	timestampMap         map[string]time.Time // Tracks last timestamp used for each date (YYYY-MM-DD)
 	timestampMutex       sync.Mutex           // Protects timestampMap for concurrent access
 	timestampAssignments map[string]time.Time // Pre-allocated timestamps for each file path
+	sampleDir            string               // Directory receiving dry-run samples
+	samplesWritten       int                  // Number of dry-run samples written so far
+	sampleMutex          sync.Mutex           // Protects samplesWritten
+	reservedNames        map[string]bool      // Destination names claimed by a worker for an unknown/ file
+	reserveMutex         sync.Mutex           // Serializes unknown/ name reservation
+	geocoder             *Geocoder            // Offline reverse geocoder for place-named folders (nil disables)
+	sidecars             map[string][]string  // Sidecar files found next to each media file
+	contentExts          map[string]string    // Corrected extensions for files whose content doesn't match their name
+	report               *reportWriter        // Per-file result report (nil disables)
+
+	folderIndexes    map[string][]folderIndexEntry // Files written to each destination folder, for WriteFolderIndex
+	folderIndexMutex sync.Mutex                    // Protects folderIndexes
+
+	contactSheets     map[string][]contactEntry // Thumbnails of files written to each destination folder, for ContactSheets
+	contactSheetMutex sync.Mutex                // Protects contactSheets
+
+	exiftoolBatch *exiftoolBatcher // Dates non-JPEG files with one exiftool run per batch (nil dates each on its own)
+
+	dateOverrides *dateOverrides // Manually corrected dates from DateOverrideCSV (nil disables)
+
+	bursts map[string]*burstGroup // Burst each burst frame belongs to, for GroupBursts
+
+	takeoutJSON map[string]string // Google Takeout metadata file next to each media file
+
+	promptIn    *bufio.Reader // Terminal input for InteractiveUnknown, opened on the first prompt
+	promptMutex sync.Mutex    // Serializes prompts across workers
+	promptDone  bool          // The user quit or input ended; no more prompts
+
+	plan      map[string][]planFile // Files placed in each destination folder (relative to DestDir), for TreePlanPath
+	planMutex sync.Mutex            // Protects plan
+
+	destClient         *SSHClient // SSH connection to a remote destination (nil otherwise)
+	remoteExiftoolOnce sync.Once  // Guards the one-time check for exiftool on the destination host
+	remoteExiftool     bool       // Whether the destination host has exiftool, for DirectRemoteStream
 }
 
 // ProcessStats tracks statistics during processing
 type ProcessStats struct {
-	TotalFiles      int
-	ProcessedFiles  int
-	SkippedFiles    int
-	ErrorFiles      int
-	MovedFiles      int
-	UpdatedMetadata int
+	TotalFiles      int `json:"total_files"`
+	ProcessedFiles  int `json:"processed_files"`
+	SkippedFiles    int `json:"skipped_files"`
+	ErrorFiles      int `json:"error_files"`
+	MovedFiles      int `json:"moved_files"`
+	UpdatedMetadata int `json:"updated_metadata"`
+	// ExifVerifyFailures counts metadata writes that didn't read back as intended
+	ExifVerifyFailures int `json:"exif_verify_failures"`
+	// UploadVerifyFailures counts remote uploads whose hash didn't match, including ones retried successfully
+	UploadVerifyFailures int `json:"upload_verify_failures"`
+	// SidecarFiles counts XMP/AAE sidecars copied alongside their media
+	SidecarFiles int `json:"sidecar_files"`
+	// SkippedFiles is split by reason into these counters; any remainder had some other reason
+	SkippedNoDate        int `json:"skipped_no_date"`        // No date found, copied to unknown/
+	SkippedAlreadyExists int `json:"skipped_already_exists"` // Already at the destination, already processed or already standardized
+	SkippedFiltered      int `json:"skipped_filtered"`       // Left out by a filter (e.g. camera) or at the interactive prompt
+	SkippedDuplicate     int `json:"skipped_duplicate"`      // Content already written elsewhere in the run or destination
+	SkippedOutOfWindow   int `json:"skipped_out_of_window"`  // Dated outside the requested date window
+	// CameraFiltered counts files left untouched by the camera make/model filters
+	CameraFiltered int `json:"camera_filtered"`
+	// DerivedPreviews counts thumbnails and exported previews left out by SkipDerivedPreviews
+	DerivedPreviews int `json:"derived_previews"`
+	// SmallFiles counts media files left out for being smaller than MinFileSize
+	SmallFiles int `json:"small_files"`
+	// IgnoredFiles counts files excluded by .picmetaignore files
+	IgnoredFiles int `json:"ignored_files"`
+	// ManifestMissing counts SourceManifest lines naming a file that is missing, unreadable
+	// or outside the source; they aren't part of TotalFiles
+	ManifestMissing int `json:"manifest_missing"`
+	// RangeFolderDated counts files dated only by the year-range folder they are in (RangeFolderDate)
+	RangeFolderDated int `json:"range_folder_dated"`
+	// LongPaths counts dry-run destination paths longer than MaxPathLength
+	LongPaths int `json:"long_paths"`
+	// DatesNotWritten counts files whose date was below ExifWriteMinConfidence, so their metadata was left alone
+	DatesNotWritten int `json:"dates_not_written"`
+	// DateConflicts counts files whose name and folder gave different years (DateConflictPrefer picked one)
+	DateConflicts int `json:"date_conflicts"`
+	// RetryRecovered counts files that failed in the main pass but succeeded on retry (RetryErroredAtEnd)
+	RetryRecovered int `json:"retry_recovered"`
+	// NeedsMetadata lists destination files written without their date in their metadata,
+	// because exiftool was unavailable or the update failed, for a later fix-metadata run
+	// (the run summary only carries its length)
+	NeedsMetadata []string `json:"-"`
+	// Transfers holds the latest progress of each long upload or download in flight, by
+	// file, so concurrent workers don't overwrite each other's. A transfer is dropped
+	// once the snapshot carrying its final report has been taken.
+	Transfers map[string]TransferStatus `json:"-"`
 }
 
 // NewPhotoProcessor creates a new photo processor
@@ -45,6 +134,108 @@ func NewPhotoProcessor(config *Config) *PhotoProcessor {
 		stats:                &ProcessStats{},
 		timestampMap:         make(map[string]time.Time),
 		timestampAssignments: make(map[string]time.Time),
+		contentExts:          make(map[string]string),
+		reservedNames:        make(map[string]bool),
+		folderIndexes:        make(map[string][]folderIndexEntry),
+		contactSheets:        make(map[string][]contactEntry),
+		plan:                 make(map[string][]planFile),
+	}
+}
+
+// nameOptions returns the filename options derived from the configuration
+func (p *PhotoProcessor) nameOptions() NameOptions {
+	opts := DefaultNameOptions()
+	if p.config.WordSeparator != "" {
+		opts.Separator = p.config.WordSeparator
+	}
+	opts.StripDatePrefix = p.config.StripDatePrefix
+	opts.NormalizeWhitespace = p.config.NormalizeWhitespace
+	return opts
+}
+
+// dirMode returns the mode for created destination directories
+func (p *PhotoProcessor) dirMode() os.FileMode {
+	if p.config.DirMode != 0 {
+		return p.config.DirMode
+	}
+	return 0755
+}
+
+// WorkersAuto as Config.Workers picks the worker count automatically (see autoWorkers)
+const WorkersAuto = -1
+
+// remoteAutoWorkers is the automatic worker count when files go over the network
+const remoteAutoWorkers = 4
+
+// workers returns the number of files processed concurrently
+func (p *PhotoProcessor) workers() int {
+	if p.config.Workers == WorkersAuto {
+		n, _ := p.autoWorkers()
+		return n
+	}
+	if p.config.Workers < 1 {
+		return 1
+	}
+	return p.config.Workers
+}
+
+// autoWorkers picks the worker count for WorkersAuto and says why. Local runs are
+// bound by CPU (hashing, EXIF, HEIC conversion), so they get one worker per CPU; runs
+// that move files over the network are bound by it instead, and more concurrent
+// transfers than remoteAutoWorkers mostly just load the NAS or the link.
+func (p *PhotoProcessor) autoWorkers() (int, string) {
+	if p.config.SSHHost != "" || p.config.RemoteDest || p.config.S3Bucket != "" {
+		return remoteAutoWorkers, "network transfers"
+	}
+	return runtime.NumCPU(), "one per CPU"
+}
+
+// count increments a statistics counter; workers update stats concurrently
+func (p *PhotoProcessor) count(field *int) {
+	p.statsMutex.Lock()
+	*field++
+	p.statsMutex.Unlock()
+}
+
+// skip counts a skipped file in SkippedFiles and, unless reason is nil, its reason's counter
+func (p *PhotoProcessor) skip(reason *int) {
+	p.statsMutex.Lock()
+	p.stats.SkippedFiles++
+	if reason != nil {
+		*reason++
+	}
+	p.statsMutex.Unlock()
+}
+
+// needsMetadata records a destination file whose metadata still has to be fixed
+func (p *PhotoProcessor) needsMetadata(destPath string) {
+	p.statsMutex.Lock()
+	p.stats.NeedsMetadata = append(p.stats.NeedsMetadata, destPath)
+	p.statsMutex.Unlock()
+}
+
+// writeNeedsMetadata writes the files that still need their metadata fixed, one per line
+func (p *PhotoProcessor) writeNeedsMetadata() error {
+	p.statsMutex.Lock()
+	paths := append([]string{}, p.stats.NeedsMetadata...)
+	p.statsMutex.Unlock()
+
+	sort.Strings(paths)
+	var data []byte
+	for _, path := range paths {
+		data = append(data, path+"\n"...)
+	}
+	if err := os.WriteFile(p.config.NeedsMetadataPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write needs-metadata list: %w", err)
+	}
+	return nil
+}
+
+// parseOptions returns the date parsing options derived from the configuration
+func (p *PhotoProcessor) parseOptions() ParseOptions {
+	return ParseOptions{
+		PreferEarliestYear: p.config.PreferEarliestYear,
+		DateOrder:          p.config.DateOrder,
 	}
 }
 
@@ -86,7 +277,84 @@ func naturalLess(a, b string) bool {
 	}
 
 	// If all parts match, shorter string comes first
-	return len(aParts) < len(bParts)
+	if len(aParts) != len(bParts) {
+		return len(aParts) < len(bParts)
+	}
+	// Names that only differ in leading zeros (IMG_01, IMG_1) fall back to a plain
+	// comparison, so the order never depends on the order files were listed in
+	return a < b
+}
+
+// Orders in which files are handed to workers
+const (
+	ProcessOrderSorted  = "sorted"  // Natural sort order of the source paths (default), identical across runs
+	ProcessOrderAsFound = "asfound" // The order the source walk listed files in
+)
+
+// validateProcessOrder checks a processing order name ("" means sorted)
+func validateProcessOrder(order string) error {
+	switch order {
+	case "", ProcessOrderSorted, ProcessOrderAsFound:
+		return nil
+	default:
+		return fmt.Errorf("unsupported process order %q (use %s or %s)", order, ProcessOrderSorted, ProcessOrderAsFound)
+	}
+}
+
+// configureExiftool applies the EXIF and exiftool settings shared by every read and write
+func (p *PhotoProcessor) configureExiftool() {
+	exiftoolTimeout = p.config.ExiftoolTimeout
+	if p.config.ExifDateTags != nil {
+		exifDateTags, _ = canonicalExifDateTags(p.config.ExifDateTags)
+	}
+	if p.config.DefaultClockDates != nil {
+		defaultClockDates = p.config.DefaultClockDates
+	}
+	if p.config.ExiftoolDockerImage != "" {
+		exiftoolDockerImage = p.config.ExiftoolDockerImage
+	}
+	if p.config.ExiftoolPath != "" {
+		exiftoolBinary = p.config.ExiftoolPath
+	}
+	exifOffset = p.config.ExifOffset
+}
+
+// openSource sets up p.source for the configured source; the returned function releases it
+func (p *PhotoProcessor) openSource() (func(), error) {
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	// Initialize SSH client for source if needed
+	if p.config.SSHHost != "" {
+		client, err := NewSSHClient(p.config.SSHHost, p.config.SSHPort)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SSH client for source: %w", err)
+		}
+		p.sshClient = client
+		closers = append(closers, func() { client.Close() })
+		client.StartKeepalive(p.config.SSHKeepalive)
+		client.SetTransferProgress(p.transferReporter())
+		p.source = sshSource{client: client, parallelism: p.config.ParallelWalks, pruneDirs: p.junkDirs(), withSizes: p.config.MinFileSize > 0}
+	} else {
+		p.source = localSource{pruneDirs: p.junkDirs()}
+	}
+
+	// Reuse a saved enumeration of the source if requested
+	if p.config.WalkCachePath != "" {
+		p.source = cachedSource{Source: p.source, cachePath: p.config.WalkCachePath, ttl: p.config.WalkCacheTTL, host: p.config.SSHHost, sized: p.config.MinFileSize > 0}
+	}
+
+	// List the entries of ZIP archives alongside regular files
+	if p.config.ProcessArchives {
+		archives := newArchiveSource(p.source)
+		closers = append(closers, archives.Close)
+		p.source = archives
+	}
+	return closeAll, nil
 }
 
 // Process runs the photo reorganization process
@@ -94,39 +362,115 @@ func (p *PhotoProcessor) Process() error {
 	p.startTime = time.Now()
 	p.lastProgress = time.Now()
 
+	stopProfiling, err := p.startProfiling()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
 	// Check if exiftool is available
+	p.configureExiftool()
 	if !checkExiftoolAvailable() {
-		log.Println("Warning: exiftool not found. EXIF metadata will not be updated.")
+		log.Println("Warning: exiftool not found. EXIF metadata will only be updated for JPEGs.")
 		log.Println("Install exiftool: https://exiftool.org/")
 	}
-
-	// Initialize SSH client for source if needed
-	if p.config.SSHHost != "" {
-		client, err := NewSSHClient(p.config.SSHHost)
-		if err != nil {
-			return fmt.Errorf("failed to create SSH client for source: %w", err)
+	if p.config.ExiftoolBatchSize > 0 {
+		// A Docker exiftool only sees the one folder mounted for it, and a batch spans many
+		if checkExiftoolAvailable() && !useDockerExiftool {
+			p.exiftoolBatch = newExiftoolBatcher(p.config.ExiftoolBatchSize, p.batchTag(), p.config.Verbose)
+		} else {
+			log.Println("Warning: batched exiftool runs need a native exiftool; dating files one at a time")
 		}
-		p.sshClient = client
-		defer p.sshClient.Close()
 	}
 
-	// Initialize SSH client for destination if needed
-	if p.config.RemoteDest {
+	if p.config.Workers == WorkersAuto {
+		n, reason := p.autoWorkers()
+		log.Printf("Using %d workers (auto: %s)", n, reason)
+	}
+
+	if p.config.ConvertHEICtoJPG && findHEICDecoder() == nil {
+		log.Println("Warning: no HEIC decoder found (heif-convert, ImageMagick or sips). HEIC files will be kept as HEIC.")
+	}
+
+	closeSource, err := p.openSource()
+	if err != nil {
+		return err
+	}
+	defer closeSource()
+
+	// Initialize the destination backend
+	switch {
+	case p.config.S3Bucket != "":
+		if p.config.RemoteDest {
+			return fmt.Errorf("-remote-dest and -s3-bucket are mutually exclusive")
+		}
+		client, err := NewS3Client(p.config.S3Endpoint, p.config.S3Bucket, p.config.S3Region, p.config.S3AccessKey, p.config.S3SecretKey)
+		if err != nil {
+			return fmt.Errorf("failed to create S3 client for destination: %w", err)
+		}
+		client.SetTransferProgress(p.transferReporter())
+		p.dest = s3Destination{client: client}
+	case p.config.RemoteDest:
 		if p.config.DestSSHHost == "" {
 			return fmt.Errorf("remote destination requires -dest-ssh-host or -ssh-host")
 		}
 
 		// If dest and source are on same host, reuse the connection
-		if p.config.DestSSHHost == p.config.SSHHost && p.sshClient != nil {
-			p.destSSHClient = p.sshClient
-		} else {
-			client, err := NewSSHClient(p.config.DestSSHHost)
+		client := p.sshClient
+		if p.config.DestSSHHost != p.config.SSHHost || p.config.DestSSHPort != p.config.SSHPort || client == nil {
+			var err error
+			client, err = NewSSHClient(p.config.DestSSHHost, p.config.DestSSHPort)
 			if err != nil {
 				return fmt.Errorf("failed to create SSH client for destination: %w", err)
 			}
-			p.destSSHClient = client
-			defer p.destSSHClient.Close()
+			defer client.Close()
+			client.StartKeepalive(p.config.SSHKeepalive)
+			client.SetTransferProgress(p.transferReporter())
 		}
+		p.dest = sshDestination{client: client, dirMode: p.dirMode(), fileMode: p.config.FileMode}
+		p.destClient = client
+
+		// Fail now rather than thousands of files into the run
+		if err := p.preflightRemoteDest(client); err != nil {
+			return err
+		}
+	default:
+		p.dest = localDestination{dirMode: p.dirMode(), fileMode: p.config.FileMode}
+	}
+	p.dest = newDirCachingDestination(p.dest)
+
+	// Load manually corrected dates
+	if p.config.DateOverrideCSV != "" {
+		overrides, err := loadDateOverrides(p.config.DateOverrideCSV)
+		if err != nil {
+			return err
+		}
+		p.dateOverrides = overrides
+	}
+
+	// Load the offline geocode database if place-named folders were requested
+	if p.config.GeocodeDB != "" {
+		geocoder, err := NewGeocoder(p.config.GeocodeDB)
+		if err != nil {
+			return err
+		}
+		p.geocoder = geocoder
+	}
+
+	// Prepare the dry-run sample directory if samples were requested
+	if p.config.DryRun && p.config.DryRunSamples > 0 {
+		sampleDir := p.config.DryRunSampleDir
+		if sampleDir == "" {
+			dir, err := os.MkdirTemp("", "picture-metadata-samples-*")
+			if err != nil {
+				return fmt.Errorf("failed to create dry-run sample directory: %w", err)
+			}
+			sampleDir = dir
+		} else if err := os.MkdirAll(sampleDir, 0755); err != nil {
+			return fmt.Errorf("failed to create dry-run sample directory: %w", err)
+		}
+		p.sampleDir = sampleDir
+		log.Printf("Writing up to %d dry-run samples to: %s", p.config.DryRunSamples, sampleDir)
 	}
 
 	// Determine the directory to process
@@ -134,686 +478,1229 @@ func (p *PhotoProcessor) Process() error {
 	if p.config.TestDir != "" {
 		// TestDir is relative to SourceDir
 		processDir = filepath.Join(p.config.SourceDir, p.config.TestDir)
+		exists, err := p.source.DirExists(processDir)
+		if err != nil {
+			return fmt.Errorf("failed to check test directory %s: %w", processDir, err)
+		}
+		if !exists {
+			return fmt.Errorf("test directory %s does not exist under the source", processDir)
+		}
 		log.Printf("Processing test directory: %s", processDir)
 	}
 
-	// Walk through source directory
-	err := p.walkDirectory(processDir)
-	if err != nil {
-		return fmt.Errorf("failed to process directory: %w", err)
+	// Stream per-file results to the report as they are produced
+	if p.config.ReportPath != "" {
+		report, err := newReportWriter(p.config.ReportPath, p.config.ReportFormat)
+		if err != nil {
+			return err
+		}
+		p.report = report
+		defer func() {
+			if err := report.Close(); err != nil {
+				log.Printf("Warning: failed to finish report %s: %v", p.config.ReportPath, err)
+			}
+		}()
 	}
 
-	// Print statistics
-	p.printStats()
+	// On Ctrl-C or SIGTERM stop handing out files and let the ones in progress finish,
+	// so the shutdown below still leaves complete indexes, statistics and a closed report
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	go func() {
+		select {
+		case <-interrupted:
+			// A second signal gets the default behaviour and exits immediately
+			signal.Stop(interrupted)
+			log.Println("Interrupted: finishing files in progress (interrupt again to quit immediately)")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
-	return nil
-}
+	// Walk through source directory
+	walkErr := p.walkDirectory(ctx, processDir)
 
-// walkDirectory recursively walks through directories and processes photos
-func (p *PhotoProcessor) walkDirectory(dir string) error {
-	if p.sshClient != nil {
-		return p.walkRemoteDirectory(dir)
+	// Every folder is complete once the walk is done; after an interruption or failure
+	// the indexes still list every file written so far
+	if p.config.WriteFolderIndex {
+		p.writeFolderIndexes()
+	}
+	if p.config.ContactSheets {
+		p.writeContactSheets()
 	}
-	return p.walkLocalDirectory(dir)
-}
 
-// walkLocalDirectory walks through local directories
-func (p *PhotoProcessor) walkLocalDirectory(dir string) error {
-	// First pass: count total files
-	imageFiles := []string{}
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Printf("Error accessing %s: %v", path, err)
-			return nil
+	// The tree plan covers every file placed, including after an interruption
+	if p.config.TreePlanPath != "" {
+		if err := p.writeTreePlan(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("Wrote tree plan: %s", p.config.TreePlanPath)
 		}
+	}
 
-		// Skip directories and non-photo files
-		if info.IsDir() {
-			// Skip @eaDir directories (Synology metadata)
-			if strings.Contains(path, "@eaDir") {
-				return filepath.SkipDir
-			}
-			return nil
+	// Files still missing their date can be fixed with a later fix-metadata run
+	if p.config.NeedsMetadataPath != "" {
+		if err := p.writeNeedsMetadata(); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("Wrote %d files needing metadata to %s", len(p.stats.NeedsMetadata), p.config.NeedsMetadataPath)
 		}
+	}
 
-		// Process only media files (images and videos)
-		if !isMediaFile(path) {
-			return nil
+	// Deliver the final snapshot to any progress callback
+	if p.config.ProgressCallback != nil {
+		p.printProgress(true)
+	}
+
+	// Print statistics
+	p.printStats()
+	if p.config.SummaryJSONPath != "" {
+		if err := p.writeSummary(walkErr); err != nil {
+			log.Printf("Warning: %v", err)
+		} else {
+			log.Printf("Wrote run summary: %s", p.config.SummaryJSONPath)
 		}
+	}
 
-		imageFiles = append(imageFiles, path)
-		return nil
-	})
+	if walkErr != nil {
+		return fmt.Errorf("failed to process directory: %w", walkErr)
+	}
+	return nil
+}
 
+// walkDirectory recursively walks through directories and processes photos
+// Once ctx is cancelled no further files are started
+func (p *PhotoProcessor) walkDirectory(ctx context.Context, dir string) error {
+	// A source manifest names the files up front, so the walk is skipped
+	var files []SourceFile
+	var err error
+	if p.config.SourceManifest != "" {
+		files, err = p.readSourceManifest(dir)
+	} else {
+		files, err = p.source.Walk(dir)
+	}
 	if err != nil {
 		return err
 	}
 
+	// First pass: count total files
+	imageFiles := p.selectMediaFiles(dir, files)
+
+	// Drop thumbnails and exported previews that would duplicate their original
+	if p.config.SkipDerivedPreviews {
+		derived := findDerivedPreviews(imageFiles, p.contentExts)
+		kept := imageFiles[:0]
+		for _, path := range imageFiles {
+			if derived[path] {
+				if p.config.Verbose {
+					log.Printf("Skipping (derived preview): %s", path)
+				}
+				continue
+			}
+			kept = append(kept, path)
+		}
+		p.stats.DerivedPreviews = len(imageFiles) - len(kept)
+		imageFiles = kept
+	}
+
 	p.stats.TotalFiles = len(imageFiles)
+	if p.config.MoveSidecars {
+		p.sidecars = findSidecars(files, imageFiles)
+	}
+	p.takeoutJSON = findTakeoutJSON(files, imageFiles)
 	log.Printf("Found %d media files to process", p.stats.TotalFiles)
 
 	// Sort files using natural sort to ensure correct numeric ordering
 	// (e.g., file1, file2, file10 instead of file1, file10, file2)
-	naturalSort(imageFiles)
+	// Sequential timestamps follow this order, so with asfound they follow the walk instead
+	if p.config.ProcessOrder != ProcessOrderAsFound {
+		naturalSort(imageFiles)
+	}
 
-	// Track last timestamp for sequential ordering
-	var lastTimestamp time.Time
+	// Of several identical source files, only the one DedupKeep picks is processed
+	if p.config.DedupKeep != "" {
+		sizes := make(map[string]int64, len(files))
+		for _, file := range files {
+			sizes[file.Path] = file.Size
+		}
+		imageFiles = p.dropSourceDuplicates(imageFiles, sizes)
+	}
 
-	// Process files sequentially in natural sort order
-	for _, path := range imageFiles {
-		err := p.processPhoto(path, &lastTimestamp)
-		if err != nil {
-			p.stats.ErrorFiles++
-			log.Printf("Error processing %s: %v", path, err)
+	// A Limit caps the run at the first files in processing order, after every filter so
+	// it counts files that will really be processed
+	if p.config.Limit > 0 && len(imageFiles) > p.config.Limit {
+		log.Printf("Limiting this run to the first %d of %d media files", p.config.Limit, len(imageFiles))
+		imageFiles = imageFiles[:p.config.Limit]
+		// Duplicates dropped above are already counted as skipped, so they stay in the total
+		p.stats.TotalFiles = len(imageFiles) + p.stats.SkippedDuplicate
+	}
+
+	// Keep burst frames together under their first frame's date
+	if p.config.GroupBursts {
+		p.bursts = findBursts(imageFiles)
+		if len(p.bursts) > 0 {
+			log.Printf("Found %d burst frames", len(p.bursts))
+		}
+	}
+
+	// A full destination stops the run instead of failing every remaining file
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	var outOfSpace atomic.Bool
+
+	// With RetryErroredAtEnd, failed files wait for a second attempt before they're reported
+	failed := make(map[int]FileResult)
+	var failedMutex sync.Mutex
+
+	// Process files concurrently; the sequencer keeps timestamps in natural sort order
+	seq := newTimestampSequencer()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	if p.exiftoolBatch != nil {
+		p.exiftoolBatch.setWorkers(p.workers())
+	}
+	for w := 0; w < p.workers(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p.exiftoolBatch != nil {
+				// A worker that's done no longer holds up the batch the others wait on
+				defer p.exiftoolBatch.workerDone()
+			}
+			for i := range jobs {
+				result := FileResult{Source: imageFiles[i]}
+				err := p.processPhotoRecovered(imageFiles[i], i, seq, &result)
+				seq.finish(i)
+				if err != nil {
+					p.count(&p.stats.ErrorFiles)
+					log.Printf("Error processing %s: %v", imageFiles[i], err)
+					result.Status, result.Error = ResultError, err.Error()
+					if isOutOfSpace(err) && outOfSpace.CompareAndSwap(false, true) {
+						log.Println("Destination out of space: finishing files in progress and stopping the run")
+						stop()
+					}
+					if p.config.RetryErroredAtEnd {
+						failedMutex.Lock()
+						failed[i] = result
+						failedMutex.Unlock()
+						p.printProgress(false)
+						continue
+					}
+				}
+				p.recordResult(result)
+
+				// Print progress every ProgressEveryN files or every ProgressInterval
+				p.printProgress(false)
+			}
+		}()
+	}
+
+	started := 0
+dispatch:
+	for i := range imageFiles {
+		// Hold back the next file while the run is paused
+		if !p.waitWhilePaused(ctx) {
+			break
+		}
+		select {
+		case jobs <- i:
+			started++
+		case <-ctx.Done():
+			break dispatch
 		}
+	}
+	close(jobs)
+	wg.Wait()
 
-		// Print progress every 100 files or every 10 seconds
-		p.printProgress(false)
+	// Retrying is pointless once the destination is full or the run was interrupted
+	if outOfSpace.Load() || started < len(imageFiles) {
+		for _, result := range failed {
+			p.recordResult(result)
+		}
+	} else {
+		p.retryErrored(imageFiles, failed, seq)
 	}
 
+	if outOfSpace.Load() {
+		return fmt.Errorf("%w: %d of %d files not processed", errDestinationFull, len(imageFiles)-started, len(imageFiles))
+	}
+	if started < len(imageFiles) {
+		return fmt.Errorf("interrupted with %d of %d files not processed", len(imageFiles)-started, len(imageFiles))
+	}
 	return nil
 }
 
-// walkRemoteDirectory walks through remote SSH directories
-func (p *PhotoProcessor) walkRemoteDirectory(dir string) error {
-	files, err := p.sshClient.WalkDirectory(dir)
-	if err != nil {
-		return err
-	}
+// selectMediaFiles returns the walked files to process: media files (RAW only when
+// asked) that aren't ignored, hidden or too small
+func (p *PhotoProcessor) selectMediaFiles(dir string, files []SourceFile) []string {
+	// Folders can exclude themselves or files within them with an ignore file
+	ignoreRules := loadIgnoreRules(p.source, files)
 
-	// First pass: count total files
 	imageFiles := []string{}
-	for _, path := range files {
-		// Skip @eaDir directories
-		if strings.Contains(path, "@eaDir") {
+	for _, file := range files {
+		if ignoreFile := ignoredBy(ignoreRules, file.Path); ignoreFile != "" {
+			if p.config.Verbose {
+				log.Printf("Skipping (ignored by %s): %s", ignoreFile, file.Path)
+			}
+			p.stats.IgnoredFiles++
 			continue
 		}
 
-		// Process only media files (images and videos)
-		if !isMediaFile(path) {
+		// Leave dotfiles and AppleDouble "._" files alone; they only look like media
+		if p.config.SkipHidden && isHiddenPath(dir, file.Path) {
+			if p.config.Verbose {
+				log.Printf("Skipping (hidden): %s", file.Path)
+			}
 			continue
 		}
 
-		imageFiles = append(imageFiles, path)
-	}
+		// Contact sheets from an earlier run aren't photos
+		if isContactSheet(file.Path) {
+			continue
+		}
 
-	p.stats.TotalFiles = len(imageFiles)
-	log.Printf("Found %d media files to process", p.stats.TotalFiles)
+		// Process only media files (images and videos), and RAW files and PDFs only when asked
+		// RAW containers are vendor-specific, so they're recognized by extension alone
+		if isRawFile(file.Path) {
+			if !p.config.IncludeRaw {
+				continue
+			}
+		} else if isPDFFile(file.Path) {
+			if !p.config.IncludePDF {
+				continue
+			}
+		} else if p.config.DetectByContent {
+			isMedia, fixedExt := detectMediaByContent(p.source, file.Path)
+			if !isMedia {
+				continue
+			}
+			if fixedExt != "" {
+				log.Printf("Content of %s is %s, using that extension", file.Path, fixedExt)
+				p.contentExts[file.Path] = fixedExt
+			}
+		} else if !isMediaFile(file.Path) {
+			continue
+		}
 
-	// Sort files using natural sort to ensure correct numeric ordering
-	// (e.g., file1, file2, file10 instead of file1, file10, file2)
-	naturalSort(imageFiles)
+		// Tiny files are thumbnails or junk rather than real photos; unknown sizes are kept
+		if p.config.MinFileSize > 0 && file.Size >= 0 && file.Size < p.config.MinFileSize {
+			if p.config.Verbose {
+				log.Printf("Skipping (%s, below minimum size): %s", formatBytes(file.Size), file.Path)
+			}
+			p.stats.SmallFiles++
+			continue
+		}
 
-	// Track last timestamp for sequential ordering
-	var lastTimestamp time.Time
+		imageFiles = append(imageFiles, file.Path)
+	}
+	return imageFiles
+}
 
-	// Process files sequentially in natural sort order
-	for _, path := range imageFiles {
-		err := p.processRemotePhoto(path, &lastTimestamp)
-		if err != nil {
-			p.stats.ErrorFiles++
-			log.Printf("Error processing %s: %v", path, err)
+// processPhotoRecovered runs processPhoto, turning a panic into an error for that file
+// so a single bad file can't kill the run before its report and indexes are written
+func (p *PhotoProcessor) processPhotoRecovered(filePath string, index int, seq *timestampSequencer, result *FileResult) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic processing %s: %v\n%s", filePath, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
 		}
+	}()
+	return p.processPhoto(filePath, index, seq, result)
+}
+
+// fetchedFile makes a source file available locally the first time it is needed,
+// so files that are skipped early are never downloaded
+type fetchedFile struct {
+	source    Source
+	path      string
+	local     string
+	cleanup   func()
+	headBytes int // When set, metadata is read from only this many leading bytes unless the whole file was fetched
+	head      string
+	headClean func()
+}
 
-		// Print progress every 100 files or every 10 seconds
-		p.printProgress(false)
+// Local returns the local path of the file, fetching it on first use
+func (f *fetchedFile) Local() (string, error) {
+	if f.local != "" {
+		return f.local, nil
 	}
+	local, cleanup, err := f.source.Fetch(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.local, f.cleanup = local, cleanup
+	return local, nil
+}
 
-	return nil
+// MetadataPath returns a local path to read the file's metadata from. With headBytes set
+// only the start of the file is fetched, which holds the EXIF of photos and most videos.
+func (f *fetchedFile) MetadataPath() (string, error) {
+	if f.headBytes <= 0 || f.local != "" {
+		return f.Local()
+	}
+	if f.head != "" {
+		return f.head, nil
+	}
+	data, err := f.source.ReadHead(f.path, f.headBytes)
+	if err != nil {
+		return "", err
+	}
+	tempFile, err := os.CreateTemp("", "photo-head-*"+filepath.Ext(f.path))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tempPath := tempFile.Name()
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	f.head, f.headClean = tempPath, func() { os.Remove(tempPath) }
+	return tempPath, nil
+}
+
+// Close removes any local copy
+func (f *fetchedFile) Close() {
+	if f.cleanup != nil {
+		f.cleanup()
+	}
+	if f.headClean != nil {
+		f.headClean()
+	}
 }
 
-// processPhoto processes a single photo file
-func (p *PhotoProcessor) processPhoto(filePath string, lastTimestamp *time.Time) error {
+// processPhoto processes a single photo file from the source, filling in result as it goes
+// index is the file's position in natural sort order, used to keep sequential timestamps in order
+func (p *PhotoProcessor) processPhoto(filePath string, index int, seq *timestampSequencer, result *FileResult) error {
 	if p.config.Verbose {
 		log.Printf("Processing: %s", filePath)
 	}
 
-	// Parse date from filename
-	dateInfo, err := ParseDateFromFilename(filePath)
-	if err != nil {
+	src := &fetchedFile{source: p.source, path: filePath}
+	if p.config.DirectRemoteStream {
+		// The file itself is streamed host to host, so only fetch what the metadata needs
+		src.headBytes = directStreamHeadBytes
+	}
+	defer src.Close()
+
+	// Leave files from other cameras untouched
+	if p.cameraFilterEnabled() {
+		localPath, err := src.MetadataPath()
+		if err != nil {
+			return err
+		}
+		metadata, err := ReadExifData(localPath)
+		if err != nil {
+			return err
+		}
+		if !p.cameraAllowed(metadata) {
+			if p.config.Verbose {
+				log.Printf("Skipping (camera filtered: %q %q): %s", metadata.Make, metadata.Model, filePath)
+			}
+			p.count(&p.stats.CameraFiltered)
+			p.skip(&p.stats.SkippedFiltered)
+			result.Status, result.Reason = ResultSkipped, "camera filtered"
+			return nil
+		}
+	}
+
+	dateInfo, exactTimestamp, dateSource, err := p.resolveDate(filePath, src)
+	if err != nil && !errors.Is(err, errNoDate) {
+		return err
+	}
+
+	// Every frame of a burst takes the date (and so the folder) of its first frame;
+	// its own EXIF time is then only used if it agrees with that date's year
+	if burst := p.bursts[filePath]; burst != nil && burst.leader != filePath {
+		if leaderDate := p.burstDate(burst); leaderDate != nil {
+			dateInfo = leaderDate.withOriginal(filepath.Base(filePath)).withSource(DateSourceBurst)
+			exactTimestamp, dateSource = time.Time{}, "parsed"
+		}
+	}
+	if dateSource == "range" {
+		p.count(&p.stats.RangeFolderDated)
+	}
+
+	// Let the user date what nothing else could
+	if dateInfo == nil && p.config.InteractiveUnknown {
+		var answer promptAnswer
+		dateInfo, answer = p.promptForDate(filePath, src)
+		switch answer {
+		case answerSkip:
+			p.skip(&p.stats.SkippedFiltered)
+			result.Status, result.Reason = ResultSkipped, "skipped at prompt"
+			return nil
+		case answerDate:
+			dateInfo = dateInfo.withSource(DateSourceManual)
+			exactTimestamp, dateSource = dateInfo.ToTimeWithDefault(p.config.DefaultTimeOfDay), "manual"
+		}
+	}
+	if dateInfo == nil {
 		log.Printf("Skipping (no date found): %s -> unknown/", filePath)
 
 		// Copy to "unknown" folder instead of skipping
+		result.Status = ResultUnknown
 		if !p.config.DryRun {
-			base := filepath.Base(filePath)
-			unknownPath := filepath.Join(p.config.DestDir, "unknown", base)
-
-			if err := os.MkdirAll(filepath.Join(p.config.DestDir, "unknown"), 0755); err != nil {
-				return fmt.Errorf("failed to create unknown directory: %w", err)
+			localPath, err := src.Local()
+			if err != nil {
+				log.Printf("ERROR: Failed to fetch file: %s - %v", filePath, err)
+				return err
 			}
 
-			// Handle duplicate filenames by appending a counter
-			finalPath := unknownPath
-			counter := 1
-			ext := filepath.Ext(base)
-			nameWithoutExt := strings.TrimSuffix(base, ext)
-			for {
-				if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-					break
-				}
-				finalPath = filepath.Join(p.config.DestDir, "unknown", fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
-				counter++
+			base := filepath.Base(filePath)
+			if fixedExt, ok := p.contentExts[filePath]; ok {
+				base = strings.TrimSuffix(base, fileExt(base)) + fixedExt
 			}
-
-			if err := copyFile(filePath, finalPath); err != nil {
-				log.Printf("ERROR: Failed to copy to unknown: %s - %v", filePath, err)
-				return fmt.Errorf("failed to copy to unknown: %w", err)
+			unknownPath, err := p.copyToUnknown(localPath, base)
+			if err != nil {
+				return err
 			}
+			result.Destination = unknownPath
+		}
+		unknownName := filepath.Base(filePath)
+		if result.Destination != "" {
+			unknownName = filepath.Base(result.Destination)
 		}
-		p.stats.SkippedFiles++
+		p.addToPlan("unknown", planFile{Source: filePath, Name: unknownName})
+		p.skip(&p.stats.SkippedNoDate)
 		return nil
 	}
 
-	// Extract description from filename
-	base := filePath[strings.LastIndex(filePath, "/")+1:]
-	ext := filePath[strings.LastIndex(filePath, "."):]
-	desc := strings.TrimSuffix(base, ext)
+	// Surface names and folders that disagree on the year for review
+	if dateSource == "parsed" {
+		if nameDate, folderDate := p.dateConflict(filePath); folderDate != nil {
+			result.DateConflict = fmt.Sprintf("filename %s, folder %s", nameDate, folderDate)
+			p.count(&p.stats.DateConflicts)
+			log.Printf("Date conflict: %s: filename says %s, folder says %s; using %s", filePath, nameDate, folderDate, dateInfo.Source)
+		}
+	}
+	result.DateSource = string(dateInfo.Source)
+	if p.config.Verbose {
+		log.Printf("[Date] %s -> %s (from %s)", filePath, dateInfo, dateInfo.Source)
+	}
 
-	// Extract directory context and prepend to description
-	dirContext := ExtractDirectoryContext(filePath, p.config.SourceDir)
-	if dirContext != "" {
-		desc = dirContext + "_" + desc
+	// Leave files that are already organized alone rather than renaming and re-stamping them
+	if !p.config.ForceReprocess && p.alreadyStandardized(filePath, dateInfo) {
+		if p.config.Verbose {
+			log.Printf("Skipping (already standardized): %s", filePath)
+		}
+		p.skip(&p.stats.SkippedAlreadyExists)
+		result.Status, result.Reason = ResultSkipped, "already standardized"
+		return nil
 	}
 
-	// Generate standardized filename
-	newFilename := dateInfo.StandardizedFilename(desc, ext)
-	destPath := filepath.Join(p.config.DestDir, dateInfo.GetDirectoryPath(), newFilename)
+	// Work out the destination folder and standardized name
+	dirPath, newFilename, err := p.ComputeDestination(filePath, dateInfo, src)
+	if err != nil {
+		return err
+	}
+	destPath := filepath.Join(p.config.DestDir, dirPath, newFilename)
 
-	// In fix-metadata mode, we only update EXIF, no copying
-	if p.config.FixMetadata {
-		// Check if destination file exists
-		if _, err := os.Stat(destPath); os.IsNotExist(err) {
+	// Check whether the destination file exists: fix-metadata needs it, skip-existing avoids it,
+	// and tag-processed avoids it if it carries the processed tag
+	if p.config.FixMetadata || p.skipExisting() || p.config.TagProcessed {
+		exists, err := p.dest.Exists(destPath)
+		if err != nil {
+			log.Printf("Warning: failed to check if file exists at %s: %v", destPath, err)
+		}
+
+		if p.config.FixMetadata && !exists {
 			if p.config.Verbose {
 				log.Printf("Skipping (dest doesn't exist): %s", destPath)
 			}
-			p.stats.SkippedFiles++
+			p.skip(nil)
+			result.Status, result.Reason = ResultSkipped, "destination missing"
 			return nil
 		}
 
-		// Determine correct timestamp (original EXIF if year matches, otherwise parsed)
-		correctTimestamp, isFromEXIF := DetermineCorrectTimestamp(filePath, dateInfo)
-
-		// Calculate final timestamp using sequential logic
-		if isFromEXIF {
-			// Real EXIF data is sacred - use it as-is
-			// Update lastTimestamp only if EXIF is later than what we've seen
-			if correctTimestamp.After(*lastTimestamp) {
-				*lastTimestamp = correctTimestamp
+		if !p.config.FixMetadata && exists && p.skipExisting() {
+			if p.config.Verbose {
+				log.Printf("Skipping (already exists): %s", destPath)
 			}
-		} else {
-			// No matching EXIF - allocate sequential timestamp in natural filename order
-			if lastTimestamp.IsZero() {
-				// First file without EXIF - start at midnight of the parsed date
-				baseDate := dateInfo.ToTime()
-				correctTimestamp = time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), 0, 0, 0, 0, baseDate.Location())
-			} else {
-				// Subsequent files without EXIF - continue from last timestamp
-				correctTimestamp = lastTimestamp.Add(1 * time.Second)
+			p.skip(&p.stats.SkippedAlreadyExists)
+			result.Status, result.Reason = ResultSkipped, "already exists"
+			return nil
+		}
+
+		if !p.config.FixMetadata && exists && p.config.TagProcessed && p.destTagged(destPath) {
+			if p.config.Verbose {
+				log.Printf("Skipping (already processed): %s", destPath)
 			}
-			*lastTimestamp = correctTimestamp
+			p.skip(&p.stats.SkippedAlreadyExists)
+			result.Status, result.Reason = ResultSkipped, "already processed"
+			return nil
+		}
+	}
+
+	// Fetch the source locally to read EXIF (need this even for dry-run to determine timestamp)
+	localPath, err := src.MetadataPath()
+	if err != nil {
+		return err
+	}
+
+	// Determine correct timestamp (original EXIF if year matches or is preferred, otherwise parsed)
+	// A timestamp that came with the date (override, EXIF or Takeout) is used as-is
+	correctTimestamp, isFromEXIF := exactTimestamp, true
+	if exactTimestamp.IsZero() {
+		correctTimestamp, isFromEXIF = DetermineCorrectTimestamp(localPath, dateInfo)
+		if isFromEXIF {
+			dateSource = "exif"
+		}
+	}
+	timestamp := seq.assign(index, func(lastTimestamp *time.Time) time.Time {
+		return sequentialTimestamp(dateInfo, correctTimestamp, isFromEXIF, lastTimestamp, p.config.DefaultTimeOfDay)
+	})
+
+	source := dateSource
+	switch dateSource {
+	case "exif":
+		source = "EXIF"
+	case "parsed":
+		source = "parsed+sequential"
+	case "range":
+		source = "range folder+sequential, low confidence"
+	case "manual":
+		source = "entered at prompt"
+	case "folder":
+		source = "folder+sequential"
+	}
+	result.TimestampSource = dateSource
+	result.Destination = destPath
+	result.Timestamp = timestamp.Format("2006-01-02 15:04:05")
+
+	// A guess below ExifWriteMinConfidence still places the file, but its metadata,
+	// which may well be more accurate, is left alone
+	writeDate := meetsConfidence(dateConfidence(dateSource), p.config.ExifWriteMinConfidence)
+	if !writeDate {
+		p.count(&p.stats.DatesNotWritten)
+		if p.config.Verbose {
+			log.Printf("Not writing date to metadata (%s confidence): %s", dateConfidence(dateSource), filePath)
 		}
+	}
+	// A PDF's own dates are left alone unless PDFWriteDate asks for them to be set
+	if isPDFFile(filePath) && !p.config.PDFWriteDate {
+		writeDate = false
+	}
 
+	// In fix-metadata mode, we only update EXIF, no copying
+	if p.config.FixMetadata {
+		if !writeDate {
+			p.skip(&p.stats.SkippedFiltered)
+			result.Status, result.Reason = ResultSkipped, "date confidence too low"
+			return nil
+		}
+		result.Status = ResultFixed
 		if p.config.DryRun {
-			source := "EXIF"
-			if !isFromEXIF {
-				source = "parsed+sequential"
+			result.Status = ResultDryRun
+			log.Printf("[DRY RUN] Would fix metadata: %s -> %s (from %s)", destPath, timestamp.Format("2006-01-02 15:04:05"), source)
+			return nil
+		}
+
+		if p.config.Verbose {
+			log.Printf("[Timestamp] %s -> %s (from %s)", filepath.Base(destPath), timestamp.Format("2006-01-02 15:04:05"), source)
+		}
+
+		// Update EXIF/metadata at destination for both images and videos
+		if err := p.fixDestinationMetadata(destPath, timestamp); err != nil {
+			return err
+		}
+
+		p.count(&p.stats.ProcessedFiles)
+		return nil
+	}
+
+	// When merging libraries, an identical file already at the destination is the same photo
+	if p.config.DestExistsAction == DestExistsMerge {
+		target, duplicate, err := p.mergeDestination(localPath, destPath, timestamp)
+		if err != nil {
+			return err
+		}
+		result.Destination = target
+		if duplicate {
+			if p.config.Verbose {
+				log.Printf("Skipping (identical file at destination): %s -> %s", filePath, target)
 			}
-			log.Printf("[DRY RUN] Would fix metadata: %s -> %s (from %s)", destPath, correctTimestamp.Format("2006-01-02 15:04:05"), source)
+			p.skip(&p.stats.SkippedDuplicate)
+			result.Status, result.Reason = ResultSkipped, "merged with identical file"
 			return nil
 		}
+		destPath, newFilename = target, filepath.Base(target)
+	}
+
+	// Normal mode: copy file and update EXIF
+	planned := planFile{Source: filePath, Name: newFilename, Date: dateInfo.String(), Timestamp: result.Timestamp}
+	if p.config.DryRun {
+		p.addToPlan(dirPath, planned)
+		result.Status = ResultDryRun
+		log.Printf("[DRY RUN] Would move: %s -> %s | timestamp: %s (from %s)", filePath, destPath, timestamp.Format("2006-01-02 15:04:05"), source)
+		p.checkPathLength(destPath)
+		p.writeDryRunSample(localPath, filepath.Join(dirPath, newFilename), timestamp)
+		return p.copySidecars(filePath, destPath)
+	}
 
-		// Update EXIF/metadata for both images and videos
-		if checkExiftoolAvailable() {
-			if err := UpdateExifDate(destPath, correctTimestamp); err != nil {
-				log.Printf("Warning: failed to update metadata for %s: %v", destPath, err)
-			} else {
-				p.stats.UpdatedMetadata++
+	if p.config.DirectRemoteStream {
+		if err := p.streamToDestination(filePath, destPath, timestamp, writeDate); err != nil {
+			return err
+		}
+		if p.config.PreservePermissions {
+			if err := p.copyAttrs(filePath, destPath); err != nil {
+				return err
 			}
 		}
+	} else if err := p.writeToDestination(filePath, localPath, destPath, timestamp, writeDate); err != nil {
+		return err
+	}
+	if p.config.WriteMetadataSidecar {
+		if err := p.writeMetadataSidecar(filePath, localPath, destPath, dateInfo, timestamp, dateSource); err != nil {
+			log.Printf("Warning: failed to write metadata sidecar for %s: %v", destPath, err)
+		}
+	}
+	result.Status = ResultProcessed
+	p.addToPlan(dirPath, planned)
+	if p.config.WriteFolderIndex {
+		p.addToFolderIndex(filePath, destPath, dateInfo, timestamp)
+	}
+	if p.config.ContactSheets {
+		p.addToContactSheet(localPath, destPath)
+	}
+	return p.copySidecars(filePath, destPath)
+}
 
-		p.stats.ProcessedFiles++
-		return nil
+// errNoDate reports that no date could be found for a file
+var errNoDate = errors.New("no date found")
+
+// resolveDate dates a source file from, in order: a date override, its EXIF capture time
+// (with PreferExif), its Google Takeout metadata file, its name or (with RangeFolderDate)
+// a year-range folder above it. With DateFromFolderOnly, only an override or its parent
+// folder can date it. exact is the timestamp to use as-is when the date came with one,
+// and source says where the date came from ("override", "folder", "exif", "takeout",
+// "parsed" or "range"). dateInfo is nil (with errNoDate) when nothing dated the file.
+func (p *PhotoProcessor) resolveDate(filePath string, src *fetchedFile) (dateInfo *DateInfo, exact time.Time, source string, err error) {
+	// A manually supplied date overrides both the filename and EXIF
+	// An override is used as-is, with DefaultTimeOfDay when it has no time
+	if p.dateOverrides != nil {
+		if override := p.dateOverrides.lookup(filePath, p.config.SourceDir); override != nil {
+			return override.withSource(DateSourceOverride), override.ToTimeWithDefault(p.config.DefaultTimeOfDay), "override", nil
+		}
+	}
+
+	// With DateFromFolderOnly, the parent folder alone dates its files
+	if p.config.DateFromFolderOnly {
+		dateInfo, err := ParseDateFromFilenameWithOptions(filepath.Dir(filePath), p.parseOptions())
+		if err != nil {
+			return nil, time.Time{}, "", errNoDate
+		}
+		return dateInfo.withOriginal(filepath.Base(filePath)).withSource(DateSourceFolder), time.Time{}, "folder", nil
+	}
+
+	// With PreferExif, a plausible EXIF capture time decides the date outright
+	if p.config.PreferExif {
+		localPath, err := src.MetadataPath()
+		if err != nil {
+			return nil, time.Time{}, "", err
+		}
+		if t, ok := ReadCaptureTime(localPath); ok && validYear(t.Year()) {
+			return DateInfoFromTime(t, filepath.Base(filePath)).withSource(DateSourceExif), t, "exif", nil
+		}
+	}
+
+	// A Takeout export's photoTakenTime beats guessing from the name
+	t, ok, err := p.takeoutTakenTime(filePath)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if ok {
+		return DateInfoFromTime(t, filepath.Base(filePath)).withSource(DateSourceTakeout), t, "takeout", nil
+	}
+
+	// Parse date from filename
+	dateInfo, err = ParseDateFromFilenameWithOptions(filePath, p.parseOptions())
+	if err == nil {
+		// The name is tried before its folders, so the folder only wins a conflict when preferred
+		if _, folderDate := p.dateConflict(filePath); folderDate != nil && p.config.DateConflictPrefer == DateConflictPreferFolder {
+			return folderDate, time.Time{}, "parsed", nil
+		}
+		return dateInfo.withSource(parsedDateSource(filePath, dateInfo, p.parseOptions())), time.Time{}, "parsed", nil
+	}
+
+	// As a last resort, a year-range folder gives a rough, low-confidence date
+	if p.config.RangeFolderDate != "" {
+		if dateInfo := rangeFolderDate(filePath, p.config.SourceDir, p.config.RangeFolderDate); dateInfo != nil {
+			return dateInfo.withSource(DateSourceRange), time.Time{}, "range", nil
+		}
+	}
+	return nil, time.Time{}, "", errNoDate
+}
+
+// parsedDateSource says whether a date parsed from filePath came from the file's own
+// name or, when the name alone doesn't give that date, from a folder above it
+func parsedDateSource(filePath string, dateInfo *DateInfo, opts ParseOptions) DateSource {
+	own, err := ParseDateFromFilenameWithOptions(filepath.Base(filePath), opts)
+	if err == nil && own.Year == dateInfo.Year && own.Month == dateInfo.Month && own.Day == dateInfo.Day {
+		return DateSourceFilename
+	}
+	return DateSourceFolder
+}
+
+// ComputeDestination returns the destination folder (relative to DestDir) and the
+// standardized file name for a source file dated by dateInfo
+func (p *PhotoProcessor) ComputeDestination(filePath string, dateInfo *DateInfo, src *fetchedFile) (dirPath, newFilename string, err error) {
+	// Extract description from filename
+	desc, ext := splitFilename(filePath)
+	if p.bursts[filePath] != nil {
+		desc = padBurstSequence(desc)
+	}
+	if fixedExt, ok := p.contentExts[filePath]; ok {
+		ext = fixedExt
+	}
+	if p.config.ConvertHEICtoJPG && isHEICExt(ext) && findHEICDecoder() != nil {
+		ext = ".jpg"
+	}
+
+	// Extract directory context and prepend to description
+	nameOpts := p.nameOptions()
+	dirContext := ExtractDirectoryContext(filePath, p.config.SourceDir, nameOpts.Separator)
+	if dirContext != "" {
+		desc = dirContext + nameOpts.Separator + desc
+	}
+
+	// Generate standardized filename
+	newFilename = dateInfo.StandardizedFilename(desc, ext, nameOpts)
+	if p.config.SeparateScreenshots && isScreenshot(filePath, p.config.SourceDir) {
+		// Screenshots have no place to name, so the geocoder is skipped too
+		return p.screenshotDir(dateInfo), newFilename, nil
 	}
+	dirPath = dateInfo.DirectoryPath(p.config.DestLayout)
 
-	// Normal mode: copy file and update EXIF
-	// Check if destination already exists (for resume capability)
-	if p.config.SkipExisting {
-		if _, err := os.Stat(destPath); err == nil {
-			if p.config.Verbose {
-				log.Printf("Skipping (already exists): %s", destPath)
-			}
-			p.stats.SkippedFiles++
-			return nil
+	// Appending a place name needs the GPS coordinates, so fetch the source up front
+	if p.geocoder != nil {
+		localPath, err := src.MetadataPath()
+		if err != nil {
+			return "", "", err
 		}
+		if place := p.placeName(localPath); place != "" && dirPath == "" {
+			dirPath = place
+		} else if place != "" {
+			dirPath += nameOpts.Separator + place
+		}
+	}
+	return dirPath, newFilename, nil
+}
+
+// alreadyStandardized reports whether a file already has the standardized name for its
+// date and sits in the folder that date maps to, as it does when the source is a tree
+// this tool organized; processing it again would only rewrite it
+func (p *PhotoProcessor) alreadyStandardized(filePath string, dateInfo *DateInfo) bool {
+	// A file whose extension would change isn't in its final form
+	ext := fileExt(filePath)
+	if _, ok := p.contentExts[filePath]; ok || (p.config.ConvertHEICtoJPG && isHEICExt(ext)) {
+		return false
 	}
 
-	// Determine correct timestamp for EXIF
-	// Check if source has real EXIF that matches the parsed year
-	correctTimestamp, isFromEXIF := DetermineCorrectTimestamp(filePath, dateInfo)
+	sourceRoot := strings.TrimRight(p.config.SourceDir, "/")
+	relDir := filepath.Dir(strings.TrimPrefix(strings.TrimPrefix(filePath, sourceRoot), "/"))
+	wantDir := dateInfo.DirectoryPath(p.config.DestLayout)
+	if p.config.SeparateScreenshots && isScreenshot(filePath, p.config.SourceDir) {
+		wantDir = p.screenshotDir(dateInfo)
+	}
+	if wantDir == "" {
+		wantDir = "."
+	}
+	nameOpts := p.nameOptions()
+	// Geocoding appends a place name to the date folder
+	if relDir != wantDir && !(p.geocoder != nil && strings.HasPrefix(relDir, wantDir+nameOpts.Separator)) {
+		return false
+	}
+	return dateInfo.HasStandardizedName(filepath.Base(filePath), nameOpts)
+}
 
-	// Calculate final timestamp using sequential logic
-	var timestamp time.Time
+// sequentialTimestamp calculates the final timestamp for a file
+// Real EXIF timestamps are used as-is; files without matching EXIF are allocated
+// sequential timestamps so they keep their natural filename order, starting at
+// dayStart (an offset from midnight) on the parsed date
+func sequentialTimestamp(dateInfo *DateInfo, correctTimestamp time.Time, isFromEXIF bool, lastTimestamp *time.Time, dayStart time.Duration) time.Time {
 	if isFromEXIF {
 		// Real EXIF data is sacred - always use it as-is
-		timestamp = correctTimestamp
 		// Update lastTimestamp only if EXIF is later than what we've seen
 		if correctTimestamp.After(*lastTimestamp) {
 			*lastTimestamp = correctTimestamp
 		}
-	} else {
-		// No matching EXIF - allocate sequential timestamp in natural filename order
-		// Start at midnight (00:00:00) so real EXIF timestamps (usually daytime) sort after
-		if lastTimestamp.IsZero() {
-			// First file without EXIF - start at midnight of the parsed date
-			baseDate := dateInfo.ToTime()
-			timestamp = time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), 0, 0, 0, 0, baseDate.Location())
-		} else {
-			// Subsequent files without EXIF - continue from last timestamp
-			timestamp = lastTimestamp.Add(1 * time.Second)
-		}
-		*lastTimestamp = timestamp
-	}
-
-	if p.config.DryRun {
-		source := "EXIF"
-		if !isFromEXIF {
-			source = "parsed+sequential"
-		}
-		log.Printf("[DRY RUN] Would move: %s -> %s | timestamp: %s (from %s)", filePath, destPath, timestamp.Format("2006-01-02 15:04:05"), source)
-		return nil
+		return correctTimestamp
 	}
 
-	// Create destination directory
-	destDir := filepath.Dir(destPath)
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
+	// No matching EXIF - allocate sequential timestamp in natural filename order
+	// By default start at midnight (00:00:00) so real EXIF timestamps (usually daytime) sort after
+	var timestamp time.Time
+	if lastTimestamp.IsZero() {
+		// First file without EXIF - start at dayStart on the parsed date
+		baseDate := dateInfo.ToTime()
+		timestamp = time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), 0, 0, 0, 0, baseDate.Location()).Add(dayStart)
+	} else {
+		// Subsequent files without EXIF - continue from last timestamp
+		timestamp = lastTimestamp.Add(1 * time.Second)
 	}
+	*lastTimestamp = timestamp
+	return timestamp
+}
 
-	// Copy file
-	if err := copyFile(filePath, destPath); err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+// updateMetadata writes the timestamp into a file's metadata,
+// re-reading it afterwards when write verification is enabled
+func (p *PhotoProcessor) updateMetadata(localPath string, timestamp time.Time) error {
+	if err := UpdateExifDate(localPath, timestamp); err != nil {
+		return err
 	}
-	p.stats.MovedFiles++
 
-	// Update EXIF/metadata for both images and videos
-	if checkExiftoolAvailable() {
-		if err := UpdateExifDate(destPath, timestamp); err != nil {
-			log.Printf("Warning: failed to update metadata for %s: %v", destPath, err)
-		} else {
-			p.stats.UpdatedMetadata++
+	if p.config.VerifyExifWrite {
+		if err := VerifyExifDate(localPath, timestamp); err != nil {
+			p.count(&p.stats.ExifVerifyFailures)
+			return fmt.Errorf("verification failed: %w", err)
 		}
 	}
-
-	p.stats.ProcessedFiles++
 	return nil
 }
 
-// processRemotePhoto processes a photo from remote SSH location
-func (p *PhotoProcessor) processRemotePhoto(remotePath string, lastTimestamp *time.Time) error {
-	if p.config.Verbose {
-		log.Printf("Processing remote: %s", remotePath)
+// copyToUnknown writes an undated file to the destination's unknown/ folder,
+// appending a counter when a file with the same name already exists, and returns its path
+func (p *PhotoProcessor) copyToUnknown(localPath, base string) (string, error) {
+	unknownDir := filepath.Join(p.config.DestDir, "unknown")
+	if err := p.dest.MkdirAll(unknownDir); err != nil {
+		return "", fmt.Errorf("failed to create unknown directory: %w", err)
 	}
 
-	// Parse date from filename
-	dateInfo, err := ParseDateFromFilename(remotePath)
+	finalPath, err := p.reserveUnknownName(unknownDir, base)
 	if err != nil {
-		log.Printf("Skipping (no date found): %s -> unknown/", remotePath)
+		return "", err
+	}
 
-		// Copy to "unknown" folder instead of skipping
-		if !p.config.DryRun {
-			base := remotePath[strings.LastIndex(remotePath, "/")+1:]
-			unknownPath := filepath.Join(p.config.DestDir, "unknown", base)
+	if err := p.writeVerified(localPath, finalPath); err != nil {
+		log.Printf("ERROR: Failed to copy to unknown: %s - %v", finalPath, err)
+		return "", fmt.Errorf("failed to copy to unknown: %w", err)
+	}
+	return finalPath, nil
+}
 
-			// Download to temporary file
-			tempFile, err := os.CreateTemp("", "photo-*"+filepath.Ext(remotePath))
+// reserveUnknownName picks a free name for base in unknownDir, appending a counter when a file
+// with the same name already exists. Names are reserved for the rest of the run so concurrent
+// workers never pick the same target.
+func (p *PhotoProcessor) reserveUnknownName(unknownDir, base string) (string, error) {
+	p.reserveMutex.Lock()
+	defer p.reserveMutex.Unlock()
+
+	ext := filepath.Ext(base)
+	nameWithoutExt := strings.TrimSuffix(base, ext)
+	finalPath := filepath.Join(unknownDir, base)
+	for counter := 1; ; counter++ {
+		if !p.reservedNames[finalPath] {
+			exists, err := p.dest.Exists(finalPath)
 			if err != nil {
-				return fmt.Errorf("failed to create temp file: %w", err)
+				return "", fmt.Errorf("failed to check if file exists: %w", err)
 			}
-			tempPath := tempFile.Name()
-			tempFile.Close()
-			defer os.Remove(tempPath)
-
-			if err := p.sshClient.DownloadFile(remotePath, tempPath); err != nil {
-				log.Printf("ERROR: Failed to download file: %s - %v", remotePath, err)
-				return fmt.Errorf("failed to download file: %w", err)
-			}
-
-			// Handle duplicate filenames by appending a counter
-			finalPath := unknownPath
-			counter := 1
-			ext := filepath.Ext(base)
-			nameWithoutExt := strings.TrimSuffix(base, ext)
-
-			// Upload or copy to unknown folder
-			if p.config.RemoteDest {
-				if err := p.destSSHClient.CreateDirectory(filepath.Join(p.config.DestDir, "unknown")); err != nil {
-					return fmt.Errorf("failed to create unknown directory: %w", err)
-				}
-
-				// Check for duplicates and find available filename
-				for {
-					exists, err := p.destSSHClient.FileExists(finalPath)
-					if err != nil {
-						return fmt.Errorf("failed to check if file exists: %w", err)
-					}
-					if !exists {
-						break
-					}
-					finalPath = filepath.Join(p.config.DestDir, "unknown", fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
-					counter++
-				}
-
-				if err := p.destSSHClient.UploadFile(tempPath, finalPath); err != nil {
-					log.Printf("ERROR: Failed to upload to unknown: %s - %v", finalPath, err)
-					return fmt.Errorf("failed to upload to unknown: %w", err)
-				}
-			} else {
-				if err := os.MkdirAll(filepath.Join(p.config.DestDir, "unknown"), 0755); err != nil {
-					return fmt.Errorf("failed to create unknown directory: %w", err)
-				}
-
-				// Check for duplicates and find available filename
-				for {
-					if _, err := os.Stat(finalPath); os.IsNotExist(err) {
-						break
-					}
-					finalPath = filepath.Join(p.config.DestDir, "unknown", fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
-					counter++
-				}
-
-				if err := copyFile(tempPath, finalPath); err != nil {
-					log.Printf("ERROR: Failed to copy to unknown: %s - %v", finalPath, err)
-					return fmt.Errorf("failed to copy to unknown: %w", err)
-				}
+			if !exists {
+				break
 			}
 		}
-		p.stats.SkippedFiles++
-		return nil
+		finalPath = filepath.Join(unknownDir, fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
 	}
 
-	// Extract description from filename
-	base := remotePath[strings.LastIndex(remotePath, "/")+1:]
-	ext := remotePath[strings.LastIndex(remotePath, "."):]
-	desc := strings.TrimSuffix(base, ext)
+	p.reservedNames[finalPath] = true
+	return finalPath, nil
+}
 
-	// Extract directory context and prepend to description
-	dirContext := ExtractDirectoryContext(remotePath, p.config.SourceDir)
-	if dirContext != "" {
-		desc = dirContext + "_" + desc
+// writeToDestination copies a local file to a temp file, updates its EXIF date
+// (unless writeDate is false) and writes the result to the destination. A local
+// destination prepares the file next to destPath, so it is copied only once.
+func (p *PhotoProcessor) writeToDestination(sourcePath, localPath, destPath string, timestamp time.Time, writeDate bool) error {
+	// The folder is made up front, since a local destination prepares the file in it
+	destDir := filepath.Dir(destPath)
+	if err := p.dest.MkdirAll(destDir); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
 	}
 
-	// Generate standardized filename
-	newFilename := dateInfo.StandardizedFilename(desc, ext)
+	// Work on a temp copy so the source file is never modified
+	// Name it after the destination so exiftool sees the corrected extension
+	tempPath, err := p.tempCopyPath(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tempPath)
 
-	var destPath string
-	if p.config.RemoteDest {
-		destPath = filepath.Join(p.config.DestDir, dateInfo.GetDirectoryPath(), newFilename)
-	} else {
-		destPath = filepath.Join(p.config.DestDir, dateInfo.GetDirectoryPath(), newFilename)
+	if err := copyOrConvert(localPath, tempPath); err != nil {
+		return fmt.Errorf("failed to copy temp file: %w", err)
 	}
 
-	// In fix-metadata mode, we only update EXIF, no copying
-	if p.config.FixMetadata {
-		// Check if destination file exists
-		var exists bool
+	// Update EXIF/metadata for both images and videos; in batch mode files needing
+	// exiftool are dated, and tagged, together with the files other workers are writing
+	metadataUpdated := false
+	batched := writeDate && p.batchesExiftool(destPath)
+	if writeDate && canUpdateMetadata(tempPath) {
 		var err error
-
-		if p.config.RemoteDest {
-			exists, err = p.destSSHClient.FileExists(destPath)
-			if err != nil {
-				log.Printf("Warning: failed to check if file exists at %s: %v", destPath, err)
-			}
+		if batched {
+			err = p.dateInBatch(tempPath, destPath, timestamp)
 		} else {
-			_, err := os.Stat(destPath)
-			exists = err == nil
+			err = p.updateMetadata(tempPath, timestamp)
 		}
-
-		if !exists {
-			if p.config.Verbose {
-				log.Printf("Skipping (dest doesn't exist): %s", destPath)
+		if errors.Is(err, errExiftoolTimeout) {
+			// A killed exiftool may have left the copy half-written
+			return fmt.Errorf("failed to update metadata: %w", err)
+		} else if err != nil {
+			log.Printf("Warning: failed to update metadata for %s: %v", destPath, err)
+		} else {
+			p.count(&p.stats.UpdatedMetadata)
+			metadataUpdated = true
+		}
+	}
+	if canUpdateMetadata(tempPath) && !batched {
+		if p.config.TagProcessed && checkExiftoolAvailable() {
+			if err := writeProcessedTag(tempPath, processedTagValue()); err != nil {
+				log.Printf("Warning: failed to tag %s as processed: %v", destPath, err)
 			}
-			p.stats.SkippedFiles++
-			return nil
 		}
+	}
 
-		// Download source file temporarily to read EXIF (need this even for dry-run to determine timestamp)
-		sourceTempFile, err := os.CreateTemp("", "photo-source-*"+ext)
-		if err != nil {
-			return fmt.Errorf("failed to create temp file for source: %w", err)
-		}
-		sourceTempPath := sourceTempFile.Name()
-		sourceTempFile.Close()
-		defer os.Remove(sourceTempPath)
+	return p.placeFile(sourcePath, tempPath, destPath, timestamp, writeDate && !metadataUpdated)
+}
+
+// tempCopyPath returns an empty temp file to prepare the file for destPath in: a partial
+// file next to it on a local destination, otherwise one in the system temp folder
+func (p *PhotoProcessor) tempCopyPath(destPath string) (string, error) {
+	if stager, ok := p.dest.(inPlaceStager); ok {
+		return stager.Stage(destPath)
+	}
+	tempFile, err := os.CreateTemp("", "photo-*"+filepath.Ext(destPath))
+	if err != nil {
+		return "", err
+	}
+	tempFile.Close()
+	return tempFile.Name(), nil
+}
 
-		if err := p.sshClient.DownloadFile(remotePath, sourceTempPath); err != nil {
-			return fmt.Errorf("failed to download source file: %w", err)
+// placeFile writes a prepared temp copy to destPath, whose folder already exists, and
+// gives it the photo's modification time and, with PreservePermissions, the source's
+// attributes. needsMetadata lists it among the files whose date still has to be fixed.
+func (p *PhotoProcessor) placeFile(sourcePath, tempPath, destPath string, timestamp time.Time, needsMetadata bool) error {
+	if stager, ok := p.dest.(inPlaceStager); ok {
+		if err := stager.Commit(tempPath, destPath); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	} else if err := p.writeVerified(tempPath, destPath); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := p.setFileModifyDate(destPath, timestamp); err != nil {
+		return err
+	}
+	if p.config.PreservePermissions {
+		if err := p.copyAttrs(sourcePath, destPath); err != nil {
+			return err
 		}
+	}
+	if needsMetadata {
+		p.needsMetadata(destPath)
+	}
+	p.count(&p.stats.MovedFiles)
 
-		// Determine correct timestamp (original EXIF if year matches, otherwise parsed)
-		correctTimestamp, isFromEXIF := DetermineCorrectTimestamp(sourceTempPath, dateInfo)
+	p.count(&p.stats.ProcessedFiles)
+	return nil
+}
 
-		// Calculate final timestamp using sequential logic
-		if isFromEXIF {
-			// Real EXIF data is sacred - use it as-is
-			// Update lastTimestamp only if EXIF is later than what we've seen
-			if correctTimestamp.After(*lastTimestamp) {
-				*lastTimestamp = correctTimestamp
-			}
-		} else {
-			// No matching EXIF - allocate sequential timestamp in natural filename order
-			if lastTimestamp.IsZero() {
-				// First file without EXIF - start at midnight of the parsed date
-				baseDate := dateInfo.ToTime()
-				correctTimestamp = time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), 0, 0, 0, 0, baseDate.Location())
-			} else {
-				// Subsequent files without EXIF - continue from last timestamp
-				correctTimestamp = lastTimestamp.Add(1 * time.Second)
-			}
-			*lastTimestamp = correctTimestamp
+// fixDestinationMetadata updates the EXIF date of a file already at the destination
+func (p *PhotoProcessor) fixDestinationMetadata(destPath string, timestamp time.Time) error {
+	if canUpdateMetadata(destPath) {
+		var exifErr error
+		err := p.dest.Update(destPath, func(localPath string) error {
+			exifErr = p.updateMetadata(localPath, timestamp)
+			return exifErr
+		})
+		if errors.Is(exifErr, errExiftoolTimeout) {
+			return fmt.Errorf("failed to update metadata: %w", exifErr)
 		}
-
-		if p.config.DryRun {
-			source := "EXIF"
-			if !isFromEXIF {
-				source = "parsed+sequential"
-			}
-			log.Printf("[DRY RUN] Would fix metadata: %s -> %s (from %s)", filepath.Base(destPath), correctTimestamp.Format("2006-01-02 15:04:05"), source)
+		if exifErr != nil {
+			log.Printf("Warning: failed to update metadata for %s: %v", destPath, exifErr)
+			p.needsMetadata(destPath)
 			return nil
 		}
-
-		if p.config.Verbose {
-			source := "EXIF"
-			if !isFromEXIF {
-				source = "parsed+sequential"
-			}
-			log.Printf("[Timestamp] %s -> %s (from %s)", filepath.Base(destPath), correctTimestamp.Format("2006-01-02 15:04:05"), source)
+		if err != nil {
+			return err
 		}
 
-		// Update EXIF/metadata at destination for both images and videos
-		if p.config.RemoteDest {
-			// Download dest file, update metadata, re-upload
-			destTempFile, err := os.CreateTemp("", "photo-dest-*"+ext)
-			if err != nil {
-				return fmt.Errorf("failed to create temp file for dest: %w", err)
-			}
-			destTempPath := destTempFile.Name()
-			destTempFile.Close()
-			defer os.Remove(destTempPath)
+		p.count(&p.stats.UpdatedMetadata)
+	} else {
+		p.needsMetadata(destPath)
+	}
 
-			if err := p.destSSHClient.DownloadFile(destPath, destTempPath); err != nil {
-				return fmt.Errorf("failed to download dest file: %w", err)
-			}
+	return p.setFileModifyDate(destPath, timestamp)
+}
 
-			if checkExiftoolAvailable() {
-				if err := UpdateExifDate(destTempPath, correctTimestamp); err != nil {
-					log.Printf("Warning: failed to update metadata for %s: %v", destTempPath, err)
-				} else {
-					// Re-upload to destination
-					if err := p.destSSHClient.UploadFile(destTempPath, destPath); err != nil {
-						return fmt.Errorf("failed to upload updated file: %w", err)
-					}
-					p.stats.UpdatedMetadata++
-				}
-			}
-		} else {
-			// Local destination, update directly
-			if checkExiftoolAvailable() {
-				if err := UpdateExifDate(destPath, correctTimestamp); err != nil {
-					log.Printf("Warning: failed to update metadata for %s: %v", destPath, err)
-				} else {
-					p.stats.UpdatedMetadata++
-				}
-			}
-		}
+// copyAttrs gives the destination file the source file's mode and, where permitted, owner
+func (p *PhotoProcessor) copyAttrs(sourcePath, destPath string) error {
+	attrs, err := p.source.Attrs(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read permissions of %s: %w", sourcePath, err)
+	}
+	if err := p.dest.SetAttrs(destPath, attrs); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", destPath, err)
+	}
+	return nil
+}
 
-		p.stats.ProcessedFiles++
+// setFileModifyDate sets the destination file's modification time to the photo date if enabled
+// This runs after any metadata update, since rewriting the file resets its mtime
+func (p *PhotoProcessor) setFileModifyDate(destPath string, timestamp time.Time) error {
+	if !p.config.SetFileModifyDate {
 		return nil
 	}
+	if err := p.dest.SetModTime(destPath, timestamp); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", destPath, err)
+	}
+	return nil
+}
 
-	// Normal mode: copy file and update EXIF
-	// Check if destination already exists (for resume capability)
-	if p.config.SkipExisting {
-		var exists bool
-		var err error
-
-		if p.config.RemoteDest {
-			exists, err = p.destSSHClient.FileExists(destPath)
-			if err != nil {
-				log.Printf("Warning: failed to check if file exists at %s: %v", destPath, err)
-			}
-		} else {
-			_, err := os.Stat(destPath)
-			exists = err == nil
-		}
+// processedTagValue identifies this tool in the processed tag
+func processedTagValue() string {
+	return processedTagPrefix + version
+}
 
-		if exists {
-			if p.config.Verbose {
-				log.Printf("Skipping (already exists): %s", destPath)
-			}
-			p.stats.SkippedFiles++
-			return nil
-		}
-	}
+// processedTagPrefix starts every processed tag value written by this tool
+const processedTagPrefix = "picture-metadata:"
 
-	// Download source file temporarily to read EXIF (need this even for dry-run to determine timestamp)
-	sourceTempFile, err := os.CreateTemp("", "photo-source-*"+ext)
+// destTagged reports whether the file at destPath carries the processed tag
+func (p *PhotoProcessor) destTagged(destPath string) bool {
+	localPath, cleanup, err := p.dest.Fetch(destPath)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file for source: %w", err)
+		log.Printf("Warning: failed to read %s for its processed tag: %v", destPath, err)
+		return false
 	}
-	sourceTempPath := sourceTempFile.Name()
-	sourceTempFile.Close()
-	defer os.Remove(sourceTempPath)
+	defer cleanup()
+	return strings.HasPrefix(readProcessedTag(localPath), processedTagPrefix)
+}
 
-	if err := p.sshClient.DownloadFile(remotePath, sourceTempPath); err != nil {
-		return fmt.Errorf("failed to download source file: %w", err)
+// placeName returns the cleaned name of the place a photo was taken, or "" if unknown
+func (p *PhotoProcessor) placeName(localPath string) string {
+	metadata, err := ReadExifData(localPath)
+	if err != nil || !metadata.HasGPS {
+		return ""
 	}
+	return cleanPlaceName(p.geocoder.Lookup(metadata.Latitude, metadata.Longitude), p.nameOptions().Separator)
+}
 
-	// Determine correct timestamp for EXIF
-	// Check if source has real EXIF that matches the parsed year
-	correctTimestamp, isFromEXIF := DetermineCorrectTimestamp(sourceTempPath, dateInfo)
-
-	// Calculate final timestamp using sequential logic
-	var timestamp time.Time
-	if isFromEXIF {
-		// Real EXIF data is sacred - always use it as-is
-		timestamp = correctTimestamp
-		// Update lastTimestamp only if EXIF is later than what we've seen
-		if correctTimestamp.After(*lastTimestamp) {
-			*lastTimestamp = correctTimestamp
-		}
-	} else {
-		// No matching EXIF - allocate sequential timestamp in natural filename order
-		// Start at midnight (00:00:00) so real EXIF timestamps (usually daytime) sort after
-		if lastTimestamp.IsZero() {
-			// First file without EXIF - start at midnight of the parsed date
-			baseDate := dateInfo.ToTime()
-			timestamp = time.Date(baseDate.Year(), baseDate.Month(), baseDate.Day(), 0, 0, 0, 0, baseDate.Location())
-		} else {
-			// Subsequent files without EXIF - continue from last timestamp
-			timestamp = lastTimestamp.Add(1 * time.Second)
+// checkPathLength warns about a planned destination path longer than MaxPathLength,
+// which the real run would fail to write. Length is counted in characters, as Windows
+// and SMB shares limit it.
+func (p *PhotoProcessor) checkPathLength(destPath string) {
+	if p.config.MaxPathLength <= 0 {
+		return
+	}
+	// A relative local destination is written under the working directory, which counts too
+	if !p.config.RemoteDest && p.config.S3Bucket == "" {
+		if abs, err := filepath.Abs(destPath); err == nil {
+			destPath = abs
 		}
-		*lastTimestamp = timestamp
 	}
+	if n := utf8.RuneCountInString(destPath); n > p.config.MaxPathLength {
+		log.Printf("Warning: destination path is %d characters, over the %d limit: %s", n, p.config.MaxPathLength, destPath)
+		p.count(&p.stats.LongPaths)
+	}
+}
 
-	if p.config.DryRun {
-		source := "EXIF"
-		if !isFromEXIF {
-			source = "parsed+sequential"
-		}
-		if p.config.RemoteDest {
-			log.Printf("[DRY RUN] Would process remote to remote: %s -> %s | timestamp: %s (from %s)", remotePath, destPath, timestamp.Format("2006-01-02 15:04:05"), source)
-		} else {
-			log.Printf("[DRY RUN] Would download and move: %s -> %s | timestamp: %s (from %s)", remotePath, destPath, timestamp.Format("2006-01-02 15:04:05"), source)
-		}
-		// Clean up source temp file
-		os.Remove(sourceTempPath)
-		return nil
+// writeDryRunSample copies a would-be output file into the dry-run sample directory
+// and applies the timestamp, so the result can be inspected without touching the destination
+func (p *PhotoProcessor) writeDryRunSample(localPath, relPath string, timestamp time.Time) {
+	p.sampleMutex.Lock()
+	if p.sampleDir == "" || p.samplesWritten >= p.config.DryRunSamples {
+		p.sampleMutex.Unlock()
+		return
 	}
+	p.samplesWritten++
+	p.sampleMutex.Unlock()
 
-	// For non-dry-run, we already have the source downloaded, but we need it in a different temp file for processing
-	// Move the source temp file to the processing temp file
-	tempFile, err := os.CreateTemp("", "photo-*"+ext)
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+	samplePath := filepath.Join(p.sampleDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(samplePath), 0755); err != nil {
+		log.Printf("Warning: failed to create sample directory for %s: %v", samplePath, err)
+		return
 	}
-	tempPath := tempFile.Name()
-	tempFile.Close()
-	defer os.Remove(tempPath)
 
-	// Copy from source temp to processing temp
-	if err := copyFile(sourceTempPath, tempPath); err != nil {
-		return fmt.Errorf("failed to copy temp file: %w", err)
+	if err := copyOrConvert(localPath, samplePath); err != nil {
+		log.Printf("Warning: failed to write dry-run sample %s: %v", samplePath, err)
+		return
 	}
 
-	// Update EXIF/metadata for both images and videos
-	if checkExiftoolAvailable() {
-		if err := UpdateExifDate(tempPath, timestamp); err != nil {
-			log.Printf("Warning: failed to update metadata for %s: %v", tempPath, err)
-		} else {
-			p.stats.UpdatedMetadata++
+	if canUpdateMetadata(samplePath) {
+		if err := p.updateMetadata(samplePath, timestamp); err != nil {
+			log.Printf("Warning: failed to update metadata for sample %s: %v", samplePath, err)
+			return
 		}
 	}
 
-	// Upload to destination (remote or local)
-	if p.config.RemoteDest {
-		// Create destination directory on remote
-		destDir := filepath.Dir(destPath)
-		if err := p.destSSHClient.CreateDirectory(destDir); err != nil {
-			return fmt.Errorf("failed to create remote directory %s: %w", destDir, err)
-		}
+	log.Printf("[DRY RUN] Wrote sample: %s", samplePath)
+}
 
-		// Upload file to remote destination
-		if err := p.destSSHClient.UploadFile(tempPath, destPath); err != nil {
-			return fmt.Errorf("failed to upload file: %w", err)
-		}
-	} else {
-		// Copy to local destination
-		destDir := filepath.Dir(destPath)
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", destDir, err)
-		}
+// splitFilename splits the base name of a path into its description and extension.
+// Only the final extension is split off, and files without one get an empty extension.
+// A repeated extension ("IMG_1.jpg.jpg") is only kept once.
+func splitFilename(path string) (string, string) {
+	base := filepath.Base(path)
+	ext := fileExt(base)
+	desc := strings.TrimSuffix(base, ext)
+	if ext != "" && strings.EqualFold(fileExt(desc), ext) {
+		desc = strings.TrimSuffix(desc, fileExt(desc))
+	}
+	return desc, ext
+}
 
-		if err := copyFile(tempPath, destPath); err != nil {
-			return fmt.Errorf("failed to copy file: %w", err)
+// fileExt is filepath.Ext, except that a suffix which can't be a file extension (digits
+// only, e.g. the ".01" of an extensionless "2018.01.01", or containing spaces or
+// punctuation) is treated as part of the name, so the file has no extension
+func fileExt(path string) string {
+	ext := filepath.Ext(path)
+	if len(ext) < 2 || len(ext) > 6 {
+		return ""
+	}
+	hasLetter := false
+	for _, r := range ext[1:] {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+		default:
+			return ""
 		}
 	}
+	if !hasLetter {
+		return ""
+	}
+	return ext
+}
 
-	p.stats.MovedFiles++
+// imageExtensions are the file extensions processed as images
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".heic", ".heif"}
 
-	p.stats.ProcessedFiles++
-	return nil
+// videoExtensions are the file extensions processed as videos
+var videoExtensions = []string{".mp4", ".mov", ".avi", ".mkv", ".m4v", ".3gp", ".wmv", ".flv", ".webm", ".mpg", ".mpeg", ".mts", ".m2ts"}
+
+// rawExtensions are camera RAW formats, processed as images with Config.IncludeRaw
+var rawExtensions = []string{".nef", ".nrw", ".cr2", ".cr3", ".arw", ".dng", ".orf", ".rw2", ".raf", ".pef", ".srw"}
+
+// isRawFile checks if a file is a camera RAW file based on extension
+func isRawFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, rawExt := range rawExtensions {
+		if ext == rawExt {
+			return true
+		}
+	}
+	return false
 }
 
 // isMediaFile checks if a file is a photo or video based on extension
 func isMediaFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
-	// Image extensions
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tiff", ".tif", ".heic", ".heif"}
-	// Video extensions
-	videoExts := []string{".mp4", ".mov", ".avi", ".mkv", ".m4v", ".3gp", ".wmv", ".flv", ".webm", ".mpg", ".mpeg", ".mts", ".m2ts"}
-
-	allExts := append(imageExts, videoExts...)
-	for _, mediaExt := range allExts {
+	for _, mediaExt := range append(imageExtensions, videoExtensions...) {
 		if ext == mediaExt {
 			return true
 		}
@@ -824,8 +1711,7 @@ func isMediaFile(filename string) bool {
 // isVideoFile checks if a file is a video based on extension
 func isVideoFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
-	videoExts := []string{".mp4", ".mov", ".avi", ".mkv", ".m4v", ".3gp", ".wmv", ".flv", ".webm", ".mpg", ".mpeg", ".mts", ".m2ts"}
-	for _, vidExt := range videoExts {
+	for _, vidExt := range videoExtensions {
 		if ext == vidExt {
 			return true
 		}
@@ -856,23 +1742,50 @@ func copyFile(src, dst string) error {
 	return destFile.Sync()
 }
 
+// Default progress cadence: a progress line every this many files or this often, whichever comes first
+const (
+	DefaultProgressEveryN   = 100
+	DefaultProgressInterval = 10 * time.Second
+)
+
+// progressDue reports whether a progress line is due after processed files, with
+// sinceLast elapsed since the previous one (a negative setting disables its trigger)
+func (p *PhotoProcessor) progressDue(processed int, sinceLast time.Duration) bool {
+	everyN, interval := p.config.ProgressEveryN, p.config.ProgressInterval
+	if everyN == 0 {
+		everyN = DefaultProgressEveryN
+	}
+	if interval == 0 {
+		interval = DefaultProgressInterval
+	}
+	return (everyN > 0 && processed%everyN == 0) || (interval > 0 && sinceLast >= interval)
+}
+
 // printProgress prints progress updates periodically
 func (p *PhotoProcessor) printProgress(force bool) {
 	p.statsMutex.Lock()
-	defer p.statsMutex.Unlock()
-
 	now := time.Now()
 	timeSinceLastProgress := now.Sub(p.lastProgress)
 	processed := p.stats.ProcessedFiles + p.stats.SkippedFiles + p.stats.ErrorFiles
 
-	// Print every 100 files or every 10 seconds, whichever comes first
-	if !force && processed%100 != 0 && timeSinceLastProgress < 10*time.Second {
+	// Print every ProgressEveryN files or every ProgressInterval, whichever comes first
+	if !force && !p.progressDue(processed, timeSinceLastProgress) {
+		p.statsMutex.Unlock()
 		return
 	}
 
 	p.lastProgress = now
 	elapsed := now.Sub(p.startTime)
 
+	// Embedders receive a structured snapshot instead of the console log
+	if p.config.ProgressCallback != nil {
+		snapshot := p.statsSnapshot()
+		p.statsMutex.Unlock()
+		p.deliverProgress(snapshot)
+		return
+	}
+	defer p.statsMutex.Unlock()
+
 	if processed == 0 {
 		return
 	}
@@ -896,6 +1809,63 @@ func (p *PhotoProcessor) printProgress(force bool) {
 		rate, formatDuration(elapsed), eta)
 }
 
+// statsSnapshot copies the stats for a progress callback; statsMutex must be held
+func (p *PhotoProcessor) statsSnapshot() ProcessStats {
+	snapshot := *p.stats
+	snapshot.NeedsMetadata = slices.Clone(p.stats.NeedsMetadata)
+	snapshot.Transfers = maps.Clone(p.stats.Transfers)
+	return snapshot
+}
+
+// deliverProgress passes a snapshot to the progress callback. It runs without statsMutex,
+// so a slow callback doesn't hold up the workers and one that reads the processor's
+// stats can't deadlock, but callbacks still never run concurrently.
+func (p *PhotoProcessor) deliverProgress(snapshot ProcessStats) {
+	p.callbackMutex.Lock()
+	defer p.callbackMutex.Unlock()
+	p.config.ProgressCallback(snapshot)
+}
+
+// transferReporter returns the reporter for long transfers, or nil if disabled
+func (p *PhotoProcessor) transferReporter() *transferReporter {
+	if p.config.TransferProgressInterval <= 0 {
+		return nil
+	}
+	return &transferReporter{interval: p.config.TransferProgressInterval, report: p.reportTransfer}
+}
+
+// reportTransfer records a transfer's progress and passes it on to the progress callback or log
+func (p *PhotoProcessor) reportTransfer(status TransferStatus) {
+	p.statsMutex.Lock()
+	if p.stats.Transfers == nil {
+		p.stats.Transfers = make(map[string]TransferStatus)
+	}
+	p.stats.Transfers[status.File] = status
+	var snapshot ProcessStats
+	if p.config.ProgressCallback != nil {
+		snapshot = p.statsSnapshot()
+	}
+	if status.Done {
+		delete(p.stats.Transfers, status.File)
+	}
+	p.statsMutex.Unlock()
+
+	if p.config.ProgressCallback != nil {
+		p.deliverProgress(snapshot)
+		return
+	}
+
+	size := formatBytes(status.Bytes)
+	if status.Total >= 0 {
+		size = fmt.Sprintf("%s/%s (%.1f%%)", size, formatBytes(status.Total), float64(status.Bytes)/float64(max(status.Total, 1))*100)
+	}
+	state := "in progress"
+	if status.Done {
+		state = "done"
+	}
+	log.Printf("Transfer %s: %s %s at %s/s (%s)", status.Direction, filepath.Base(status.File), size, formatBytes(int64(status.Rate)), state)
+}
+
 // formatDuration formats a duration in a human-readable way
 func formatDuration(d time.Duration) string {
 	d = d.Round(time.Second)
@@ -918,9 +1888,70 @@ func (p *PhotoProcessor) printStats() {
 	fmt.Println("\n=== Processing Statistics ===")
 	fmt.Printf("Total files found:      %d\n", p.stats.TotalFiles)
 	fmt.Printf("Successfully processed: %d\n", p.stats.ProcessedFiles)
-	fmt.Printf("Skipped (no date):      %d\n", p.stats.SkippedFiles)
+	fmt.Printf("Skipped:                %d\n", p.stats.SkippedFiles)
+	other := p.stats.SkippedFiles
+	for _, reason := range []struct {
+		label string
+		count int
+	}{
+		{"no date", p.stats.SkippedNoDate},
+		{"already exists", p.stats.SkippedAlreadyExists},
+		{"filtered", p.stats.SkippedFiltered},
+		{"duplicate", p.stats.SkippedDuplicate},
+		{"outside date window", p.stats.SkippedOutOfWindow},
+	} {
+		other -= reason.count
+		if reason.count > 0 {
+			fmt.Printf("  %-22s%d\n", reason.label+":", reason.count)
+		}
+	}
+	if other > 0 {
+		fmt.Printf("  %-22s%d\n", "other:", other)
+	}
 	fmt.Printf("Errors:                 %d\n", p.stats.ErrorFiles)
 	fmt.Printf("Files moved:            %d\n", p.stats.MovedFiles)
 	fmt.Printf("Metadata updated:       %d\n", p.stats.UpdatedMetadata)
+	if p.config.VerifyExifWrite {
+		fmt.Printf("Metadata verify failed: %d\n", p.stats.ExifVerifyFailures)
+	}
+	if p.config.VerifyUpload {
+		fmt.Printf("Upload verify failed:   %d\n", p.stats.UploadVerifyFailures)
+	}
+	if p.config.MoveSidecars {
+		fmt.Printf("Sidecars copied:        %d\n", p.stats.SidecarFiles)
+	}
+	if p.cameraFilterEnabled() {
+		fmt.Printf("Camera filtered:        %d\n", p.stats.CameraFiltered)
+	}
+	if p.config.SkipDerivedPreviews {
+		fmt.Printf("Derived previews:       %d\n", p.stats.DerivedPreviews)
+	}
+	if p.config.MinFileSize > 0 {
+		fmt.Printf("Below minimum size:     %d\n", p.stats.SmallFiles)
+	}
+	if p.stats.IgnoredFiles > 0 {
+		fmt.Printf("Ignored files:          %d\n", p.stats.IgnoredFiles)
+	}
+	if p.stats.ManifestMissing > 0 {
+		fmt.Printf("Manifest files missing: %d\n", p.stats.ManifestMissing)
+	}
+	if p.config.RangeFolderDate != "" {
+		fmt.Printf("Range folder dated:     %d\n", p.stats.RangeFolderDated)
+	}
+	if p.config.ExifWriteMinConfidence != "" {
+		fmt.Printf("Dates not written:      %d\n", p.stats.DatesNotWritten)
+	}
+	if p.stats.DateConflicts > 0 {
+		fmt.Printf("Date conflicts:         %d\n", p.stats.DateConflicts)
+	}
+	if p.config.RetryErroredAtEnd {
+		fmt.Printf("Recovered on retry:     %d\n", p.stats.RetryRecovered)
+	}
+	if p.config.DryRun && p.config.MaxPathLength > 0 {
+		fmt.Printf("Paths too long:         %d\n", p.stats.LongPaths)
+	}
+	if len(p.stats.NeedsMetadata) > 0 {
+		fmt.Printf("Needs metadata:         %d\n", len(p.stats.NeedsMetadata))
+	}
 	fmt.Println("============================")
 }
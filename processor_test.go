@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgressCallbackRunsOutsideStatsLock(t *testing.T) {
+	var p *PhotoProcessor
+	var snapshots []ProcessStats
+	p = NewPhotoProcessor(&Config{ProgressCallback: func(stats ProcessStats) {
+		// Counting from the callback would deadlock if it ran under statsMutex
+		p.statsMutex.Lock()
+		p.stats.SkippedFiles++
+		p.statsMutex.Unlock()
+		snapshots = append(snapshots, stats)
+	}})
+	p.stats.ProcessedFiles = 3
+
+	done := make(chan struct{})
+	go func() {
+		p.printProgress(true)
+		p.printProgress(true)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress callback deadlocked")
+	}
+
+	if len(snapshots) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(snapshots))
+	}
+	if snapshots[0].ProcessedFiles != 3 || snapshots[0].SkippedFiles != 0 {
+		t.Errorf("first snapshot = %d processed, %d skipped; want 3, 0", snapshots[0].ProcessedFiles, snapshots[0].SkippedFiles)
+	}
+	if snapshots[1].SkippedFiles != 1 {
+		t.Errorf("second snapshot SkippedFiles = %d, want 1", snapshots[1].SkippedFiles)
+	}
+	if p.stats.SkippedFiles != 2 {
+		t.Errorf("SkippedFiles = %d, want 2", p.stats.SkippedFiles)
+	}
+}
+
+func TestReportTransferUpdatesSnapshot(t *testing.T) {
+	var snapshots []ProcessStats
+	p := NewPhotoProcessor(&Config{ProgressCallback: func(stats ProcessStats) {
+		snapshots = append(snapshots, stats)
+	}})
+
+	// Two workers' transfers in flight at once each keep their own progress
+	p.reportTransfer(TransferStatus{File: "a.mp4", Bytes: 10, Total: 20})
+	p.reportTransfer(TransferStatus{File: "b.mp4", Bytes: 5, Total: 50})
+	p.reportTransfer(TransferStatus{File: "a.mp4", Bytes: 20, Total: 20, Done: true})
+
+	if len(snapshots) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snapshots))
+	}
+	if got := snapshots[1].Transfers; len(got) != 2 || got["a.mp4"].Bytes != 10 || got["b.mp4"].Bytes != 5 {
+		t.Errorf("second snapshot Transfers = %+v, want a.mp4 at 10 bytes and b.mp4 at 5", got)
+	}
+	if got := snapshots[2].Transfers["a.mp4"]; !got.Done {
+		t.Errorf("final report of a.mp4 missing from its snapshot: %+v", snapshots[2].Transfers)
+	}
+	if _, ok := p.stats.Transfers["a.mp4"]; ok || len(p.stats.Transfers) != 1 {
+		t.Errorf("Transfers = %+v after a.mp4 finished, want only b.mp4", p.stats.Transfers)
+	}
+}
+
+func TestUnknownNamesAreReservedAcrossWorkers(t *testing.T) {
+	destDir := t.TempDir()
+	srcDir := t.TempDir()
+	unknownDir := filepath.Join(destDir, "unknown")
+	if err := os.MkdirAll(unknownDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A file from an earlier run already holds the plain name
+	if err := os.WriteFile(filepath.Join(unknownDir, "IMG_0001.jpg"), []byte("earlier"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPhotoProcessor(&Config{DestDir: destDir})
+	p.dest = localDestination{dirMode: 0755}
+
+	const workers = 64
+	sources := make([]string, workers)
+	for i := range sources {
+		sources[i] = filepath.Join(srcDir, fmt.Sprintf("src%d.jpg", i))
+		if err := os.WriteFile(sources[i], []byte(fmt.Sprintf("file %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := make([]string, workers)
+	errs := make([]error, workers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			paths[i], errs[i] = p.copyToUnknown(sources[i], "IMG_0001.jpg")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	seen := make(map[string]int)
+	for i, path := range paths {
+		if errs[i] != nil {
+			t.Fatalf("worker %d: %v", i, errs[i])
+		}
+		if other, dup := seen[path]; dup {
+			t.Fatalf("workers %d and %d both got %s", other, i, path)
+		}
+		seen[path] = i
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("file %d", i); string(data) != want {
+			t.Errorf("%s holds %q, want %q (clobbered by another worker)", path, data, want)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(unknownDir, "IMG_0001.jpg")); string(data) != "earlier" {
+		t.Errorf("existing IMG_0001.jpg was overwritten with %q", data)
+	}
+	entries, err := os.ReadDir(unknownDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != workers+1 {
+		t.Errorf("unknown/ holds %d files, want %d", len(entries), workers+1)
+	}
+}
+
+func TestSplitFilename(t *testing.T) {
+	tests := []struct {
+		path     string
+		wantDesc string
+		wantExt  string
+	}{
+		{"/src/IMG_0001.jpg", "IMG_0001", ".jpg"},
+		// Extensionless names, including ones whose dots are part of a date
+		{"/src/20180101", "20180101", ""},
+		{"/src/2018.01.01", "2018.01.01", ""},
+		{"/src/party 2018.10.21", "party 2018.10.21", ""},
+		{"/src/notes. final", "notes. final", ""},
+		// Double extensions: a repeated one is dropped, a different one stays in the description
+		{"/src/IMG_0001.jpg.jpg", "IMG_0001", ".jpg"},
+		{"/src/IMG_0001.JPG.jpg", "IMG_0001", ".jpg"},
+		{"/src/scan.jpeg.jpg", "scan.jpeg", ".jpg"},
+		{"/src/backup.tar.mp4", "backup.tar", ".mp4"},
+	}
+	for _, tt := range tests {
+		desc, ext := splitFilename(tt.path)
+		if desc != tt.wantDesc || ext != tt.wantExt {
+			t.Errorf("splitFilename(%q) = %q, %q, want %q, %q", tt.path, desc, ext, tt.wantDesc, tt.wantExt)
+		}
+	}
+}
+
+func TestStandardizedNameExtensions(t *testing.T) {
+	opts := NameOptions{Separator: "_", StripDatePrefix: true}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/src/20180101", "2018-01-01_photo"},
+		{"/src/2018-01-01 beach", "2018-01-01_beach"},
+		{"/src/20180101.jpg.jpg", "2018-01-01_photo.jpg"},
+		{"/src/20180101_beach.jpeg.jpg", "2018-01-01_beach.jpeg.jpg"},
+	}
+	for _, tt := range tests {
+		dateInfo, err := ParseDateFromFilename(tt.path)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.path, err)
+		}
+		desc, ext := splitFilename(tt.path)
+		if name := dateInfo.StandardizedFilename(desc, ext, opts); name != tt.want {
+			t.Errorf("standardized name of %q = %q, want %q", tt.path, name, tt.want)
+		}
+	}
+}
+
+func TestMinFileSizeSkipsThumbnails(t *testing.T) {
+	srcDir := t.TempDir()
+	sizes := map[string]int{"thumb.jpg": 5 * 1024, "edge.jpg": 10 * 1024, "photo.jpg": 20 * 1024}
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(srcDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := newTestSSHClient(t)
+	tests := []struct {
+		name      string
+		source    Source
+		wantFiles int
+		wantSmall int
+	}{
+		{"local", localSource{}, 2, 1},
+		{"remote", sshSource{client: client, withSizes: true}, 2, 1},
+		// Files whose size the walk didn't report are kept
+		{"unknown size", sshSource{client: client}, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPhotoProcessor(&Config{SourceDir: srcDir, DestDir: t.TempDir(), MinFileSize: 10 * 1024, DryRun: true})
+			p.startTime = time.Now()
+			p.source = tt.source
+			p.dest = localDestination{dirMode: 0755}
+			if err := p.walkDirectory(context.Background(), srcDir); err != nil {
+				t.Fatal(err)
+			}
+			if p.stats.TotalFiles != tt.wantFiles || p.stats.SmallFiles != tt.wantSmall {
+				t.Errorf("found %d media files and %d small files, want %d and %d",
+					p.stats.TotalFiles, p.stats.SmallFiles, tt.wantFiles, tt.wantSmall)
+			}
+		})
+	}
+}
+
+func TestLimitAppliesAfterDedup(t *testing.T) {
+	srcDir := t.TempDir()
+	files := map[string]string{
+		"2018-01-01_a.jpg":      "photo a",
+		"2018-01-01_a copy.jpg": "photo a",
+		"2018-01-02_b.jpg":      "photo b",
+		"2018-01-03_c.jpg":      "photo c",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewPhotoProcessor(&Config{SourceDir: srcDir, DestDir: t.TempDir(), Limit: 2, DedupKeep: DedupKeepFirstSeen})
+	p.startTime = time.Now()
+	p.source = localSource{}
+	p.dest = newDirCachingDestination(localDestination{dirMode: 0755})
+	if err := p.walkDirectory(context.Background(), srcDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// The duplicate is dropped first, so the cap still leaves two real photos
+	if p.stats.ProcessedFiles != 2 {
+		t.Errorf("ProcessedFiles = %d, want 2", p.stats.ProcessedFiles)
+	}
+	if p.stats.SkippedDuplicate != 1 {
+		t.Errorf("SkippedDuplicate = %d, want 1", p.stats.SkippedDuplicate)
+	}
+	// Progress reaches exactly 100%: the capped files and the dropped duplicate
+	if done := p.stats.ProcessedFiles + p.stats.SkippedFiles + p.stats.ErrorFiles; p.stats.TotalFiles != done {
+		t.Errorf("TotalFiles = %d, but %d files were accounted for", p.stats.TotalFiles, done)
+	}
+}
+
+func TestWriteToDestinationStagesNextToLocalFile(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	source := filepath.Join(srcDir, "IMG_0001.png")
+	if err := os.WriteFile(source, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPhotoProcessor(&Config{DestDir: destDir})
+	p.dest = localDestination{dirMode: 0755, fileMode: 0640}
+	if _, ok := p.dest.(inPlaceStager); !ok {
+		t.Fatal("local destination doesn't stage in place")
+	}
+
+	destPath := filepath.Join(destDir, "2020", "2020-05-17_IMG_0001.png")
+	if err := p.writeToDestination(source, source, destPath, time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC), false); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(filepath.Dir(destPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("destination folder holds %d files, want only the written one (partial file left behind?)", len(entries))
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// startProfiling starts the CPU profile and execution trace requested in the config.
+// The returned stop function ends them and writes the heap profile, so it must run
+// once processing is done.
+func (p *PhotoProcessor) startProfiling() (stop func(), err error) {
+	var stops []func()
+	stop = func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if p.config.CPUProfile != "" {
+		f, err := os.Create(p.config.CPUProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+			log.Printf("Wrote CPU profile: %s", p.config.CPUProfile)
+		})
+	}
+
+	if p.config.TracePath != "" {
+		f, err := os.Create(p.config.TracePath)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to create trace: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("failed to start trace: %w", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+			log.Printf("Wrote execution trace: %s", p.config.TracePath)
+		})
+	}
+
+	if p.config.MemProfile != "" {
+		stops = append(stops, func() {
+			if err := writeHeapProfile(p.config.MemProfile); err != nil {
+				log.Printf("Warning: %v", err)
+				return
+			}
+			log.Printf("Wrote memory profile: %s", p.config.MemProfile)
+		})
+	}
+	return stop, nil
+}
+
+// writeHeapProfile writes a heap profile reflecting the state after the last garbage collection
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	defer f.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Dates given to undateable files in a year-range folder (Config.RangeFolderDate)
+const (
+	RangeFolderDateStart    = "start"    // January 1st of the range's first year
+	RangeFolderDateMidpoint = "midpoint" // The day halfway through the range
+)
+
+// yearRangeRegex matches a year range in a folder name, e.g. "2010-2019" or "1980 - 1989 scans"
+var yearRangeRegex = regexp.MustCompile(`(?:^|\D)((?:18|19|20)\d{2})\s*-\s*((?:18|19|20)\d{2})(?:\D|$)`)
+
+// validateRangeFolderDate checks a range folder date mode
+func validateRangeFolderDate(mode string) error {
+	switch mode {
+	case "", RangeFolderDateStart, RangeFolderDateMidpoint:
+		return nil
+	default:
+		return fmt.Errorf("unsupported range folder date %q (use %s or %s)", mode, RangeFolderDateStart, RangeFolderDateMidpoint)
+	}
+}
+
+// rangeFolderDate dates a file from the nearest folder above it (up to sourceDir) named
+// with a year range, or returns nil if there is none
+func rangeFolderDate(filePath, sourceDir, mode string) *DateInfo {
+	for dir := filepath.Dir(filePath); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(sourceDir, dir)
+		if err != nil || !filepath.IsLocal(rel) {
+			return nil
+		}
+
+		if m := yearRangeRegex.FindStringSubmatch(filepath.Base(dir)); m != nil {
+			first, _ := strconv.Atoi(m[1])
+			last, _ := strconv.Atoi(m[2])
+			if first <= last && validYear(first) && validYear(last) {
+				date := time.Date(first, time.January, 1, 0, 0, 0, 0, time.UTC)
+				if mode == RangeFolderDateMidpoint {
+					end := time.Date(last+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+					date = date.Add(end.Sub(date) / 2)
+				}
+				return DateInfoFromTime(date, filepath.Base(filePath))
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// RelinkExisting walks the destination and writes each organized file's date, read back
+// from its standardized name, into its metadata in place. It repairs a tree copied before
+// exiftool was installed without going back to the source. As when the file was first
+// organized, a metadata time in the name's year is kept rather than rewritten.
+func (p *PhotoProcessor) RelinkExisting() error {
+	p.configureExiftool()
+	if !checkExiftoolAvailable() {
+		log.Println("Warning: exiftool not found. EXIF metadata will only be updated for JPEGs.")
+	}
+
+	var walker Source = localSource{pruneDirs: p.junkDirs()}
+	p.dest = localDestination{dirMode: p.dirMode(), fileMode: p.config.FileMode}
+	switch {
+	case p.config.S3Bucket != "":
+		return fmt.Errorf("relinking existing files is not supported for S3 destinations")
+	case p.config.RemoteDest:
+		if p.config.DestSSHHost == "" {
+			return fmt.Errorf("remote destination requires -dest-ssh-host or -ssh-host")
+		}
+		client, err := NewSSHClient(p.config.DestSSHHost, p.config.DestSSHPort)
+		if err != nil {
+			return fmt.Errorf("failed to create SSH client for destination: %w", err)
+		}
+		defer client.Close()
+		client.StartKeepalive(p.config.SSHKeepalive)
+		walker = sshSource{client: client, parallelism: p.config.ParallelWalks, pruneDirs: p.junkDirs()}
+		p.dest = sshDestination{client: client, dirMode: p.dirMode(), fileMode: p.config.FileMode}
+		p.destClient = client
+	}
+
+	files, err := walker.Walk(p.config.DestDir)
+	if err != nil {
+		return fmt.Errorf("failed to walk destination: %w", err)
+	}
+	var paths []string
+	for _, file := range files {
+		if (isMediaFile(file.Path) || (p.config.IncludeRaw && isRawFile(file.Path)) || (p.config.PDFWriteDate && isPDFFile(file.Path))) && !isContactSheet(file.Path) {
+			paths = append(paths, file.Path)
+		}
+	}
+	naturalSort(paths)
+	log.Printf("Relinking metadata of %d destination files", len(paths))
+
+	// Files without a time in their name get sequential times, as when they were organized,
+	// counted separately for each day so one day's files don't push the next day's along
+	lastTimestamps := make(map[string]*time.Time)
+	nameOpts := p.nameOptions()
+	var relinked, unchanged, unnamed int
+	for _, destPath := range paths {
+		dateInfo := ParseStandardizedName(filepath.Base(destPath), nameOpts)
+		if dateInfo == nil {
+			if p.config.Verbose {
+				log.Printf("Skipping (not a standardized name): %s", destPath)
+			}
+			unnamed++
+			continue
+		}
+
+		timestamp, current, err := p.relinkTimestamp(destPath, dateInfo, lastTimestamps)
+		if err != nil {
+			log.Printf("Error: %s: %v", destPath, err)
+			p.count(&p.stats.ErrorFiles)
+			continue
+		}
+		if current {
+			if p.config.Verbose {
+				log.Printf("Skipping (metadata already has its date): %s", destPath)
+			}
+			unchanged++
+			continue
+		}
+
+		if p.config.DryRun {
+			log.Printf("[DRY RUN] Would relink metadata: %s -> %s", destPath, timestamp.Format("2006-01-02 15:04:05"))
+			relinked++
+			continue
+		}
+		if p.config.Verbose {
+			log.Printf("[Timestamp] %s -> %s (from name)", filepath.Base(destPath), timestamp.Format("2006-01-02 15:04:05"))
+		}
+		if err := p.fixDestinationMetadata(destPath, timestamp); err != nil {
+			log.Printf("Error: %s: %v", destPath, err)
+			p.count(&p.stats.ErrorFiles)
+			continue
+		}
+		relinked++
+	}
+
+	log.Printf("Relinked %d files; %d already had their date, %d without a standardized name, %d errors",
+		relinked, unchanged, unnamed, p.stats.ErrorFiles)
+	if p.config.NeedsMetadataPath != "" {
+		if err := p.writeNeedsMetadata(); err != nil {
+			return err
+		}
+		log.Printf("Wrote %d files needing metadata to %s", len(p.stats.NeedsMetadata), p.config.NeedsMetadataPath)
+	}
+	return nil
+}
+
+// relinkTimestamp works out the timestamp for a destination file dated by its name, and
+// whether its metadata already has it: a time in the name is used as-is, otherwise the
+// metadata's own time is kept if it falls in the name's year, as DetermineCorrectTimestamp
+// decides when a file is first organized
+func (p *PhotoProcessor) relinkTimestamp(destPath string, dateInfo *DateInfo, lastTimestamps map[string]*time.Time) (time.Time, bool, error) {
+	localPath, cleanup, err := p.dest.Fetch(destPath)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to fetch: %w", err)
+	}
+	defer cleanup()
+
+	day := fmt.Sprintf("%04d-%02d-%02d", dateInfo.Year, dateInfo.Month, dateInfo.Day)
+	if lastTimestamps[day] == nil {
+		lastTimestamps[day] = &time.Time{}
+	}
+
+	if dateInfo.HasTime {
+		timestamp := dateInfo.ToTimeWithDefault(p.config.DefaultTimeOfDay)
+		current, ok := ReadCaptureTime(localPath)
+		sequentialTimestamp(dateInfo, timestamp, true, lastTimestamps[day], p.config.DefaultTimeOfDay)
+		// Compare wall clocks, since metadata times carry no zone
+		return timestamp, ok && current.Format("2006-01-02 15:04:05") == timestamp.Format("2006-01-02 15:04:05"), nil
+	}
+
+	correctTimestamp, isFromEXIF := DetermineCorrectTimestamp(localPath, dateInfo)
+	timestamp := sequentialTimestamp(dateInfo, correctTimestamp, isFromEXIF, lastTimestamps[day], p.config.DefaultTimeOfDay)
+	return timestamp, isFromEXIF, nil
+}
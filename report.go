@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// Per-file report output formats
+const (
+	ReportFormatJSON   = "json"   // A single JSON array of results
+	ReportFormatNDJSON = "ndjson" // One JSON object per line, readable while the run is in progress
+	ReportFormatCSV    = "csv"    // A header row followed by one row per result
+)
+
+// Per-file result statuses
+const (
+	ResultProcessed = "processed" // Copied to the destination with updated metadata
+	ResultFixed     = "fixed"     // Metadata of the existing destination file updated
+	ResultDryRun    = "dry-run"   // Would have been processed
+	ResultUnknown   = "unknown"   // No date found, copied to unknown/
+	ResultSkipped   = "skipped"   // Left alone; Reason says why
+	ResultError     = "error"     // Processing failed; Error holds the message
+)
+
+// FileResult is the outcome of processing one source file, shared by all report formats
+type FileResult struct {
+	Source          string `json:"source"`
+	Destination     string `json:"destination,omitempty"`
+	Status          string `json:"status"`
+	Reason          string `json:"reason,omitempty"`
+	Timestamp       string `json:"timestamp,omitempty"`        // Date written to the file, "YYYY-MM-DD HH:MM:SS"
+	TimestampSource string `json:"timestamp_source,omitempty"` // "exif", "parsed", "override", "folder", "takeout", "range" or "manual"
+	DateSource      string `json:"date_source,omitempty"`      // Where the date came from: a DateSource
+	DateConflict    string `json:"date_conflict,omitempty"`    // Both candidates when the name and folder disagreed, e.g. "filename 2018-10-21, folder 2015-01-01"
+	Error           string `json:"error,omitempty"`
+}
+
+// reportColumns is the CSV header, in the order written by csvRecord
+var reportColumns = []string{"source", "destination", "status", "reason", "timestamp", "timestamp_source", "date_source", "date_conflict", "error"}
+
+// csvRecord returns the result as a CSV row matching reportColumns
+func (r FileResult) csvRecord() []string {
+	return []string{r.Source, r.Destination, r.Status, r.Reason, r.Timestamp, r.TimestampSource, r.DateSource, r.DateConflict, r.Error}
+}
+
+// reportWriter streams per-file results to a report file as they are produced,
+// so even huge runs never hold the whole report in memory
+type reportWriter struct {
+	mutex  sync.Mutex
+	file   *os.File
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	format string
+	count  int
+}
+
+// newReportWriter creates the report file and writes any header the format needs
+func newReportWriter(path, format string) (*reportWriter, error) {
+	if err := validateReportFormat(format); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report: %w", err)
+	}
+	r := &reportWriter{file: file, buf: bufio.NewWriter(file), format: format}
+
+	switch format {
+	case ReportFormatCSV:
+		r.csv = csv.NewWriter(r.buf)
+		r.csv.Write(reportColumns)
+	case ReportFormatJSON, "":
+		r.buf.WriteString("[")
+	}
+	return r, nil
+}
+
+// Write appends one result to the report and flushes it to disk
+func (r *reportWriter) Write(result FileResult) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch r.format {
+	case ReportFormatCSV:
+		r.csv.Write(result.csvRecord())
+		r.csv.Flush()
+		if err := r.csv.Error(); err != nil {
+			return err
+		}
+	default:
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		if r.format == ReportFormatNDJSON {
+			data = append(data, '\n')
+		} else {
+			// Keep one element per line so the array is still easy to grep
+			sep := ",\n"
+			if r.count == 0 {
+				sep = "\n"
+			}
+			r.buf.WriteString(sep)
+		}
+		r.buf.Write(data)
+	}
+
+	r.count++
+	return r.buf.Flush()
+}
+
+// Close finishes the report (closing the JSON array) and closes the file
+func (r *reportWriter) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.format == ReportFormatJSON || r.format == "" {
+		r.buf.WriteString("\n]\n")
+	}
+	if err := r.buf.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// recordResult adds a file's result to the report, if one is being written
+func (p *PhotoProcessor) recordResult(result FileResult) {
+	if p.report == nil {
+		return
+	}
+	if err := p.report.Write(result); err != nil {
+		log.Printf("Warning: failed to write report entry for %s: %v", result.Source, err)
+	}
+}
+
+// validateReportFormat checks a report format name ("" means json)
+func validateReportFormat(format string) error {
+	switch format {
+	case "", ReportFormatJSON, ReportFormatNDJSON, ReportFormatCSV:
+		return nil
+	default:
+		return fmt.Errorf("unsupported report format %q (use %s, %s or %s)", format, ReportFormatJSON, ReportFormatNDJSON, ReportFormatCSV)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// retryErrored processes the files that errored in the main pass once more, after the
+// pass has finished (Config.RetryErroredAtEnd). Transient failures, such as a dropped SSH
+// connection, then don't need a whole re-run. SSH connections are re-dialed first. A file
+// that now succeeds or is skipped moves out of the error count; the report gets the
+// retry's result for each file, or its original error if the retry failed too.
+func (p *PhotoProcessor) retryErrored(imageFiles []string, failed map[int]FileResult, seq *timestampSequencer) {
+	if len(failed) == 0 {
+		return
+	}
+	log.Printf("Retrying %d files that failed", len(failed))
+	p.reconnectSSH()
+
+	indexes := make([]int, 0, len(failed))
+	for i := range failed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	recovered := 0
+	for _, i := range indexes {
+		// The file's turn in the sequencer has passed, so it is timed after the last file
+		result := FileResult{Source: imageFiles[i]}
+		if err := p.processPhotoRecovered(imageFiles[i], i, seq, &result); err != nil {
+			log.Printf("Error processing %s on retry: %v", imageFiles[i], err)
+			p.recordResult(failed[i])
+			continue
+		}
+		p.statsMutex.Lock()
+		p.stats.ErrorFiles--
+		p.stats.RetryRecovered++
+		p.statsMutex.Unlock()
+		recovered++
+		p.recordResult(result)
+	}
+	log.Printf("Recovered %d of %d failed files on retry", recovered, len(failed))
+}
+
+// reconnectSSH replaces the source and destination SSH connections with fresh ones
+func (p *PhotoProcessor) reconnectSSH() {
+	for _, client := range []*SSHClient{p.sshClient, p.destClient} {
+		if client == nil || (client == p.destClient && client == p.sshClient) {
+			continue
+		}
+		if err := client.reconnect(client.client()); err != nil {
+			log.Printf("Warning: failed to reconnect to %s: %v", client.host, err)
+		}
+	}
+}
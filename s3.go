@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Client handles uploads to S3-compatible object storage (AWS S3, Backblaze B2, MinIO)
+// using path-style requests signed with AWS Signature Version 4
+type S3Client struct {
+	endpoint   *url.URL
+	bucket     string
+	region     string
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+	progress   *transferReporter // Reports long uploads/downloads (nil disables)
+}
+
+// NewS3Client creates a new S3 client
+// endpoint is the service URL, e.g. "https://s3.us-west-002.backblazeb2.com"
+func NewS3Client(endpoint, bucket, region, accessKey, secretKey string) (*S3Client, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("S3 credentials are required - set -s3-access-key/-s3-secret-key or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return &S3Client{
+		endpoint:   u,
+		bucket:     bucket,
+		region:     region,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		httpClient: &http.Client{Transport: newS3Transport()},
+	}, nil
+}
+
+// s3ResponseHeaderTimeout is how long a request waits for the service to start
+// answering once it has been sent
+var s3ResponseHeaderTimeout = 2 * time.Minute
+
+// newS3Transport returns a transport that gives up on a stalled connection instead of
+// hanging a worker forever. There's no overall deadline, which would cut off large
+// uploads on slow links; TCP keepalives notice a peer that has gone away mid-transfer.
+func newS3Transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: s3ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   16,
+	}
+}
+
+// SetTransferProgress enables periodic progress reports for uploads and downloads
+func (c *S3Client) SetTransferProgress(progress *transferReporter) {
+	c.progress = progress
+}
+
+// FileExists checks if an object exists at the given destination path
+func (c *S3Client) FileExists(destPath string) (bool, error) {
+	req, err := c.newRequest(http.MethodHead, destPath, nil, emptyPayloadHash)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check object existence: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check object existence: %s", resp.Status)
+	}
+}
+
+// UploadFile uploads a local file as an object at the given destination path
+func (c *S3Client) UploadFile(localPath, destPath string) error {
+	payloadHash, err := HashFile(localPath, HashSHA256) // SigV4 always signs SHA-256, whatever HashAlgo is
+	if err != nil {
+		return fmt.Errorf("failed to hash local file: %w", err)
+	}
+
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer localFile.Close()
+
+	info, err := localFile.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat local file: %w", err)
+	}
+
+	meter := c.progress.track(destPath, "upload", info.Size())
+	req, err := c.newRequest(http.MethodPut, destPath, meter.Reader(localFile), payloadHash)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload object: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	meter.finish()
+
+	return nil
+}
+
+// DownloadFile downloads the object at the given destination path to a local file
+// A failed download removes the partial local file
+func (c *S3Client) DownloadFile(destPath, localPath string) (err error) {
+	req, err := c.newRequest(http.MethodGet, destPath, nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download object: %s", resp.Status)
+	}
+
+	localFile, err := os.Create(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer func() {
+		localFile.Close()
+		if err != nil {
+			os.Remove(localPath)
+		}
+	}()
+
+	meter := c.progress.track(destPath, "download", resp.ContentLength)
+	if _, err := io.Copy(meter.Writer(localFile), resp.Body); err != nil {
+		return fmt.Errorf("failed to download object: %w", err)
+	}
+	meter.finish()
+
+	return localFile.Sync()
+}
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// objectKey converts a destination path into an object key
+func objectKey(destPath string) string {
+	return strings.TrimLeft(path.Clean(filepath.ToSlash(destPath)), "/")
+}
+
+// newRequest builds a signed path-style request for the object at destPath. The bucket
+// and key go after any path in the endpoint, for gateways mounted below the root.
+func (c *S3Client) newRequest(method, destPath string, body io.Reader, payloadHash string) (*http.Request, error) {
+	key := objectKey(destPath)
+	u := *c.endpoint
+	u.Path = strings.TrimRight(c.endpoint.Path, "/") + "/" + c.bucket + "/" + key
+	u.RawPath = strings.TrimRight(c.endpoint.EscapedPath(), "/") + "/" + s3EscapePath(c.bucket) + "/" + s3EscapePath(key)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+
+	c.sign(req, payloadHash, time.Now().UTC())
+	return req, nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request
+func (c *S3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	// Canonical headers must be sorted by lowercase name
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-date":           amzDate,
+		"x-amz-content-sha256": payloadHash,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + c.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.secretKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, c.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 computes an HMAC-SHA256 of data with the given key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath percent-encodes an object key as required by Signature Version 4,
+// leaving only unreserved characters and path separators as-is
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		ch := p[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
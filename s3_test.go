@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestS3RequestPathKeepsEndpointPrefix(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://s3.example.com", "/photos/2018/2018-10/2018-10-21_beach%20day.jpg"},
+		{"https://s3.example.com/", "/photos/2018/2018-10/2018-10-21_beach%20day.jpg"},
+		{"https://gw.example.com/storage/s3", "/storage/s3/photos/2018/2018-10/2018-10-21_beach%20day.jpg"},
+		{"https://gw.example.com/storage/s3/", "/storage/s3/photos/2018/2018-10/2018-10-21_beach%20day.jpg"},
+	}
+	for _, tt := range tests {
+		c, err := NewS3Client(tt.endpoint, "photos", "us-east-1", "key", "secret")
+		if err != nil {
+			t.Fatalf("NewS3Client(%q): %v", tt.endpoint, err)
+		}
+		req, err := c.newRequest(http.MethodHead, "/2018/2018-10/2018-10-21_beach day.jpg", nil, emptyPayloadHash)
+		if err != nil {
+			t.Fatalf("newRequest: %v", err)
+		}
+		if got := req.URL.EscapedPath(); got != tt.want {
+			t.Errorf("endpoint %q: request path = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestS3ClientRequestsReachGatewayPath(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c, err := NewS3Client(server.URL+"/gateway", "photos", "us-east-1", "key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := c.FileExists("2018/a.jpg")
+	if err != nil || exists {
+		t.Fatalf("FileExists = %v, %v; want false, nil", exists, err)
+	}
+	if gotPath != "/gateway/photos/2018/a.jpg" {
+		t.Errorf("server saw path %q, want /gateway/photos/2018/a.jpg", gotPath)
+	}
+}
+
+func TestS3ClientGivesUpOnStalledService(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	saved := s3ResponseHeaderTimeout
+	s3ResponseHeaderTimeout = 100 * time.Millisecond
+	defer func() { s3ResponseHeaderTimeout = saved }()
+
+	c, err := NewS3Client(server.URL, "photos", "us-east-1", "key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.FileExists("2018/a.jpg")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("FileExists on a stalled service succeeded, want a timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("FileExists hung on a stalled service")
+	}
+}
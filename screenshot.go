@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultScreenshotsDir is the destination subtree screenshots go to with Config.SeparateScreenshots
+const DefaultScreenshotsDir = "Screenshots"
+
+// screenshotNameRegex matches the names phones and desktops give screenshots, e.g.
+// "Screenshot_2018-05-04-10-00-00.png", "Screen Shot 2018-05-04 at 10.00.00.png" or
+// "Bildschirmfoto 2018-05-04 um 10.00.00.png", also once organized ("2018-05-04_Screenshot_...")
+var screenshotNameRegex = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:screen[ _-]?shot|scrnshot|bildschirmfoto|capture d.[ée]cran|schermafbeelding|captura de pantalla)`)
+
+// screenshotFolderRegex matches a folder that holds screenshots, e.g. the iOS "Screenshots" album
+var screenshotFolderRegex = regexp.MustCompile(`(?i)^screen[ _-]?shots?$`)
+
+// isScreenshot reports whether a file looks like a screenshot: its name says so, or it's
+// a PNG in a "Screenshots" folder at or below sourceDir (where iOS screenshots keep IMG_ names)
+func isScreenshot(filePath, sourceDir string) bool {
+	if screenshotNameRegex.MatchString(filepath.Base(filePath)) {
+		return true
+	}
+	if !strings.EqualFold(filepath.Ext(filePath), ".png") {
+		return false
+	}
+
+	root := filepath.Clean(sourceDir)
+	for dir := filepath.Dir(filePath); ; dir = filepath.Dir(dir) {
+		if screenshotFolderRegex.MatchString(filepath.Base(dir)) {
+			return true
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	return false
+}
+
+// validateScreenshotsDir checks a screenshots subtree stays inside the destination
+func validateScreenshotsDir(dir string) error {
+	if filepath.IsAbs(dir) {
+		return fmt.Errorf("screenshots folder %q must be relative to the destination", dir)
+	}
+	if clean := filepath.Clean(dir); clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("screenshots folder %q must be inside the destination", dir)
+	}
+	return nil
+}
+
+// screenshotDir is the folder a screenshot dated dateInfo goes to: <ScreenshotsDir>/YYYY
+func (p *PhotoProcessor) screenshotDir(dateInfo *DateInfo) string {
+	base := p.config.ScreenshotsDir
+	if base == "" {
+		base = DefaultScreenshotsDir
+	}
+	return filepath.Join(base, fmt.Sprintf("%04d", dateInfo.Year))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// timestampSequencer lets files be processed concurrently while sequential
+// timestamps are still handed out in natural file order: each file waits for
+// every earlier file to take (or give up) its turn before assigning its own
+type timestampSequencer struct {
+	mu       sync.Mutex
+	turn     *sync.Cond
+	next     int          // Index of the file whose turn it is
+	finished map[int]bool // Later files that finished before their turn came
+	last     time.Time    // Last timestamp handed out, shared across the run
+}
+
+func newTimestampSequencer() *timestampSequencer {
+	s := &timestampSequencer{finished: make(map[int]bool)}
+	s.turn = sync.NewCond(&s.mu)
+	return s
+}
+
+// assign waits for file index's turn, computes its timestamp from the last one
+// handed out and then ends the turn
+func (s *timestampSequencer) assign(index int, fn func(lastTimestamp *time.Time) time.Time) time.Time {
+	s.mu.Lock()
+	for s.next < index {
+		s.turn.Wait()
+	}
+	timestamp := fn(&s.last)
+	s.finishLocked(index)
+	s.mu.Unlock()
+	return timestamp
+}
+
+// finish gives up file index's turn; files call this however they finish,
+// so skipped or failed files never hold up later ones
+func (s *timestampSequencer) finish(index int) {
+	s.mu.Lock()
+	s.finishLocked(index)
+	s.mu.Unlock()
+}
+
+func (s *timestampSequencer) finishLocked(index int) {
+	if index < s.next {
+		return // Turn already taken
+	}
+	s.finished[index] = true
+	for s.finished[s.next] {
+		delete(s.finished, s.next)
+		s.next++
+	}
+	s.turn.Broadcast()
+}
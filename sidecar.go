@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// sidecarMatcher returns the candidate sidecar file names for a media file name
+type sidecarMatcher func(mediaBase string) []string
+
+// sidecarMatchers are consulted in order when MoveSidecars is enabled
+var sidecarMatchers = []sidecarMatcher{xmpSidecarNames, appleSidecarNames}
+
+// xmpSidecarNames matches XMP sidecars written as IMG_1234.xmp (Lightroom) or IMG_1234.jpg.xmp (darktable)
+func xmpSidecarNames(mediaBase string) []string {
+	stem := strings.TrimSuffix(mediaBase, filepath.Ext(mediaBase))
+	return []string{stem + ".xmp", mediaBase + ".xmp"}
+}
+
+// appleSidecarNames matches iPhone edit sidecars: IMG_1234.AAE, or IMG_O1234.AAE
+// when the edited copy was exported alongside the original
+func appleSidecarNames(mediaBase string) []string {
+	stem := strings.TrimSuffix(mediaBase, filepath.Ext(mediaBase))
+	names := []string{stem + ".aae"}
+	if len(stem) > 4 && strings.EqualFold(stem[:4], "IMG_") {
+		names = append(names, stem[:4]+"O"+stem[4:]+".aae")
+	}
+	return names
+}
+
+// findSidecars maps each media file to the sidecar files found next to it
+func findSidecars(files []SourceFile, mediaFiles []string) map[string][]string {
+	// Sidecar extensions vary in case (.AAE, .xmp), so look them up case-insensitively
+	byLowerPath := make(map[string]string, len(files))
+	for _, file := range files {
+		byLowerPath[strings.ToLower(file.Path)] = file.Path
+	}
+
+	sidecars := make(map[string][]string)
+	for _, mediaPath := range mediaFiles {
+		dir, base := filepath.Split(mediaPath)
+		seen := make(map[string]bool)
+		for _, match := range sidecarMatchers {
+			for _, name := range match(base) {
+				path, ok := byLowerPath[strings.ToLower(dir+name)]
+				if !ok || seen[path] {
+					continue
+				}
+				seen[path] = true
+				sidecars[mediaPath] = append(sidecars[mediaPath], path)
+			}
+		}
+	}
+	return sidecars
+}
+
+// sidecarDestPath names a sidecar after its organized media file, keeping the
+// sidecar's own suffix (".AAE", ".xmp" or ".jpg.xmp")
+func sidecarDestPath(mediaPath, sidecarPath, mediaDestPath string) string {
+	mediaBase := filepath.Base(mediaPath)
+	sidecarBase := filepath.Base(sidecarPath)
+
+	if len(sidecarBase) > len(mediaBase) && strings.EqualFold(sidecarBase[:len(mediaBase)], mediaBase) {
+		// IMG_1234.jpg.xmp -> <new name>.jpg.xmp
+		return mediaDestPath + sidecarBase[len(mediaBase):]
+	}
+	return strings.TrimSuffix(mediaDestPath, filepath.Ext(mediaDestPath)) + filepath.Ext(sidecarBase)
+}
+
+// copySidecars copies the sidecars of a media file next to its organized copy
+func (p *PhotoProcessor) copySidecars(mediaPath, mediaDestPath string) error {
+	for _, sidecarPath := range p.sidecars[mediaPath] {
+		destPath := sidecarDestPath(mediaPath, sidecarPath, mediaDestPath)
+
+		if p.config.DryRun {
+			log.Printf("[DRY RUN] Would copy sidecar: %s -> %s", sidecarPath, destPath)
+			continue
+		}
+
+		localPath, cleanup, err := p.source.Fetch(sidecarPath)
+		if err != nil {
+			return fmt.Errorf("failed to fetch sidecar %s: %w", sidecarPath, err)
+		}
+		err = p.writeVerified(localPath, destPath)
+		cleanup()
+		if err != nil {
+			return fmt.Errorf("failed to write sidecar %s: %w", destPath, err)
+		}
+
+		if p.config.Verbose {
+			log.Printf("Copied sidecar: %s -> %s", sidecarPath, destPath)
+		}
+		p.count(&p.stats.SidecarFiles)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// sniffLen is how many leading bytes are read to identify a file's type
+const sniffLen = 512
+
+// contentType is a media type identified from a file's leading bytes
+type contentType struct {
+	video bool
+	exts  []string // Extensions used for this type, canonical first
+}
+
+// matchesExt reports whether ext (e.g. ".JPEG") is a usual extension for the type
+func (t contentType) matchesExt(ext string) bool {
+	ext = strings.ToLower(ext)
+	for _, e := range t.exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jpegType = contentType{exts: []string{".jpg", ".jpeg"}}
+	pngType  = contentType{exts: []string{".png"}}
+	gifType  = contentType{exts: []string{".gif"}}
+	bmpType  = contentType{exts: []string{".bmp"}}
+	tiffType = contentType{exts: []string{".tif", ".tiff"}}
+	heicType = contentType{exts: []string{".heic", ".heif"}}
+	mp4Type  = contentType{video: true, exts: []string{".mp4", ".m4v"}}
+	movType  = contentType{video: true, exts: []string{".mov"}}
+	tgpType  = contentType{video: true, exts: []string{".3gp"}}
+	aviType  = contentType{video: true, exts: []string{".avi"}}
+	mkvType  = contentType{video: true, exts: []string{".mkv", ".webm"}}
+	mpegType = contentType{video: true, exts: []string{".mpg", ".mpeg"}}
+)
+
+// sniffContentType identifies a media type from a file's leading bytes
+// It returns known=false when the bytes are unrecognized, so the caller can fall
+// back to the extension, and media=false when they are recognizably something else
+func sniffContentType(head []byte) (t contentType, media bool, known bool) {
+	// ISO base media files (MP4, MOV, HEIC, 3GP) start with a size then "ftyp" and a brand
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch brand := string(head[8:12]); {
+		case brand == "heic" || brand == "heix" || brand == "heim" || brand == "heis" || brand == "mif1" || brand == "msf1":
+			return heicType, true, true
+		case brand == "qt  ":
+			return movType, true, true
+		case strings.HasPrefix(brand, "3g"):
+			return tgpType, true, true
+		default:
+			return mp4Type, true, true
+		}
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")):
+		return tiffType, true, true
+	case bytes.HasPrefix(head, []byte("\x1a\x45\xdf\xa3")):
+		return mkvType, true, true
+	case bytes.HasPrefix(head, []byte("\x00\x00\x01\xba")) || bytes.HasPrefix(head, []byte("\x00\x00\x01\xb3")):
+		return mpegType, true, true
+	}
+
+	mime := http.DetectContentType(head)
+	switch {
+	case mime == "image/jpeg":
+		return jpegType, true, true
+	case mime == "image/png":
+		return pngType, true, true
+	case mime == "image/gif":
+		return gifType, true, true
+	case mime == "image/bmp":
+		return bmpType, true, true
+	case mime == "video/avi":
+		return aviType, true, true
+	case mime == "video/webm":
+		return mkvType, true, true
+	case mime == "application/octet-stream":
+		return contentType{}, false, false
+	default:
+		// Text, HTML, PDF, archives and the like
+		return contentType{}, false, true
+	}
+}
+
+// detectMediaByContent decides whether a file is media from its content, falling back
+// to the extension for unrecognized content. It returns the extension the file should
+// have, or "" if its own extension already fits.
+func detectMediaByContent(src Source, path string) (isMedia bool, fixedExt string) {
+	head, err := src.ReadHead(path, sniffLen)
+	if err != nil {
+		return isMediaFile(path), ""
+	}
+
+	t, media, known := sniffContentType(head)
+	if !known {
+		return isMediaFile(path), ""
+	}
+	if !media {
+		return false, ""
+	}
+
+	if t.matchesExt(fileExt(path)) {
+		return true, ""
+	}
+	return true, t.exts[0]
+}
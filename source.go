@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileAttrs are the permission bits, ownership and modification time of a file
+type fileAttrs struct {
+	mode    os.FileMode
+	uid     int       // Owner user ID, or -1 if unknown
+	gid     int       // Owner group ID, or -1 if unknown
+	modTime time.Time // Modification time, or zero if unknown
+}
+
+// SourceFile describes a file found while walking a source
+type SourceFile struct {
+	Path string
+	Size int64 // Size in bytes, or -1 when the walk didn't report sizes
+}
+
+// Source abstracts where photos are read from (local filesystem or SSH host)
+type Source interface {
+	// Walk recursively lists the files under dir
+	Walk(dir string) ([]SourceFile, error)
+	// DirExists reports whether dir exists and is a directory
+	DirExists(dir string) (bool, error)
+	// Open streams the contents of a file
+	Open(path string) (io.ReadCloser, error)
+	// ReadHead returns up to the first n bytes of a file
+	ReadHead(path string, n int) ([]byte, error)
+	// Attrs returns a file's permission bits and owner
+	Attrs(path string) (fileAttrs, error)
+	// Fetch makes a file available on the local filesystem, returning its local path
+	// and a cleanup function to call once the local copy is no longer needed
+	Fetch(path string) (string, func(), error)
+}
+
+// readableChecker is a Source that can check many files in one go, where checking
+// them one at a time would cost a round trip each
+type readableChecker interface {
+	// ReadableFiles reports which of paths are readable regular files
+	ReadableFiles(paths []string) (map[string]bool, error)
+}
+
+// localSource reads from the local filesystem
+type localSource struct {
+	pruneDirs []string // Directory names skipped entirely while walking (e.g. Synology @eaDir)
+}
+
+// Walk lists local files, pruning directories named in pruneDirs
+func (s localSource) Walk(dir string) ([]SourceFile, error) {
+	var files []SourceFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Printf("Error accessing %s: %v", path, err)
+			return nil
+		}
+
+		if info.IsDir() {
+			// Skip junk directories such as @eaDir (Synology metadata), but never the root itself
+			if path != dir && isPrunedDir(info.Name(), s.pruneDirs) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		files = append(files, SourceFile{Path: path, Size: info.Size()})
+		return nil
+	})
+	return files, err
+}
+
+// DirExists reports whether a local directory exists
+func (localSource) DirExists(dir string) (bool, error) {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// Open opens a local file
+func (localSource) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
+
+// ReadHead reads the start of a local file
+func (localSource) ReadHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readHead(f, n)
+}
+
+// Attrs stats a local file
+func (localSource) Attrs(path string) (fileAttrs, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileAttrs{}, err
+	}
+	uid, gid := fileOwner(info)
+	return fileAttrs{mode: info.Mode().Perm(), uid: uid, gid: gid, modTime: info.ModTime()}, nil
+}
+
+// Fetch returns the local path as-is
+func (localSource) Fetch(path string) (string, func(), error) {
+	return path, func() {}, nil
+}
+
+// sshSource reads from a remote host over SSH
+type sshSource struct {
+	client      *SSHClient
+	parallelism int      // Concurrent find commands over top-level subdirectories (<= 1 runs a single find)
+	pruneDirs   []string // Directory names skipped entirely while walking (e.g. Synology @eaDir)
+	withSizes   bool     // List file sizes too (needs GNU find on the remote host)
+}
+
+// Walk lists remote files using find
+func (s sshSource) Walk(dir string) ([]SourceFile, error) {
+	var lines []string
+	var err error
+	if s.parallelism > 1 {
+		lines, err = s.client.WalkDirectoryParallel(dir, s.pruneDirs, s.withSizes, s.parallelism)
+	} else {
+		lines, err = s.client.WalkDirectory(dir, s.pruneDirs, s.withSizes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]SourceFile, 0, len(lines))
+	for _, line := range lines {
+		file := SourceFile{Path: line, Size: -1}
+		if s.withSizes {
+			sizeStr, path, ok := strings.Cut(line, "\t")
+			size, err := strconv.ParseInt(sizeStr, 10, 64)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("unexpected find output %q (sizes need GNU find on the source host)", line)
+			}
+			file = SourceFile{Path: path, Size: size}
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// DirExists reports whether a remote directory exists
+func (s sshSource) DirExists(dir string) (bool, error) {
+	return s.client.DirExists(dir)
+}
+
+// Open streams a remote file
+func (s sshSource) Open(path string) (io.ReadCloser, error) {
+	return s.client.OpenFile(path)
+}
+
+// ReadHead reads the start of a remote file without transferring the rest
+func (s sshSource) ReadHead(path string, n int) ([]byte, error) {
+	return s.client.ReadHead(path, n)
+}
+
+// ReadableFiles checks remote files with a single command
+func (s sshSource) ReadableFiles(paths []string) (map[string]bool, error) {
+	return s.client.ReadableFiles(paths)
+}
+
+// Attrs stats a remote file
+func (s sshSource) Attrs(path string) (fileAttrs, error) {
+	return s.client.Attrs(path)
+}
+
+// Fetch downloads a remote file to a temp file
+func (s sshSource) Fetch(path string) (string, func(), error) {
+	tempFile, err := os.CreateTemp("", "photo-source-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file for source: %w", err)
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+	cleanup := func() { os.Remove(tempPath) }
+
+	if err := s.client.DownloadFile(path, tempPath); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to download source file: %w", err)
+	}
+	return tempPath, cleanup, nil
+}
+
+// readHead reads up to n bytes, returning fewer only at end of file
+func readHead(r io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := io.ReadFull(r, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:read], err
+}
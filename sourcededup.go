@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+)
+
+// Which of several identical source files is processed (Config.DedupKeep)
+const (
+	DedupKeepFirstSeen    = "first-seen"    // The first in natural path order
+	DedupKeepLargest      = "largest"       // The largest file (identical files are the same size, so in practice the first in path order)
+	DedupKeepNewestMtime  = "newest-mtime"  // The most recently modified
+	DedupKeepShortestName = "shortest-name" // The one with the shortest file name
+)
+
+// validateDedupKeep checks a duplicate keep policy ("" disables source dedup)
+func validateDedupKeep(policy string) error {
+	switch policy {
+	case "", DedupKeepFirstSeen, DedupKeepLargest, DedupKeepNewestMtime, DedupKeepShortestName:
+		return nil
+	default:
+		return fmt.Errorf("unsupported dedup keep policy %q (use %s, %s, %s or %s)", policy, DedupKeepFirstSeen, DedupKeepLargest, DedupKeepNewestMtime, DedupKeepShortestName)
+	}
+}
+
+// dedupCandidate is a source file in a group of identical files
+type dedupCandidate struct {
+	path  string
+	size  int64
+	mtime int64 // Unix nanoseconds, 0 if unknown
+}
+
+// dropSourceDuplicates hashes the source files that share a size and keeps one file of
+// each group with identical content, chosen by Config.DedupKeep. Ties go to the first
+// file in natural path order, so re-runs over the same source make the same choice.
+// Each dropped file is skipped as a duplicate and reported with the file that was kept.
+func (p *PhotoProcessor) dropSourceDuplicates(files []string, sizes map[string]int64) []string {
+	// Only files of the same size can be identical, so only those are hashed
+	bySize := make(map[int64][]string)
+	for _, path := range files {
+		bySize[sizes[path]] = append(bySize[sizes[path]], path)
+	}
+
+	groups := make(map[string][]dedupCandidate)
+	hashed := 0
+	for _, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, path := range paths {
+			sum, size, err := hashSourceFile(p.source, path, p.config.HashAlgo)
+			if err != nil {
+				// Processing it will report the read error properly
+				log.Printf("Warning: failed to hash %s for dedup: %v", path, err)
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", sum, size)
+			groups[key] = append(groups[key], dedupCandidate{path: path, size: size})
+			hashed++
+		}
+	}
+	if p.config.Verbose {
+		log.Printf("Hashed %d source files sharing a size to find duplicates", hashed)
+	}
+
+	dropped := make(map[string]string)
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		if p.config.DedupKeep == DedupKeepNewestMtime {
+			for i := range group {
+				if attrs, err := p.source.Attrs(group[i].path); err == nil && !attrs.modTime.IsZero() {
+					group[i].mtime = attrs.modTime.UnixNano()
+				}
+			}
+		}
+		kept := keptDuplicate(group, p.config.DedupKeep)
+		for _, c := range group {
+			if c.path != kept {
+				dropped[c.path] = kept
+			}
+		}
+	}
+	if len(dropped) == 0 {
+		return files
+	}
+
+	kept := make([]string, 0, len(files)-len(dropped))
+	for _, path := range files {
+		keptPath, ok := dropped[path]
+		if !ok {
+			kept = append(kept, path)
+			continue
+		}
+		if p.config.Verbose {
+			log.Printf("Skipping (duplicate of %s): %s", keptPath, path)
+		}
+		p.skip(&p.stats.SkippedDuplicate)
+		p.recordResult(FileResult{
+			Source: path,
+			Status: ResultSkipped,
+			Reason: fmt.Sprintf("duplicate of %s (kept by %s)", keptPath, p.config.DedupKeep),
+		})
+	}
+	log.Printf("Dropped %d duplicate source files, keeping one of each by %s", len(dropped), p.config.DedupKeep)
+	return kept
+}
+
+// keptDuplicate returns the path of the file a policy keeps from a group of identical files
+func keptDuplicate(group []dedupCandidate, policy string) string {
+	sort.Slice(group, func(i, j int) bool {
+		a, b := group[i], group[j]
+		switch policy {
+		case DedupKeepLargest:
+			if a.size != b.size {
+				return a.size > b.size
+			}
+		case DedupKeepNewestMtime:
+			if a.mtime != b.mtime {
+				return a.mtime > b.mtime
+			}
+		case DedupKeepShortestName:
+			if la, lb := utf8.RuneCountInString(filepath.Base(a.path)), utf8.RuneCountInString(filepath.Base(b.path)); la != lb {
+				return la < lb
+			}
+		}
+		return naturalLess(a.path, b.path)
+	})
+	return group[0].path
+}
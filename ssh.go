@@ -1,24 +1,37 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
 
 // SSHClient handles SSH connections (without SFTP)
 type SSHClient struct {
-	sshClient *ssh.Client
-	host      string
+	sshClient     *ssh.Client
+	host          string
+	addr          string
+	config        *ssh.ClientConfig
+	mu            sync.Mutex // Protects sshClient across reconnects
+	stopKeepalive chan struct{}
+	closeOnce     sync.Once
+	progress      *transferReporter // Reports long uploads/downloads (nil disables)
 }
 
-// NewSSHClient creates a new SSH client
+// NewSSHClient creates a new SSH client. A port above 0 overrides any port in host;
+// otherwise the one in host is used, or 22.
 // host can be in format "user@host:port" or just "host" (uses SSH config)
-func NewSSHClient(host string) (*SSHClient, error) {
+func NewSSHClient(host string, port int) (*SSHClient, error) {
 	// Load SSH keys
 	authMethods := []ssh.AuthMethod{}
 	if keyAuth := publicKeyAuth(); keyAuth != nil {
@@ -35,7 +48,7 @@ func NewSSHClient(host string) (*SSHClient, error) {
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 	}
 
-	hostAddr := parseHostAddr(host)
+	hostAddr := parseHostAddr(host, port)
 
 	// Connect to SSH
 	client, err := ssh.Dial("tcp", hostAddr, config)
@@ -44,25 +57,198 @@ func NewSSHClient(host string) (*SSHClient, error) {
 	}
 
 	return &SSHClient{
-		sshClient: client,
-		host:      host,
+		sshClient:     client,
+		host:          host,
+		addr:          hostAddr,
+		config:        config,
+		stopKeepalive: make(chan struct{}),
 	}, nil
 }
 
 // Close closes the SSH connection
 func (c *SSHClient) Close() error {
+	c.closeOnce.Do(func() { close(c.stopKeepalive) })
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.sshClient != nil {
 		return c.sshClient.Close()
 	}
 	return nil
 }
 
-// WalkDirectory recursively walks through a remote directory using SSH
-func (c *SSHClient) WalkDirectory(dir string) ([]string, error) {
-	// Use find command to list all files
-	cmd := fmt.Sprintf("find %s -type f", shellescape(dir))
+// SetTransferProgress enables periodic progress reports for uploads and downloads
+func (c *SSHClient) SetTransferProgress(progress *transferReporter) {
+	c.progress = progress
+}
+
+// StartKeepalive periodically sends keepalive requests so routers don't drop
+// the connection during long idle gaps, reconnecting if a keepalive fails
+func (c *SSHClient) StartKeepalive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stopKeepalive:
+				return
+			case <-ticker.C:
+				client := c.client()
+				if err := ping(client, keepaliveTimeout); err != nil {
+					log.Printf("Warning: SSH keepalive to %s failed: %v", c.host, err)
+					if err := c.reconnect(client); err != nil {
+						log.Printf("Warning: failed to reconnect to %s: %v", c.host, err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// keepaliveTimeout bounds the wait for a keepalive reply, which never comes on a half-open connection
+const keepaliveTimeout = 15 * time.Second
+
+// ping sends a keepalive request, failing if the server doesn't reply within timeout.
+// A request left waiting returns once the dead connection is closed.
+func ping(client *ssh.Client, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no keepalive reply within %s", timeout)
+	}
+}
+
+// client returns the current underlying SSH connection
+func (c *SSHClient) client() *ssh.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sshClient
+}
+
+// reconnect replaces a dead connection with a fresh one
+// stale is the connection that was found dead; if another caller already replaced it, this is a no-op
+func (c *SSHClient) reconnect(stale *ssh.Client) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sshClient != stale {
+		return nil
+	}
+
+	stale.Close()
+	client, err := ssh.Dial("tcp", c.addr, c.config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SSH: %w", err)
+	}
+
+	c.sshClient = client
+	log.Printf("Reconnected to SSH host %s", c.host)
+	return nil
+}
+
+// newSession opens a session, reconnecting once if the connection has died. Other
+// workers may be mid-transfer on the same connection, so it is only replaced when
+// the server no longer answers on it.
+func (c *SSHClient) newSession() (*ssh.Session, error) {
+	client := c.client()
+	session, err := client.NewSession()
+	if err == nil {
+		return session, nil
+	}
+
+	// A refused session (e.g. the server's MaxSessions reached) came over a live connection
+	var refused *ssh.OpenChannelError
+	if errors.As(err, &refused) || ping(client, keepaliveTimeout) == nil {
+		return nil, err
+	}
+	if rerr := c.reconnect(client); rerr != nil {
+		return nil, fmt.Errorf("%w (reconnect failed: %v)", err, rerr)
+	}
+	return c.client().NewSession()
+}
+
+// WalkDirectory recursively walks through a remote directory using SSH,
+// pruning directories named in pruneDirs (e.g. Synology @eaDir metadata directories)
+func (c *SSHClient) WalkDirectory(dir string, pruneDirs []string, withSizes bool) ([]string, error) {
+	action := findAction(withSizes)
+	if len(pruneDirs) == 0 {
+		return c.find(fmt.Sprintf("find %s -type f %s", shellescape(dir), action))
+	}
+	return c.find(fmt.Sprintf("find %s \\( %s \\) -prune -o -type f %s", shellescape(dir), findNameExpr(pruneDirs), action))
+}
+
+// WalkDirectoryParallel walks a remote directory by listing its immediate subdirectories
+// and running up to concurrency find commands over them at once
+func (c *SSHClient) WalkDirectoryParallel(dir string, pruneDirs []string, withSizes bool, concurrency int) ([]string, error) {
+	files, err := c.find(fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -type f %s", shellescape(dir), findAction(withSizes)))
+	if err != nil {
+		return nil, err
+	}
+	listDirs := fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -type d -print", shellescape(dir))
+	if len(pruneDirs) > 0 {
+		listDirs = fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -type d ! \\( %s \\) -print", shellescape(dir), findNameExpr(pruneDirs))
+	}
+	subdirs, err := c.find(listDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]string, len(subdirs))
+	errs := make([]error, len(subdirs))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, subdir := range subdirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i], errs[i] = c.WalkDirectory(subdir, pruneDirs, withSizes)
+		}()
+	}
+	wg.Wait()
+
+	for i, subdir := range subdirs {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to walk %s: %w", subdir, errs[i])
+		}
+		files = append(files, results[i]...)
+	}
+	return files, nil
+}
+
+// findAction is the find action listing each file: its path, or "<size>\t<path>" with
+// sizes (-printf needs GNU find, so it's only used when sizes are wanted)
+func findAction(withSizes bool) string {
+	if withSizes {
+		return `-printf '%s\t%p\n'`
+	}
+	return "-print"
+}
+
+// findNameExpr builds a find expression matching any of names, e.g. "-name '@eaDir' -o -name '.Trashes'"
+func findNameExpr(names []string) string {
+	terms := make([]string, len(names))
+	for i, name := range names {
+		terms[i] = "-name " + shellescape(name)
+	}
+	return strings.Join(terms, " -o ")
+}
 
-	session, err := c.sshClient.NewSession()
+// find runs a find command and returns the non-empty lines it prints
+func (c *SSHClient) find(cmd string) ([]string, error) {
+	session, err := c.newSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -86,11 +272,12 @@ func (c *SSHClient) WalkDirectory(dir string) ([]string, error) {
 }
 
 // DownloadFile downloads a file from remote to local using cat over SSH
-func (c *SSHClient) DownloadFile(remotePath, localPath string) error {
+// A failed download removes the partial local file
+func (c *SSHClient) DownloadFile(remotePath, localPath string) (err error) {
 	// Use cat to stream file contents
 	cmd := fmt.Sprintf("cat %s", shellescape(remotePath))
 
-	session, err := c.sshClient.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
@@ -101,18 +288,106 @@ func (c *SSHClient) DownloadFile(remotePath, localPath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create local file: %w", err)
 	}
-	defer localFile.Close()
+	defer func() {
+		localFile.Close()
+		if err != nil {
+			os.Remove(localPath)
+		}
+	}()
 
 	// Stream remote file to local
-	session.Stdout = localFile
+	meter := c.progress.track(remotePath, "download", -1)
+	session.Stdout = meter.Writer(localFile)
 
 	if err := session.Run(cmd); err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
+	meter.finish()
 
 	return localFile.Sync()
 }
 
+// OpenFile streams a remote file using cat over SSH
+// The returned reader must be closed to release the session
+func (c *SSHClient) OpenFile(remotePath string) (io.ReadCloser, error) {
+	cmd := fmt.Sprintf("cat %s", shellescape(remotePath))
+
+	session, err := c.newSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to open stdout: %w", err)
+	}
+
+	if err := session.Start(cmd); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start cat: %w", err)
+	}
+
+	return &sessionReader{Reader: stdout, session: session}, nil
+}
+
+// ReadHead returns up to the first n bytes of a remote file using head over SSH
+func (c *SSHClient) ReadHead(remotePath string, n int) ([]byte, error) {
+	cmd := fmt.Sprintf("head -c %d %s", n, shellescape(remotePath))
+
+	session, err := c.newSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file head: %w", err)
+	}
+
+	return output, nil
+}
+
+// ReadableFiles reports which of paths are readable regular files on the remote host.
+// The paths go to one remote loop on its standard input, so checking them all costs a
+// single session however many there are.
+func (c *SSHClient) ReadableFiles(paths []string) (map[string]bool, error) {
+	session, err := c.newSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader(strings.Join(paths, "\n") + "\n")
+	output, err := session.Output(`while IFS= read -r f; do if [ -f "$f" ] && [ -r "$f" ]; then printf '%s\n' "$f"; fi; done`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check files: %w", err)
+	}
+
+	readable := make(map[string]bool, len(paths))
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			readable[line] = true
+		}
+	}
+	return readable, nil
+}
+
+// sessionReader reads a remote command's output and closes its session when done
+type sessionReader struct {
+	io.Reader
+	session *ssh.Session
+}
+
+// Close waits for the remote command and closes the session
+func (r *sessionReader) Close() error {
+	defer r.session.Close()
+	// Drain any unread output so the command can exit
+	io.Copy(io.Discard, r.Reader)
+	return r.session.Wait()
+}
+
 // UploadFile uploads a local file to remote using cat over SSH
 func (c *SSHClient) UploadFile(localPath, remotePath string) error {
 	// Open local file
@@ -122,30 +397,97 @@ func (c *SSHClient) UploadFile(localPath, remotePath string) error {
 	}
 	defer localFile.Close()
 
-	// Use cat to write file contents
-	cmd := fmt.Sprintf("cat > %s", shellescape(remotePath))
+	var total int64 = -1
+	if info, err := localFile.Stat(); err == nil {
+		total = info.Size()
+	}
+	return c.upload(localFile, total, remotePath, nil)
+}
 
-	session, err := c.sshClient.NewSession()
+// UploadStream writes everything read from r to a remote file using cat over SSH, then closes r
+// The file is only put in place if r also closes cleanly: a remote source that fails
+// mid-read (e.g. cat hitting an I/O error) only reports it when its session is closed
+func (c *SSHClient) UploadStream(r io.ReadCloser, remotePath string) error {
+	return c.upload(r, -1, remotePath, r.Close)
+}
+
+// upload streams r into remotePath, reporting progress against total bytes (-1 if unknown)
+// The data goes to a hidden partial file next to remotePath that is only renamed into
+// place once all of r was sent and sourceDone (if set) reports no error, so a failed
+// transfer never leaves a truncated file at remotePath for a later run to skip as existing.
+// The rename is a separate command because the remote cat can't tell a failed reader
+// from the end of the data: the session closes its input either way.
+func (c *SSHClient) upload(r io.Reader, total int64, remotePath string, sourceDone func() error) error {
+	partialPath := filepath.Join(filepath.Dir(remotePath), "."+filepath.Base(remotePath)+".partial")
+
+	err := c.writeRemote(r, total, remotePath, partialPath)
+	if sourceDone != nil {
+		if doneErr := sourceDone(); err == nil && doneErr != nil {
+			err = fmt.Errorf("failed to read source: %w", doneErr)
+		}
+	}
+	if err == nil {
+		err = c.runUploadCommand(fmt.Sprintf("mv -f %s %s", shellescape(partialPath), shellescape(remotePath)))
+	}
+	if err != nil {
+		// The session may have died with the connection; RemoveFile reconnects if so
+		if rmErr := c.RemoveFile(partialPath); rmErr != nil {
+			log.Printf("Warning: failed to remove partial upload %s: %v", partialPath, rmErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// writeRemote copies r into partialPath with cat, failing if r returns an error
+func (c *SSHClient) writeRemote(r io.Reader, total int64, remotePath, partialPath string) error {
+	session, err := c.newSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
-	// Stream local file to remote
-	session.Stdin = localFile
+	meter := c.progress.track(remotePath, "upload", total)
 
-	if err := session.Run(cmd); err != nil {
+	// Stream the data to remote; a read error from r fails Run
+	session.Stdin = meter.Reader(r)
+	// Keep the remote error (e.g. "No space left on device") for the caller
+	var stderr strings.Builder
+	session.Stderr = &stderr
+
+	if err := session.Run(fmt.Sprintf("cat > %s", shellescape(partialPath))); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("failed to upload file: %w: %s", err, msg)
+		}
 		return fmt.Errorf("failed to upload file: %w", err)
 	}
+	meter.finish()
 
 	return nil
 }
 
+// runUploadCommand runs a command that finishes an upload, keeping its error output
+func (c *SSHClient) runUploadCommand(cmd string) error {
+	session, err := c.newSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	if output, err := session.CombinedOutput(cmd); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("failed to upload file: %w: %s", err, msg)
+		}
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	return nil
+}
+
 // FileExists checks if a file exists on the remote server
 func (c *SSHClient) FileExists(remotePath string) (bool, error) {
 	cmd := fmt.Sprintf("test -f %s && echo exists || echo notfound", shellescape(remotePath))
 
-	session, err := c.sshClient.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return false, fmt.Errorf("failed to create session: %w", err)
 	}
@@ -159,11 +501,29 @@ func (c *SSHClient) FileExists(remotePath string) (bool, error) {
 	return strings.TrimSpace(string(output)) == "exists", nil
 }
 
-// CreateDirectory creates a directory on the remote server
-func (c *SSHClient) CreateDirectory(remotePath string) error {
-	cmd := fmt.Sprintf("mkdir -p %s", shellescape(remotePath))
+// DirExists checks if a directory exists on the remote server
+func (c *SSHClient) DirExists(remotePath string) (bool, error) {
+	cmd := fmt.Sprintf("test -d %s && echo exists || echo notfound", shellescape(remotePath))
+
+	session, err := c.newSession()
+	if err != nil {
+		return false, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.Output(cmd)
+	if err != nil {
+		return false, fmt.Errorf("failed to check directory existence: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)) == "exists", nil
+}
+
+// CreateDirectory creates a directory on the remote server with the given mode
+func (c *SSHClient) CreateDirectory(remotePath string, mode os.FileMode) error {
+	cmd := fmt.Sprintf("mkdir -p -m %04o %s", mode.Perm(), shellescape(remotePath))
 
-	session, err := c.sshClient.NewSession()
+	session, err := c.newSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
@@ -176,6 +536,155 @@ func (c *SSHClient) CreateDirectory(remotePath string) error {
 	return nil
 }
 
+// HasCommand reports whether a command is on the remote server's PATH
+func (c *SSHClient) HasCommand(name string) bool {
+	session, err := c.newSession()
+	if err != nil {
+		return false
+	}
+	defer session.Close()
+
+	return session.Run(fmt.Sprintf("command -v %s >/dev/null 2>&1", shellescape(name))) == nil
+}
+
+// UpdateExifDate sets a remote file's date/time tags with the server's exiftool
+func (c *SSHClient) UpdateExifDate(remotePath string, date time.Time) error {
+	var args []string
+	if isPDFFile(remotePath) {
+		args = pdfDateArgs(date)
+	} else {
+		for _, field := range exiftoolDateFields {
+			args = append(args, exiftoolDateArgs(field, date)...)
+		}
+	}
+
+	cmd := "exiftool -overwrite_original -q"
+	for _, arg := range args {
+		cmd += " " + shellescape(arg)
+	}
+	cmd += " " + shellescape(remotePath)
+
+	session, err := c.newSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	if output, err := session.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf("remote exiftool failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// remoteHashCommands are the commands that print a file's hash on the remote host, per algorithm
+// (xxhsum -H1 is XXH64, matching the local xxhash)
+var remoteHashCommands = map[string]string{
+	HashSHA256: "sha256sum",
+	HashMD5:    "md5sum",
+	HashXXHash: "xxhsum -H1",
+	HashBLAKE3: "b3sum",
+}
+
+// HashFile returns the hex-encoded hash of a remote file, computed on the remote host
+func (c *SSHClient) HashFile(remotePath, algo string) (string, error) {
+	command, ok := remoteHashCommands[hashAlgoName(algo)]
+	if !ok {
+		return "", fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+
+	session, err := c.newSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.Output(fmt.Sprintf("%s %s", command, shellescape(remotePath)))
+	if err != nil {
+		return "", fmt.Errorf("remote %s failed: %w", command, err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("remote %s printed nothing", command)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// RemoveFile deletes a file on the remote server
+func (c *SSHClient) RemoveFile(remotePath string) error {
+	cmd := fmt.Sprintf("rm -f %s", shellescape(remotePath))
+
+	session, err := c.newSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+
+	return nil
+}
+
+// Chmod sets the mode of a file on the remote server
+func (c *SSHClient) Chmod(remotePath string, mode os.FileMode) error {
+	cmd := fmt.Sprintf("chmod %04o %s", mode.Perm(), shellescape(remotePath))
+
+	session, err := c.newSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("failed to chmod: %w", err)
+	}
+
+	return nil
+}
+
+// Attrs returns a remote file's permission bits, owner and modification time (stat -c needs GNU coreutils)
+func (c *SSHClient) Attrs(remotePath string) (fileAttrs, error) {
+	session, err := c.newSession()
+	if err != nil {
+		return fileAttrs{}, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	output, err := session.Output(fmt.Sprintf("stat -c '%%a %%u %%g %%Y' %s", shellescape(remotePath)))
+	if err != nil {
+		return fileAttrs{}, fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+
+	var mode uint32
+	var modTime int64
+	attrs := fileAttrs{}
+	if _, err := fmt.Sscanf(string(output), "%o %d %d %d", &mode, &attrs.uid, &attrs.gid, &modTime); err != nil {
+		return fileAttrs{}, fmt.Errorf("unexpected stat output %q", strings.TrimSpace(string(output)))
+	}
+	attrs.mode = os.FileMode(mode).Perm()
+	attrs.modTime = time.Unix(modTime, 0)
+	return attrs, nil
+}
+
+// SetModTime sets the access and modification times of a remote file
+// The time's wall clock is interpreted in the remote host's time zone
+func (c *SSHClient) SetModTime(remotePath string, t time.Time) error {
+	cmd := fmt.Sprintf("touch -d %s %s", shellescape(t.Format("2006-01-02 15:04:05")), shellescape(remotePath))
+
+	session, err := c.newSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("failed to set modification time: %w", err)
+	}
+
+	return nil
+}
+
 // parseUsername extracts username from host string
 func parseUsername(host string) string {
 	if strings.Contains(host, "@") {
@@ -185,8 +694,8 @@ func parseUsername(host string) string {
 	return os.Getenv("USER") // Default to current user
 }
 
-// parseHostAddr extracts host:port from host string
-func parseHostAddr(host string) string {
+// parseHostAddr extracts host:port from host string; a port above 0 replaces the one in it
+func parseHostAddr(host string, port int) string {
 	// Remove username if present
 	hostPart := host
 	if strings.Contains(host, "@") {
@@ -194,6 +703,11 @@ func parseHostAddr(host string) string {
 		hostPart = parts[1]
 	}
 
+	if port > 0 {
+		hostPart, _, _ = strings.Cut(hostPart, ":")
+		return net.JoinHostPort(hostPart, strconv.Itoa(port))
+	}
+
 	// Add default port if not specified
 	if !strings.Contains(hostPart, ":") {
 		return hostPart + ":22"
@@ -215,17 +729,19 @@ func sshAgent() ssh.AuthMethod {
 	return publicKeyAuth()
 }
 
-// publicKeyAuth loads SSH keys from standard locations
-func publicKeyAuth() ssh.AuthMethod {
-	// Try common key locations
-	keyPaths := []string{
+// sshKeyPaths returns the private key locations tried for authentication, in order
+func sshKeyPaths() []string {
+	return []string{
 		filepath.Join(os.Getenv("HOME"), ".ssh", "nas_key"),
 		filepath.Join(os.Getenv("HOME"), ".ssh", "id_ed25519"),
 		filepath.Join(os.Getenv("HOME"), ".ssh", "id_rsa"),
 	}
+}
 
+// publicKeyAuth loads SSH keys from standard locations
+func publicKeyAuth() ssh.AuthMethod {
 	var signers []ssh.Signer
-	for _, keyPath := range keyPaths {
+	for _, keyPath := range sshKeyPaths() {
 		key, err := os.ReadFile(keyPath)
 		if err != nil {
 			continue
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSSHClient starts an SSH server on localhost that runs each session's command
+// with sh on this machine, and returns a client connected to it
+func newTestSSHClient(t *testing.T) *SSHClient {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSH(conn, serverConfig)
+		}
+	}()
+
+	config := &ssh.ClientConfig{User: "test", HostKeyCallback: ssh.InsecureIgnoreHostKey()}
+	addr := listener.Addr().String()
+	client, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &SSHClient{sshClient: client, host: addr, addr: addr, config: config, stopKeepalive: make(chan struct{})}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// testSSHRefuseSessions makes the test server refuse new sessions, like OpenSSH at MaxSessions
+var testSSHRefuseSessions atomic.Bool
+
+// serveTestSSH handles one connection of the test server
+func serveTestSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		if testSSHRefuseSessions.Load() {
+			newChannel.Reject(ssh.ResourceShortage, "too many sessions")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveTestSession(channel, requests)
+	}
+}
+
+// serveTestSession runs a session's exec request and reports its exit status
+func serveTestSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			return
+		}
+		req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+		status := uint32(0)
+		if err := cmd.Run(); err != nil {
+			status = 1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status = uint32(exitErr.ExitCode())
+			}
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+// failingReader returns its data, then err instead of EOF, and closeErr from Close
+type failingReader struct {
+	data     []byte
+	err      error
+	closeErr error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (r *failingReader) Close() error {
+	return r.closeErr
+}
+
+// assertNoUploadLeftovers fails if dir holds anything but the names in keep
+func assertNoUploadLeftovers(t *testing.T, dir string, keep ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !slices.Contains(keep, entry.Name()) {
+			t.Errorf("upload left %s behind", entry.Name())
+		}
+	}
+}
+
+func TestUploadStream(t *testing.T) {
+	c := newTestSSHClient(t)
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "2018-10-21_beach day.jpg")
+
+	if err := c.UploadStream(io.NopCloser(strings.NewReader("jpeg data")), remotePath); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(remotePath); err != nil || string(data) != "jpeg data" {
+		t.Errorf("remote file = %q, %v; want jpeg data", data, err)
+	}
+	assertNoUploadLeftovers(t, dir, filepath.Base(remotePath))
+}
+
+func TestUploadMidCopyErrorLeavesNoFile(t *testing.T) {
+	c := newTestSSHClient(t)
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "2018-10-21_beach.jpg")
+
+	r := &failingReader{data: bytes.Repeat([]byte("x"), 100000), err: errors.New("input/output error")}
+	err := c.UploadStream(r, remotePath)
+	if err == nil {
+		t.Fatal("upload of a failing reader succeeded, want an error")
+	}
+	if !strings.Contains(err.Error(), "input/output error") {
+		t.Errorf("error %q doesn't mention the read error", err)
+	}
+	if _, err := os.Stat(remotePath); !os.IsNotExist(err) {
+		t.Errorf("a truncated %s was left in place (stat error %v)", filepath.Base(remotePath), err)
+	}
+	assertNoUploadLeftovers(t, dir)
+}
+
+func TestUploadMidCopyErrorKeepsExistingFile(t *testing.T) {
+	c := newTestSSHClient(t)
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "2018-10-21_beach.jpg")
+	if err := os.WriteFile(remotePath, []byte("complete"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &failingReader{data: []byte("trunc"), err: errors.New("connection reset")}
+	if err := c.UploadStream(r, remotePath); err == nil {
+		t.Fatal("upload of a failing reader succeeded, want an error")
+	}
+	if data, _ := os.ReadFile(remotePath); string(data) != "complete" {
+		t.Errorf("existing file was replaced with %q", data)
+	}
+	assertNoUploadLeftovers(t, dir, filepath.Base(remotePath))
+}
+
+func TestUploadStreamSourceCloseErrorLeavesNoFile(t *testing.T) {
+	c := newTestSSHClient(t)
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "2018-10-21_beach.jpg")
+
+	// A remote source's cat reports its read error only when the session is closed
+	r := &failingReader{data: []byte("first half"), err: io.EOF, closeErr: errors.New("Process exited with status 1")}
+	if err := c.UploadStream(r, remotePath); err == nil {
+		t.Fatal("upload of a source that failed on close succeeded, want an error")
+	}
+	if _, err := os.Stat(remotePath); !os.IsNotExist(err) {
+		t.Errorf("a truncated %s was left in place (stat error %v)", filepath.Base(remotePath), err)
+	}
+	assertNoUploadLeftovers(t, dir)
+}
+
+func TestUploadRemoteWriteErrorLeavesNoFile(t *testing.T) {
+	c := newTestSSHClient(t)
+	// The destination directory doesn't exist, so the remote cat fails
+	remotePath := filepath.Join(t.TempDir(), "missing", "a.jpg")
+	err := c.UploadStream(io.NopCloser(strings.NewReader("data")), remotePath)
+	if err == nil {
+		t.Fatal("upload into a missing directory succeeded, want an error")
+	}
+	if _, statErr := os.Stat(remotePath); !os.IsNotExist(statErr) {
+		t.Errorf("%s exists after a failed upload", remotePath)
+	}
+}
+
+func TestDownloadFailureRemovesLocalFile(t *testing.T) {
+	c := newTestSSHClient(t)
+	localPath := filepath.Join(t.TempDir(), "download.jpg")
+	if err := c.DownloadFile(filepath.Join(t.TempDir(), "missing.jpg"), localPath); err == nil {
+		t.Fatal("download of a missing file succeeded, want an error")
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Errorf("failed download left %s behind", localPath)
+	}
+}
+
+func TestOpenFileReportsRemoteErrorOnClose(t *testing.T) {
+	c := newTestSSHClient(t)
+	r, err := c.OpenFile(filepath.Join(t.TempDir(), "missing.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, r)
+	if err := r.Close(); err == nil {
+		t.Error("closing a failed remote read returned nil, want the cat error")
+	}
+}
+
+func TestStreamToDestinationSourceErrorLeavesNoFile(t *testing.T) {
+	c := newTestSSHClient(t)
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+
+	p := NewPhotoProcessor(&Config{SourceDir: srcDir, DestDir: destDir, DirectRemoteStream: true})
+	p.dest = localDestination{dirMode: 0755}
+	p.source = sshSource{client: c}
+	p.destClient = c
+
+	// A directory can't be read by cat, so the source fails after the upload started
+	if err := os.Mkdir(filepath.Join(srcDir, "unreadable.jpg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	destPath := filepath.Join(destDir, "2018", "2018-10", "2018-10-21_unreadable.jpg")
+	if err := p.streamToDestination(filepath.Join(srcDir, "unreadable.jpg"), destPath, time.Now(), false); err == nil {
+		t.Fatal("streaming an unreadable source succeeded, want an error")
+	}
+	assertNoUploadLeftovers(t, filepath.Dir(destPath))
+
+	// A readable source still streams
+	if err := os.WriteFile(filepath.Join(srcDir, "beach.jpg"), []byte("jpeg data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destPath = filepath.Join(destDir, "2018", "2018-10", "2018-10-21_beach.jpg")
+	if err := p.streamToDestination(filepath.Join(srcDir, "beach.jpg"), destPath, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(destPath); string(data) != "jpeg data" {
+		t.Errorf("streamed file holds %q, want jpeg data", data)
+	}
+}
+
+func TestRefusedSessionKeepsConnection(t *testing.T) {
+	c := newTestSSHClient(t)
+	client := c.client()
+
+	testSSHRefuseSessions.Store(true)
+	_, err := c.newSession()
+	testSSHRefuseSessions.Store(false)
+	var refused *ssh.OpenChannelError
+	if !errors.As(err, &refused) {
+		t.Fatalf("newSession error = %v, want the server's refusal", err)
+	}
+	if c.client() != client {
+		t.Fatal("a refused session replaced the connection")
+	}
+
+	// Other workers' sessions on the connection keep working
+	session, err := c.newSession()
+	if err != nil {
+		t.Fatalf("connection unusable after a refused session: %v", err)
+	}
+	session.Close()
+}
+
+func TestReadableFiles(t *testing.T) {
+	c := newTestSSHClient(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "IMG 0001.jpg")
+	if err := os.WriteFile(file, []byte("image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "IMG_0002.jpg")
+
+	readable, err := c.ReadableFiles([]string{file, missing, dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !readable[file] || readable[missing] || readable[dir] || len(readable) != 1 {
+		t.Errorf("ReadableFiles = %v, want only %s", readable, file)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runSummary is the aggregate outcome of a run, written to SummaryJSONPath
+type runSummary struct {
+	*ProcessStats
+	NeedsMetadataFiles int     `json:"needs_metadata_files"`
+	DryRun             bool    `json:"dry_run"`
+	StartedAt          string  `json:"started_at"` // RFC 3339
+	ElapsedSeconds     float64 `json:"elapsed_seconds"`
+	Error              string  `json:"error,omitempty"` // Why the run stopped early, e.g. an interruption
+}
+
+// writeSummary writes the final statistics as JSON; runErr is the error the run ended with, if any
+func (p *PhotoProcessor) writeSummary(runErr error) error {
+	p.statsMutex.Lock()
+	stats := *p.stats
+	p.statsMutex.Unlock()
+
+	summary := runSummary{
+		ProcessStats:       &stats,
+		NeedsMetadataFiles: len(stats.NeedsMetadata),
+		DryRun:             p.config.DryRun,
+		StartedAt:          p.startTime.Format(time.RFC3339),
+		ElapsedSeconds:     time.Since(p.startTime).Seconds(),
+	}
+	if runErr != nil {
+		summary.Error = runErr.Error()
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.config.SummaryJSONPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// takeoutMaxNameLength is how long Google Takeout lets a metadata file name grow
+// before ".json"; longer names are cut off at this many characters
+const takeoutMaxNameLength = 46
+
+// takeoutJSONMaxSize bounds how much of a Takeout metadata file is read
+const takeoutJSONMaxSize = 1024 * 1024
+
+// takeoutDuplicateRegex matches the "(1)" Takeout appends to a duplicate file name's stem
+var takeoutDuplicateRegex = regexp.MustCompile(`^(.*)(\(\d+\))$`)
+
+// takeoutMetadata is the part of a Takeout metadata file that dates the photo
+type takeoutMetadata struct {
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"` // Seconds since the epoch, UTC
+	} `json:"photoTakenTime"`
+}
+
+// takeoutJSONNames returns the candidate Takeout metadata file names for a media file
+// name, most specific first. Takeout writes IMG_1234.jpg.json (newer exports:
+// IMG_1234.jpg.supplemental-metadata.json), truncates long names, moves a duplicate's
+// "(1)" after the extension (IMG_1234(1).jpg -> IMG_1234.jpg(1).json) and gives
+// "-edited" copies no metadata file of their own.
+func takeoutJSONNames(mediaBase string) []string {
+	ext := filepath.Ext(mediaBase)
+	stem := strings.TrimSuffix(mediaBase, ext)
+
+	duplicate := ""
+	if m := takeoutDuplicateRegex.FindStringSubmatch(stem); m != nil {
+		stem, duplicate = m[1], m[2]
+	}
+	stem = strings.TrimSuffix(stem, "-edited")
+
+	var names []string
+	for _, name := range []string{stem + ext + ".supplemental-metadata", stem + ext, stem} {
+		if len(name) > takeoutMaxNameLength {
+			name = name[:takeoutMaxNameLength]
+		}
+		names = append(names, name+duplicate+".json")
+	}
+	return names
+}
+
+// findTakeoutJSON maps each media file to the Takeout metadata file next to it
+func findTakeoutJSON(files []SourceFile, mediaFiles []string) map[string]string {
+	exists := make(map[string]bool)
+	for _, file := range files {
+		if strings.EqualFold(filepath.Ext(file.Path), ".json") {
+			exists[file.Path] = true
+		}
+	}
+	if len(exists) == 0 {
+		return nil
+	}
+
+	found := make(map[string]string)
+	for _, mediaPath := range mediaFiles {
+		dir, base := filepath.Split(mediaPath)
+		for _, name := range takeoutJSONNames(base) {
+			if exists[dir+name] {
+				found[mediaPath] = dir + name
+				break
+			}
+		}
+	}
+	return found
+}
+
+// takeoutTakenTime reads the capture time from a media file's Takeout metadata file,
+// in local time. ok is false if the file has none or it is implausible.
+func (p *PhotoProcessor) takeoutTakenTime(mediaPath string) (time.Time, bool, error) {
+	jsonPath, ok := p.takeoutJSON[mediaPath]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	data, err := p.source.ReadHead(jsonPath, takeoutJSONMaxSize)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read %s: %w", jsonPath, err)
+	}
+
+	var metadata takeoutMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to parse %s: %w", jsonPath, err)
+	}
+	seconds, err := strconv.ParseInt(metadata.PhotoTakenTime.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false, nil
+	}
+	t := time.Unix(seconds, 0).Local()
+	if !validYear(t.Year()) {
+		return time.Time{}, false, nil
+	}
+	return t, true, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// TransferStatus describes an in-flight upload or download
+type TransferStatus struct {
+	File      string
+	Direction string  // "upload" or "download"
+	Bytes     int64   // Bytes transferred so far
+	Total     int64   // Total size, or -1 if unknown
+	Rate      float64 // Average bytes per second
+	Done      bool    // Set on the final report once the transfer completes
+}
+
+// transferReporter periodically reports the progress of large transfers
+type transferReporter struct {
+	interval time.Duration
+	report   func(TransferStatus)
+}
+
+// track starts measuring a transfer, returning nil when reporting is disabled
+func (r *transferReporter) track(file, direction string, total int64) *transferMeter {
+	if r == nil || r.interval <= 0 || r.report == nil {
+		return nil
+	}
+	now := time.Now()
+	return &transferMeter{
+		reporter: r,
+		status:   TransferStatus{File: file, Direction: direction, Total: total},
+		start:    now,
+		last:     now,
+	}
+}
+
+// transferMeter counts the bytes of one transfer
+// Transfers shorter than the reporting interval are never reported
+type transferMeter struct {
+	reporter *transferReporter
+	mu       sync.Mutex
+	status   TransferStatus
+	start    time.Time
+	last     time.Time
+	reported bool
+}
+
+// add records n more bytes and reports if the interval has elapsed
+func (m *transferMeter) add(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.Bytes += int64(n)
+	now := time.Now()
+	if now.Sub(m.last) < m.reporter.interval {
+		return
+	}
+	m.last = now
+	m.reported = true
+	m.reporter.report(m.snapshot(now))
+}
+
+// finish sends a final report for transfers that were reported along the way
+func (m *transferMeter) finish() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.reported {
+		return
+	}
+	status := m.snapshot(time.Now())
+	status.Done = true
+	m.reporter.report(status)
+}
+
+// snapshot returns the current status with the average rate filled in
+func (m *transferMeter) snapshot(now time.Time) TransferStatus {
+	status := m.status
+	if elapsed := now.Sub(m.start).Seconds(); elapsed > 0 {
+		status.Rate = float64(status.Bytes) / elapsed
+	}
+	return status
+}
+
+// Reader wraps r so reads are counted
+func (m *transferMeter) Reader(r io.Reader) io.Reader {
+	if m == nil {
+		return r
+	}
+	return &meteredReader{Reader: r, meter: m}
+}
+
+// Writer wraps w so writes are counted
+func (m *transferMeter) Writer(w io.Writer) io.Writer {
+	if m == nil {
+		return w
+	}
+	return &meteredWriter{Writer: w, meter: m}
+}
+
+// meteredReader counts bytes read through it
+type meteredReader struct {
+	io.Reader
+	meter *transferMeter
+}
+
+func (r *meteredReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.meter.add(n)
+	return n, err
+}
+
+// meteredWriter counts bytes written through it
+type meteredWriter struct {
+	io.Writer
+	meter *transferMeter
+}
+
+func (w *meteredWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.meter.add(n)
+	return n, err
+}
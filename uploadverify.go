@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// writeVerified writes a local file to the destination. With VerifyUpload on a remote
+// destination, the uploaded copy is hashed on the remote host and compared with the
+// local file; a mismatching copy is deleted and uploaded again up to VerifyUploadRetries times.
+func (p *PhotoProcessor) writeVerified(localPath, destPath string) error {
+	write := func() error { return p.dest.Write(localPath, destPath) }
+	if !p.config.VerifyUpload || p.destClient == nil {
+		return write()
+	}
+
+	want, err := HashFile(localPath, p.config.HashAlgo)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s for upload verification: %w", localPath, err)
+	}
+	return p.verifyRemoteCopy(destPath, want, write)
+}
+
+// streamVerified streams a source file from the source host into the destination host.
+// With VerifyUpload, the source file is hashed on the source host and compared with the
+// streamed copy, before anything edits the copy's metadata; a mismatching copy is deleted
+// and streamed again up to VerifyUploadRetries times.
+func (p *PhotoProcessor) streamVerified(sourcePath, destPath string) error {
+	stream := func() error {
+		r, err := p.source.Open(sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to open source file: %w", err)
+		}
+		// UploadStream closes r, and leaves nothing at destPath if the source failed mid-read
+		if err := p.destClient.UploadStream(r, destPath); err != nil {
+			return fmt.Errorf("failed to stream file: %w", err)
+		}
+		return nil
+	}
+	if !p.config.VerifyUpload {
+		return stream()
+	}
+
+	want, err := p.sshClient.HashFile(sourcePath, p.config.HashAlgo)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s for upload verification: %w", sourcePath, err)
+	}
+	return p.verifyRemoteCopy(destPath, want, stream)
+}
+
+// verifyRemoteCopy runs write, then hashes destPath on the destination host and compares
+// it with want, deleting and rewriting a mismatching copy up to VerifyUploadRetries times
+func (p *PhotoProcessor) verifyRemoteCopy(destPath, want string, write func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := write(); err != nil {
+			return err
+		}
+		got, err := p.destClient.HashFile(destPath, p.config.HashAlgo)
+		if err != nil {
+			return fmt.Errorf("failed to verify upload of %s: %w", destPath, err)
+		}
+		if got == want {
+			return nil
+		}
+
+		p.count(&p.stats.UploadVerifyFailures)
+		if err := p.destClient.RemoveFile(destPath); err != nil {
+			log.Printf("Warning: failed to remove corrupt upload %s: %v", destPath, err)
+		}
+		if attempt >= p.config.VerifyUploadRetries {
+			return fmt.Errorf("upload of %s failed verification: remote %s %s, expected %s", destPath, hashAlgoName(p.config.HashAlgo), got, want)
+		}
+		log.Printf("Warning: upload of %s failed verification, retrying (%d/%d)", destPath, attempt+1, p.config.VerifyUploadRetries)
+	}
+}
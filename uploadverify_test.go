@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// corruptingSource serves garbage for the first corruptions opens of any file
+type corruptingSource struct {
+	Source
+	corruptions int
+}
+
+func (s *corruptingSource) Open(path string) (io.ReadCloser, error) {
+	if s.corruptions > 0 {
+		s.corruptions--
+		return io.NopCloser(strings.NewReader("corrupt")), nil
+	}
+	return s.Source.Open(path)
+}
+
+func TestStreamToDestinationVerifiesUpload(t *testing.T) {
+	c := newTestSSHClient(t)
+	if !c.HasCommand("sha256sum") {
+		t.Skip("sha256sum not installed")
+	}
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	sourcePath := filepath.Join(srcDir, "beach.jpg")
+	if err := os.WriteFile(sourcePath, []byte("jpeg data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		corruptions  int
+		retries      int
+		wantErr      bool
+		wantFailures int
+	}{
+		{"intact", 0, 1, false, 0},
+		{"retried", 1, 1, false, 1},
+		{"out of retries", 2, 1, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPhotoProcessor(&Config{DirectRemoteStream: true, VerifyUpload: true, VerifyUploadRetries: tt.retries, HashAlgo: "sha256"})
+			p.dest = localDestination{dirMode: 0755}
+			p.source = &corruptingSource{Source: sshSource{client: c}, corruptions: tt.corruptions}
+			p.sshClient = c
+			p.destClient = c
+
+			destPath := filepath.Join(destDir, tt.name, "2018-10-21_beach.jpg")
+			err := p.streamToDestination(sourcePath, destPath, time.Now(), false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("streamToDestination error = %v, want error %v", err, tt.wantErr)
+			}
+			if p.stats.UploadVerifyFailures != tt.wantFailures {
+				t.Errorf("UploadVerifyFailures = %d, want %d", p.stats.UploadVerifyFailures, tt.wantFailures)
+			}
+			data, readErr := os.ReadFile(destPath)
+			switch {
+			case tt.wantErr && !os.IsNotExist(readErr):
+				t.Errorf("failed verification left %q at the destination", data)
+			case !tt.wantErr && string(data) != "jpeg data":
+				t.Errorf("destination holds %q, want jpeg data", data)
+			}
+		})
+	}
+}
+
+func TestValidateAllowsVerifiedDirectStream(t *testing.T) {
+	config := &Config{SourceDir: "/photos", DestDir: "/archive", SSHHost: "src", RemoteDest: true, DestSSHHost: "dest", DirectRemoteStream: true, VerifyUpload: true}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate rejected -verify-upload with -direct-remote-stream: %v", err)
+	}
+}
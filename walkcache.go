@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// walkCacheEntry is one cached enumeration of a source directory
+type walkCacheEntry struct {
+	Created time.Time    `json:"created"`
+	Files   []SourceFile `json:"files"`
+	Sized   bool         `json:"sized,omitempty"` // Whether the listing includes file sizes
+}
+
+// cachedSource wraps a Source so that Walk results are persisted to a local file and
+// reused on restart, avoiding a slow re-enumeration of large remote trees
+type cachedSource struct {
+	Source
+	cachePath string        // JSON file holding entries keyed by host and directory
+	ttl       time.Duration // How long an entry stays valid (0 means forever)
+	host      string        // Source SSH host, or "" for local sources
+	sized     bool          // Whether file sizes are needed; listings cached without them are re-walked
+}
+
+// Walk returns the cached listing for dir if it is fresh, otherwise walks the
+// underlying source and saves the result
+func (s cachedSource) Walk(dir string) ([]SourceFile, error) {
+	key := s.host + ":" + dir
+	entries := s.load()
+
+	if entry, ok := entries[key]; ok && (s.ttl == 0 || time.Since(entry.Created) < s.ttl) && (entry.Sized || !s.sized) {
+		log.Printf("Using cached file list for %s from %s (%d files)", key, entry.Created.Format("2006-01-02 15:04:05"), len(entry.Files))
+		return entry.Files, nil
+	}
+
+	files, err := s.Source.Walk(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries[key] = walkCacheEntry{Created: time.Now(), Files: files, Sized: s.sized}
+	if err := s.save(entries); err != nil {
+		log.Printf("Warning: failed to save walk cache %s: %v", s.cachePath, err)
+	}
+	return files, nil
+}
+
+// load reads the cache file, treating a missing or corrupt file as empty
+func (s cachedSource) load() map[string]walkCacheEntry {
+	entries := make(map[string]walkCacheEntry)
+	data, err := os.ReadFile(s.cachePath)
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Warning: ignoring unreadable walk cache %s: %v", s.cachePath, err)
+		return make(map[string]walkCacheEntry)
+	}
+	return entries
+}
+
+// save writes the cache file atomically so an interrupted run can't corrupt it
+func (s cachedSource) save(entries map[string]walkCacheEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.cachePath), 0755); err != nil {
+		return err
+	}
+	tempPath := s.cachePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return os.Rename(tempPath, s.cachePath)
+}